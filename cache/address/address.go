@@ -27,6 +27,16 @@ func Cache(address string) bool {
 	return false
 }
 
+// Remove removes the given addresses from cache.
+func Remove(addresses ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, addr := range addresses {
+		delete(addrMap, addr)
+	}
+}
+
 // CacheMulti caches multiple addresses.
 func CacheMulti(addresses []string) {
 	mu.Lock()
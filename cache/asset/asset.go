@@ -39,8 +39,8 @@ func Get(hash string) (*models.Asset, bool) {
 
 // Remove removes the given asset from cache.
 func Remove(hash string) {
-	mu.RLock()
-	defer mu.RUnlock()
+	mu.Lock()
+	defer mu.Unlock()
 
 	delete(assetMap, hash)
 }
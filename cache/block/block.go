@@ -2,17 +2,19 @@ package block
 
 import (
 	"container/list"
+	"neo3-squirrel/config"
 	"neo3-squirrel/models"
 	"sync"
 )
 
-const maxCachedBlocks = 50000
-
 var (
 	blockIndexQueue = list.New()
 	blockMap        = map[uint]*models.Block{}
 	transactionMap  = map[string]*models.Transaction{}
 
+	// cachedBytes is the estimated memory held by cached blocks.
+	cachedBytes int64
+
 	mu sync.Mutex
 
 	// bulkMode prevents double mutex lock
@@ -34,7 +36,7 @@ func CacheBlocks(blocks []*models.Block) {
 }
 
 // CacheBlock caches a single block and its transactoins.
-// If cached block size exceeded the limite,
+// If cached blocks exceeded the memory budget,
 // lowerest indexes will be removed.
 func CacheBlock(block *models.Block) {
 	if block == nil {
@@ -52,27 +54,49 @@ func CacheBlock(block *models.Block) {
 
 	blockIndexQueue.PushBack(block.Index)
 	blockMap[block.Index] = block
+	cachedBytes += block.MemSize()
 	for _, tx := range block.GetTxs() {
 		transactionMap[tx.Hash] = tx
 	}
 
-	// Remove oldest cached block if exceed the maximum limitation.
-	if blockIndexQueue.Len() > maxCachedBlocks {
+	// Remove oldest cached blocks if exceed the memory budget.
+	budget := config.GetBlockCacheBytes()
+	for budget > 0 && cachedBytes > budget && blockIndexQueue.Len() > 1 {
 		firstElem := blockIndexQueue.Front()
-		blockIndexToRemove := firstElem.Value.(uint)
-
 		blockIndexQueue.Remove(firstElem)
+		remove(firstElem.Value.(uint))
+	}
+}
 
-		blockToRemove, ok := blockMap[blockIndexToRemove]
-		if !ok {
-			return
-		}
+func remove(blockIndex uint) {
+	block, ok := blockMap[blockIndex]
+	if !ok {
+		return
+	}
+
+	for _, tx := range block.GetTxs() {
+		delete(transactionMap, tx.Hash)
+	}
 
-		for _, tx := range blockToRemove.GetTxs() {
-			delete(transactionMap, tx.Hash)
+	delete(blockMap, blockIndex)
+	cachedBytes -= block.MemSize()
+}
+
+// Truncate removes cached blocks above the given index.
+func Truncate(blockIndex uint) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for elem := blockIndexQueue.Front(); elem != nil; {
+		next := elem.Next()
+		index := elem.Value.(uint)
+
+		if index > blockIndex {
+			remove(index)
+			blockIndexQueue.Remove(elem)
 		}
 
-		delete(blockMap, blockIndexToRemove)
+		elem = next
 	}
 }
 
@@ -93,3 +117,12 @@ func GetTransaction(txID string) (*models.Transaction, bool) {
 	tx, ok := transactionMap[txID]
 	return tx, ok
 }
+
+// Usage returns the estimated memory held by cached blocks
+// and the number of cached blocks.
+func Usage() (int64, int) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return cachedBytes, len(blockMap)
+}
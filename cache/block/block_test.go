@@ -0,0 +1,41 @@
+package block
+
+import (
+	"neo3-squirrel/models"
+	"testing"
+)
+
+func TestTruncate(t *testing.T) {
+	for index := uint(0); index < 5; index++ {
+		b := &models.Block{Index: index, Size: 100}
+		b.SetTxs([]*models.Transaction{{Hash: string(rune('a' + index)), BlockIndex: index}})
+		CacheBlock(b)
+	}
+
+	bytes, _ := Usage()
+	Truncate(2)
+
+	for index := uint(0); index < 5; index++ {
+		if _, ok := GetBlock(index); ok != (index <= 2) {
+			t.Fatalf("Only blocks up to 2 must be kept, block %d cached: %v", index, ok)
+		}
+	}
+
+	if _, ok := GetTransaction("d"); ok {
+		t.Fatal("Transactions of truncated blocks must be removed")
+	}
+
+	if _, ok := GetTransaction("c"); !ok {
+		t.Fatal("Transactions of kept blocks must stay cached")
+	}
+
+	if remaining, count := Usage(); count != 3 || remaining != bytes*3/5 {
+		t.Fatalf("Incorrect cache usage after truncation: %d bytes, %d blocks", remaining, count)
+	}
+
+	// Blocks after the truncated height can be cached again.
+	CacheBlock(&models.Block{Index: 3})
+	if _, ok := GetBlock(3); !ok {
+		t.Fatal("Block 3 must be cached again after truncation")
+	}
+}
@@ -20,12 +20,16 @@ func CacheGASTotalSupply(newBlockIndex uint, newTotalSupply *big.Float) {
 		return
 	}
 
+	blockIndex = newBlockIndex
 	totalSupply = newTotalSupply
 }
 
 // CachedTillBlockIndex returns the block height
 // which GAS total supply was queried.
 func CachedTillBlockIndex() uint {
+	mu.Lock()
+	defer mu.Unlock()
+
 	return blockIndex
 }
 
@@ -0,0 +1,70 @@
+package native
+
+import (
+	"neo3-squirrel/util/log"
+	"sync"
+)
+
+// Names of native contracts referred by sync tasks.
+const (
+	ContractManagement = "ContractManagement"
+	NeoToken           = "NeoToken"
+	GasToken           = "GasToken"
+)
+
+var (
+	hashes = map[string]string{}
+	names  = map[string]string{}
+	mu     sync.RWMutex
+)
+
+// Register adds native contract to the registry.
+func Register(name, hash string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	hashes[name] = hash
+	names[hash] = name
+}
+
+// Hash returns the hash of the given native contract name.
+func Hash(name string) (string, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	hash, ok := hashes[name]
+	return hash, ok
+}
+
+// IsNative tells if the given contract hash is native contract.
+func IsNative(hash string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	_, ok := names[hash]
+	return ok
+}
+
+// Management returns the hash of native contract ContractManagement.
+func Management() string {
+	return mustHash(ContractManagement)
+}
+
+// NEO returns the hash of native contract NeoToken.
+func NEO() string {
+	return mustHash(NeoToken)
+}
+
+// GAS returns the hash of native contract GasToken.
+func GAS() string {
+	return mustHash(GasToken)
+}
+
+func mustHash(name string) string {
+	hash, ok := Hash(name)
+	if !ok {
+		log.Panicf("Native contract %s not discovered", name)
+	}
+
+	return hash
+}
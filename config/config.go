@@ -9,7 +9,9 @@ import (
 	"neo3-squirrel/util/convert"
 	"net"
 	"net/url"
+	"reflect"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -33,10 +35,89 @@ type config struct {
 	Label string
 
 	// RPCs are backend NEO-CLI nodes used in JSON-RPC queries.
-	RPCs []string `mapstructure:"rpcs"`
+	RPCs []RPCNode `mapstructure:"rpcs"`
 
-	// Workers sets the number of goroutines that will be created for data processing.
+	// Workers sets the number of goroutines that will be created for data processing,
+	// block fetchers are resized within it by throughput and node health.
 	Workers int
+
+	// BatchSize sets the maximum number of blocks or application logs
+	// fetched in one JSON-RPC batch request.
+	BatchSize int `mapstructure:"batchsize"`
+
+	// StartHeight is the block height to start syncing from on empty db.
+	StartHeight uint `mapstructure:"startheight"`
+
+	// EndHeight is the last block height to sync, no limit if absent or null.
+	EndHeight *uint `mapstructure:"endheight"`
+
+	// BlockBufferMB is the estimated memory budget of blocks
+	// fetched and waiting to be persisted, in megabytes.
+	BlockBufferMB int `mapstructure:"blockbuffermb"`
+
+	// BlockCacheMB is the estimated memory budget of
+	// cached blocks and transactions, in megabytes.
+	BlockCacheMB int `mapstructure:"blockcachemb"`
+
+	// Flush decides when fetched blocks are persisted.
+	Flush FlushPolicy `mapstructure:"flush"`
+
+	// VerifyTxWitnesses verifies signatures of tx witnesses
+	// of standard and multi-signature accounts.
+	VerifyTxWitnesses bool `mapstructure:"verifytxwitnesses"`
+
+	// ShutdownTimeout is the maximum seconds to wait for
+	// sync tasks flushing their pending data on exit.
+	ShutdownTimeout int `mapstructure:"shutdowntimeout"`
+}
+
+const (
+	// defaultBatchSize is used if batchSize is not set.
+	defaultBatchSize = 10
+
+	// defaultRPCWeight is used if weight of rpc node is not set.
+	defaultRPCWeight = 1.0
+
+	// defaultShutdownTimeout is used if shutdownTimeout is not set.
+	defaultShutdownTimeout = 30
+
+	// defaultBlockBufferMB is used if blockBufferMB is not set.
+	defaultBlockBufferMB = 1024
+
+	// defaultBlockCacheMB is used if blockCacheMB is not set.
+	defaultBlockCacheMB = 512
+
+	// Default flush limits are used if the flush policy is not set.
+	defaultFlushMaxKB        = 2048
+	defaultFlushMaxBlocks    = 1000
+	defaultFlushMaxLatencyMS = 1000
+)
+
+// FlushPolicy decides when fetched blocks are persisted, pending blocks
+// are flushed once any limit reached. A zero limit is not applied,
+// defaults are used if no limit is set.
+type FlushPolicy struct {
+	// MaxKB is the serialized size of pending blocks in kilobytes.
+	MaxKB int `mapstructure:"maxkb"`
+	// MaxBlocks is the number of pending blocks.
+	MaxBlocks int `mapstructure:"maxblocks"`
+	// MaxLatencyMS is the milliseconds the first pending block waited.
+	MaxLatencyMS int `mapstructure:"maxlatencyms"`
+	// TipMode flushes each block immediately once synced to the tip.
+	TipMode bool `mapstructure:"tipmode"`
+}
+
+// RPCNode is a backend NEO-CLI node. It can be configured
+// as a plain url string, or an object with url, weight and priority.
+type RPCNode struct {
+	URL string `mapstructure:"url"`
+	// Weight scales the chance of the node being selected, defaults to 1.
+	// A node of weight 0 is not selected unless all nodes of its priority have weight 0.
+	Weight float64 `mapstructure:"weight"`
+	// Priority prefers healthy nodes with higher priority.
+	Priority int `mapstructure:"priority"`
+	// WS is the optional WebSocket endpoint to subscribe new blocks.
+	WS string `mapstructure:"ws"`
 }
 
 var cfg config
@@ -56,6 +137,7 @@ func Load(display bool, debug, debugSQL bool) {
 	cfg.DebugSQL = debugSQL
 
 	attachRPCHTTPScheme()
+	setDefaults()
 
 	if err := validateConfig(); err != nil {
 		panic(err)
@@ -89,6 +171,16 @@ func GetLabel() string {
 
 // GetRPCs returns all rpc urls from config.
 func GetRPCs() []string {
+	urls := []string{}
+	for _, node := range cfg.RPCs {
+		urls = append(urls, node.URL)
+	}
+
+	return urls
+}
+
+// GetRPCNodes returns all rpc nodes from config.
+func GetRPCNodes() []RPCNode {
 	return cfg.RPCs
 }
 
@@ -97,6 +189,51 @@ func GetWorkers() int {
 	return cfg.Workers
 }
 
+// GetBatchSize returns the maximum size of a JSON-RPC batch request.
+func GetBatchSize() int {
+	return cfg.BatchSize
+}
+
+// GetStartHeight returns the block height to start syncing from on empty db.
+func GetStartHeight() uint {
+	return cfg.StartHeight
+}
+
+// GetEndHeight returns the last block height to sync,
+// returns false if sync range not bounded.
+func GetEndHeight() (uint, bool) {
+	if cfg.EndHeight == nil {
+		return 0, false
+	}
+
+	return *cfg.EndHeight, true
+}
+
+// GetBlockBufferBytes returns the memory budget of blocks waiting to be persisted.
+func GetBlockBufferBytes() int64 {
+	return int64(cfg.BlockBufferMB) << 20
+}
+
+// GetBlockCacheBytes returns the memory budget of cached blocks and transactions.
+func GetBlockCacheBytes() int64 {
+	return int64(cfg.BlockCacheMB) << 20
+}
+
+// GetFlushPolicy returns the policy of block persistence.
+func GetFlushPolicy() FlushPolicy {
+	return cfg.Flush
+}
+
+// VerifyTxWitnesses tells if signatures of tx witnesses are verified.
+func VerifyTxWitnesses() bool {
+	return cfg.VerifyTxWitnesses
+}
+
+// GetShutdownTimeout returns the maximum duration to wait for sync tasks on exit.
+func GetShutdownTimeout() time.Duration {
+	return time.Duration(cfg.ShutdownTimeout) * time.Second
+}
+
 // GetDbConnStr returns db connection string.
 func GetDbConnStr() string {
 	str := fmt.Sprintf(
@@ -121,12 +258,15 @@ func GetDBInfo() string {
 ------------------------------ */
 
 func load() error {
+	// Zero values of booleans cannot tell if they are set.
+	viper.SetDefault("flush.tipmode", true)
+
 	err := viper.ReadInConfig()
 	if err != nil {
 		return err
 	}
 
-	err = viper.Unmarshal(&cfg)
+	err = viper.Unmarshal(&cfg, viper.DecodeHook(decodeRPCNode))
 	if err != nil {
 		return err
 	}
@@ -134,6 +274,25 @@ func load() error {
 	return nil
 }
 
+// decodeRPCNode decodes rpc node from plain url string,
+// the weight defaults to 1 if not set.
+func decodeRPCNode(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if to != reflect.TypeOf(RPCNode{}) {
+		return data, nil
+	}
+
+	switch node := data.(type) {
+	case string:
+		return RPCNode{URL: node, Weight: defaultRPCWeight}, nil
+	case map[string]interface{}:
+		if _, ok := node["weight"]; !ok {
+			node["weight"] = defaultRPCWeight
+		}
+	}
+
+	return data, nil
+}
+
 func printConfig() {
 	dbPass := cfg.Password
 	if len(dbPass) != 0 {
@@ -151,13 +310,37 @@ func printConfig() {
 
 func attachRPCHTTPScheme() {
 	for i := 0; i < len(cfg.RPCs); i++ {
-		rpc := cfg.RPCs[i]
+		rpc := cfg.RPCs[i].URL
 		if !strings.HasPrefix(rpc, "http") {
-			cfg.RPCs[i] = "http://" + rpc
+			cfg.RPCs[i].URL = "http://" + rpc
 		}
 	}
 }
 
+func setDefaults() {
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = defaultShutdownTimeout
+	}
+
+	if cfg.BlockBufferMB == 0 {
+		cfg.BlockBufferMB = defaultBlockBufferMB
+	}
+
+	if cfg.BlockCacheMB == 0 {
+		cfg.BlockCacheMB = defaultBlockCacheMB
+	}
+
+	if cfg.Flush.MaxKB == 0 && cfg.Flush.MaxBlocks == 0 && cfg.Flush.MaxLatencyMS == 0 {
+		cfg.Flush.MaxKB = defaultFlushMaxKB
+		cfg.Flush.MaxBlocks = defaultFlushMaxBlocks
+		cfg.Flush.MaxLatencyMS = defaultFlushMaxLatencyMS
+	}
+}
+
 func validateConfig() error {
 	if err := checkRPCs(); err != nil {
 		return err
@@ -167,6 +350,26 @@ func validateConfig() error {
 		return errors.New("workers must be great than 0")
 	}
 
+	if cfg.BatchSize < 0 {
+		return errors.New("batchSize must not be negative")
+	}
+
+	if cfg.EndHeight != nil && *cfg.EndHeight < cfg.StartHeight {
+		return errors.New("endHeight must not be lower than startHeight")
+	}
+
+	if cfg.BlockBufferMB < 0 || cfg.BlockCacheMB < 0 {
+		return errors.New("blockBufferMB and blockCacheMB must not be negative")
+	}
+
+	if cfg.Flush.MaxKB < 0 || cfg.Flush.MaxBlocks < 0 || cfg.Flush.MaxLatencyMS < 0 {
+		return errors.New("flush limits must not be negative")
+	}
+
+	if cfg.ShutdownTimeout < 0 {
+		return errors.New("shutdownTimeout must not be negative")
+	}
+
 	return nil
 }
 
@@ -175,7 +378,23 @@ func checkRPCs() error {
 		return errors.New("at least 1 rpc server url must be set")
 	}
 
-	for _, rpc := range cfg.RPCs {
+	for _, node := range cfg.RPCs {
+		if node.Weight < 0 {
+			return fmt.Errorf("weight of rpc %s must not be negative", node.URL)
+		}
+
+		if node.WS != "" {
+			u, err := url.Parse(node.WS)
+			if err != nil {
+				return err
+			}
+
+			if u.Scheme != "ws" && u.Scheme != "wss" {
+				return fmt.Errorf("websocket url of rpc %s must start with ws:// or wss://", node.URL)
+			}
+		}
+
+		rpc := node.URL
 		if strings.HasPrefix(rpc, "http") {
 			u, err := url.Parse(rpc)
 			if err != nil {
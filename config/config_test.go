@@ -0,0 +1,89 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestSyncHeightRange(t *testing.T) {
+	defer func() {
+		cfg = config{}
+	}()
+
+	cfg = config{
+		RPCs:    []RPCNode{{URL: "http://127.0.0.1:10332"}},
+		Workers: 1,
+	}
+
+	if _, ok := GetEndHeight(); ok {
+		t.Fatalf("End height must not be set by default")
+	}
+
+	endHeight := uint(99)
+	cfg.StartHeight = 100
+	cfg.EndHeight = &endHeight
+	if err := validateConfig(); err == nil {
+		t.Fatalf("End height lower than start height must be rejected")
+	}
+
+	endHeight = 100
+	if err := validateConfig(); err != nil {
+		t.Fatalf("Single block range must be accepted, got %v", err)
+	}
+
+	if end, ok := GetEndHeight(); !ok || end != 100 {
+		t.Fatalf("Incorrect end height, expected 100, got %d", end)
+	}
+
+	// Sync can stop after the genesis block.
+	endHeight = 0
+	cfg.StartHeight = 0
+	if end, ok := GetEndHeight(); !ok || end != 0 {
+		t.Fatalf("End height 0 must bound the range, got %d(%v)", end, ok)
+	}
+
+	cfg.EndHeight = nil
+	if err := validateConfig(); err != nil {
+		t.Fatalf("Unbounded range must be accepted, got %v", err)
+	}
+
+	if _, ok := GetEndHeight(); ok {
+		t.Fatalf("End height must not be set if absent")
+	}
+}
+
+func TestRPCNodeWeight(t *testing.T) {
+	defer func() {
+		viper.Reset()
+		cfg = config{}
+	}()
+
+	viper.SetConfigType("json")
+	err := viper.ReadConfig(strings.NewReader(`{"rpcs": [
+		"http://127.0.0.1:10332",
+		{"url": "http://127.0.0.1:20332"},
+		{"url": "http://127.0.0.1:30332", "weight": 0},
+		{"url": "http://127.0.0.1:40332", "weight": 2}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := viper.Unmarshal(&cfg, viper.DecodeHook(decodeRPCNode)); err != nil {
+		t.Fatal(err)
+	}
+	setDefaults()
+
+	expected := []float64{1, 1, 0, 2}
+	if len(GetRPCNodes()) != len(expected) {
+		t.Fatalf("Expected %d rpc nodes, got %d", len(expected), len(GetRPCNodes()))
+	}
+
+	for i, node := range GetRPCNodes() {
+		if node.Weight != expected[i] {
+			t.Fatalf("Incorrect weight of %s, expected %v, got %v", node.URL, expected[i], node.Weight)
+		}
+	}
+}
@@ -31,6 +31,7 @@ var addrAssetColumns = []string{
 	"`contract`",
 	"`balance`",
 	"`transfers`",
+	"`opening_balance`",
 }
 
 // GetAllAssets returns all assets from DB.
@@ -101,6 +102,26 @@ func getAddrAssetBalance(sqlTx *sql.Tx, addr, assetHash string) *big.Float {
 	return convert.ToDecimal(balanceStr)
 }
 
+// AddrAssetExists tells if the address balance record of the asset exists.
+func AddrAssetExists(addr, assetHash string) bool {
+	query := []string{
+		"SELECT EXISTS(",
+		"SELECT `id`",
+		"FROM `addr_asset`",
+		fmt.Sprintf("WHERE `address`='%s'", addr),
+		fmt.Sprintf("AND `contract`='%s'", assetHash),
+		"LIMIT 1)",
+	}
+
+	var exists bool
+	err := mysql.QueryRow(mysql.Compose(query), nil, &exists)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return exists
+}
+
 // GetAsset returns the asset info of the given hash.
 func GetAsset(assetHash string) *models.Asset {
 	query := []string{
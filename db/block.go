@@ -2,6 +2,7 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"neo3-squirrel/models"
 	"neo3-squirrel/pkg/mysql"
@@ -9,6 +10,18 @@ import (
 	"strings"
 )
 
+// txChildTables store parts of transactions by `transaction_hash`.
+var txChildTables = []string{
+	"transaction_signer",
+	"transaction_attribute",
+	"transaction_witness",
+	"transaction_signer_witness",
+	"transaction_signer_contract",
+	"transaction_signer_group",
+	"transaction_signer_rule",
+	"transaction_signer_condition",
+}
+
 var blockColumns = []string{
 	"`id`",
 	"`hash`",
@@ -24,17 +37,30 @@ var blockColumns = []string{
 }
 
 // InsertBlock inserts bulk blocks into database.
-func InsertBlock(blocks []*models.Block, txBulk *models.TxBulk) {
+func InsertBlock(blocks []*models.Block, txBulk *models.TxBulk) error {
 	if len(blocks) == 0 {
-		return
+		return nil
 	}
 
+	return mysql.Trans(func(sqlTx *sql.Tx) error {
+		if err := insertBlocks(sqlTx, blocks, txBulk); err != nil {
+			return err
+		}
+
+		return updateBlockIndexCounter(sqlTx, blocks[len(blocks)-1].Index)
+	})
+}
+
+func insertBlocks(sqlTx *sql.Tx, blocks []*models.Block, txBulk *models.TxBulk) error {
 	insertBlocksCmd := generateInsertCmdForBlocks(blocks)
 	insertBlockWitnessesCmd := generateInsertCmdForBlockWitnesses(blocks)
 	insertTxsCmd := generateInsertCmdForTxs(txBulk.Txs)
 	insertTxSignersCmd := generateInsertCmdForTxSigners(txBulk.TxSigners)
 	insertTxAttrsCmd := generateInsertCmdForTxAttrs(txBulk.TxAttrs)
 	insertTxWitnessCmd := generateInsertCmdForTxWitnesses(txBulk.TxWitnesses)
+	insertTxSignerWitnessCmd := generateInsertCmdForTxSignerWitnesses(txBulk.TxSignerWitnesses)
+	insertPubKeysCmd := generateInsertCmdForPubKeys(txBulk.PubKeys)
+	insertTxSignerScopesCmds := generateInsertCmdsForTxSignerScopes(txBulk)
 
 	cmds := []string{
 		insertBlocksCmd,
@@ -43,26 +69,23 @@ func InsertBlock(blocks []*models.Block, txBulk *models.TxBulk) {
 		insertTxSignersCmd,
 		insertTxAttrsCmd,
 		insertTxWitnessCmd,
+		insertTxSignerWitnessCmd,
+		insertPubKeysCmd,
 	}
+	cmds = append(cmds, insertTxSignerScopesCmds...)
+	cmds = append(cmds, generateInsertCmdsForMultiSigAccounts(txBulk.MultiSigAccounts)...)
 
-	mysql.Trans(func(sqlTx *sql.Tx) error {
-		for _, cmd := range cmds {
-			if cmd == "" {
-				continue
-			}
-			if _, err := sqlTx.Exec(cmd); err != nil {
-				log.Error(err)
-				return err
-			}
+	for _, cmd := range cmds {
+		if cmd == "" {
+			continue
 		}
-
-		err := updateBlockIndexCounter(sqlTx, blocks[len(blocks)-1].Index)
-		if err != nil {
+		if _, err := sqlTx.Exec(cmd); err != nil {
+			log.Error(err)
 			return err
 		}
+	}
 
-		return updateTxCounter(sqlTx, len(txBulk.Txs))
-	})
+	return updateTxCounter(sqlTx, len(txBulk.Txs))
 }
 
 // GetBlock returns block record from db.
@@ -101,6 +124,92 @@ func GetBlock(index uint) *models.Block {
 	return &block
 }
 
+// GetBlocksWithWitnesses returns blocks within [from, to] in order,
+// together with their witnesses.
+func GetBlocksWithWitnesses(from, to uint) []*models.Block {
+	query := []string{
+		fmt.Sprintf("SELECT %s", strings.Join(blockColumns, ", ")),
+		"FROM `block`",
+		fmt.Sprintf("WHERE `index` BETWEEN %d AND %d", from, to),
+		"ORDER BY `index` ASC",
+	}
+
+	rows, err := mysql.Query(mysql.Compose(query))
+	if err != nil {
+		log.Error(mysql.Compose(query))
+		log.Panic(err)
+	}
+
+	defer rows.Close()
+
+	blocks := []*models.Block{}
+	hashes := []string{}
+	for rows.Next() {
+		var b models.Block
+		err := rows.Scan(
+			&b.ID,
+			&b.Hash,
+			&b.Size,
+			&b.Version,
+			&b.PreviousBlockHash,
+			&b.MerkleRoot,
+			&b.Txs,
+			&b.Time,
+			&b.Index,
+			&b.Primary,
+			&b.NextConsensus,
+		)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		blocks = append(blocks, &b)
+		hashes = append(hashes, fmt.Sprintf("'%s'", b.Hash))
+	}
+
+	if len(blocks) == 0 {
+		return blocks
+	}
+
+	witnesses := getBlockWitnesses(hashes)
+	for _, b := range blocks {
+		b.Witnesses = witnesses[b.Hash]
+	}
+
+	return blocks
+}
+
+// getBlockWitnesses returns witnesses of the quoted block hashes.
+func getBlockWitnesses(hashes []string) map[string][]models.Witness {
+	query := []string{
+		"SELECT `block_hash`, `invocation`, `verification`",
+		"FROM `block_witness`",
+		fmt.Sprintf("WHERE `block_hash` IN (%s)", strings.Join(hashes, ", ")),
+		"ORDER BY `id` ASC",
+	}
+
+	rows, err := mysql.Query(mysql.Compose(query))
+	if err != nil {
+		log.Error(mysql.Compose(query))
+		log.Panic(err)
+	}
+
+	defer rows.Close()
+
+	witnesses := map[string][]models.Witness{}
+	for rows.Next() {
+		var hash string
+		var w models.Witness
+		if err := rows.Scan(&hash, &w.Invocation, &w.Verification); err != nil {
+			log.Panic(err)
+		}
+
+		witnesses[hash] = append(witnesses[hash], w)
+	}
+
+	return witnesses
+}
+
 func generateInsertCmdForBlocks(blocks []*models.Block) string {
 	if len(blocks) == 0 {
 		return ""
@@ -267,3 +376,217 @@ func generateInsertCmdForTxWitnesses(witnesses []*models.TransactionWitness) str
 
 	return strings.TrimSuffix(strBuilder.String(), ",")
 }
+
+func generateInsertCmdForTxSignerWitnesses(witnesses []*models.TransactionSignerWitness) string {
+	if len(witnesses) == 0 {
+		return ""
+	}
+
+	columns := []string{
+		"`transaction_hash`",
+		"`account`",
+		"`type`",
+		"`threshold`",
+		"`pubkeys`",
+		"`signature`",
+	}
+
+	var strBuilder strings.Builder
+	strBuilder.WriteString(fmt.Sprintf("INSERT INTO `transaction_signer_witness` (%s) VALUES", strings.Join(columns, ", ")))
+
+	for _, witness := range witnesses {
+		pubKeys, err := json.Marshal(witness.PubKeys)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		strBuilder.WriteString(fmt.Sprintf("('%s', '%s', '%s', %d, '%s', '%s'),",
+			witness.TransactionHash,
+			witness.Account,
+			witness.Type,
+			witness.Threshold,
+			pubKeys,
+			witness.Signature,
+		))
+	}
+
+	return strings.TrimSuffix(strBuilder.String(), ",")
+}
+
+// generateInsertCmdForPubKeys keeps the first block each address signed in.
+func generateInsertCmdForPubKeys(pubKeys []*models.PubKey) string {
+	if len(pubKeys) == 0 {
+		return ""
+	}
+
+	columns := []string{
+		"`address`",
+		"`pubkey`",
+		"`block_index`",
+	}
+
+	var strBuilder strings.Builder
+	strBuilder.WriteString(fmt.Sprintf("INSERT INTO `pubkey` (%s) VALUES", strings.Join(columns, ", ")))
+
+	for _, pubKey := range pubKeys {
+		strBuilder.WriteString(fmt.Sprintf("('%s', '%s', %d),",
+			pubKey.Address,
+			pubKey.PubKey,
+			pubKey.BlockIndex,
+		))
+	}
+
+	cmd := strings.TrimSuffix(strBuilder.String(), ",")
+	return cmd + " ON DUPLICATE KEY UPDATE `block_index` = LEAST(`block_index`, VALUES(`block_index`))"
+}
+
+// generateInsertCmdsForTxSignerScopes generates commands for
+// allowed contracts, allowed groups and witness rules of signers.
+func generateInsertCmdsForTxSignerScopes(txBulk *models.TxBulk) []string {
+	return []string{
+		generateInsertCmdForTxSignerContracts(txBulk.TxSignerContracts),
+		generateInsertCmdForTxSignerGroups(txBulk.TxSignerGroups),
+		generateInsertCmdForTxSignerRules(txBulk.TxSignerRules),
+		generateInsertCmdForTxSignerConditions(txBulk.TxSignerConditions),
+	}
+}
+
+func generateInsertCmdForTxSignerContracts(contracts []*models.TransactionSignerContract) string {
+	if len(contracts) == 0 {
+		return ""
+	}
+
+	columns := []string{
+		"`transaction_hash`",
+		"`account`",
+		"`contract`",
+	}
+
+	var strBuilder strings.Builder
+	strBuilder.WriteString(fmt.Sprintf("INSERT INTO `transaction_signer_contract` (%s) VALUES", strings.Join(columns, ", ")))
+
+	for _, contract := range contracts {
+		strBuilder.WriteString(fmt.Sprintf("('%s', '%s', '%s'),",
+			contract.TransactionHash,
+			contract.Account,
+			contract.Contract,
+		))
+	}
+
+	return strings.TrimSuffix(strBuilder.String(), ",")
+}
+
+func generateInsertCmdForTxSignerGroups(groups []*models.TransactionSignerGroup) string {
+	if len(groups) == 0 {
+		return ""
+	}
+
+	columns := []string{
+		"`transaction_hash`",
+		"`account`",
+		"`group`",
+	}
+
+	var strBuilder strings.Builder
+	strBuilder.WriteString(fmt.Sprintf("INSERT INTO `transaction_signer_group` (%s) VALUES", strings.Join(columns, ", ")))
+
+	for _, group := range groups {
+		strBuilder.WriteString(fmt.Sprintf("('%s', '%s', '%s'),",
+			group.TransactionHash,
+			group.Account,
+			group.Group,
+		))
+	}
+
+	return strings.TrimSuffix(strBuilder.String(), ",")
+}
+
+func generateInsertCmdForTxSignerRules(rules []*models.TransactionSignerRule) string {
+	if len(rules) == 0 {
+		return ""
+	}
+
+	columns := []string{
+		"`transaction_hash`",
+		"`account`",
+		"`rule_index`",
+		"`action`",
+		"`condition`",
+	}
+
+	var strBuilder strings.Builder
+	strBuilder.WriteString(fmt.Sprintf("INSERT INTO `transaction_signer_rule` (%s) VALUES", strings.Join(columns, ", ")))
+
+	for _, rule := range rules {
+		condition, err := json.Marshal(rule.Condition)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		strBuilder.WriteString(fmt.Sprintf("('%s', '%s', %d, '%s', '%s'),",
+			rule.TransactionHash,
+			rule.Account,
+			rule.RuleIndex,
+			rule.Action,
+			condition,
+		))
+	}
+
+	return strings.TrimSuffix(strBuilder.String(), ",")
+}
+
+func generateInsertCmdForTxSignerConditions(conditions []*models.TransactionSignerCondition) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+
+	columns := []string{
+		"`transaction_hash`",
+		"`account`",
+		"`rule_index`",
+		"`node_index`",
+		"`parent_index`",
+		"`type`",
+		"`expression`",
+		"`hash`",
+		"`group`",
+	}
+
+	var strBuilder strings.Builder
+	strBuilder.WriteString(fmt.Sprintf("INSERT INTO `transaction_signer_condition` (%s) VALUES", strings.Join(columns, ", ")))
+
+	for _, cond := range conditions {
+		// Root nodes and fields unused by the condition type are NULL.
+		parent := "NULL"
+		if cond.ParentIndex >= 0 {
+			parent = fmt.Sprint(cond.ParentIndex)
+		}
+
+		expression := "NULL"
+		if cond.Expression != nil {
+			expression = fmt.Sprint(*cond.Expression)
+		}
+
+		strBuilder.WriteString(fmt.Sprintf("('%s', '%s', %d, %d, %s, '%s', %s, %s, %s),",
+			cond.TransactionHash,
+			cond.Account,
+			cond.RuleIndex,
+			cond.NodeIndex,
+			parent,
+			cond.Type,
+			expression,
+			nullableString(cond.Hash),
+			nullableString(cond.Group),
+		))
+	}
+
+	return strings.TrimSuffix(strBuilder.String(), ",")
+}
+
+func nullableString(str string) string {
+	if str == "" {
+		return "NULL"
+	}
+
+	return fmt.Sprintf("'%s'", str)
+}
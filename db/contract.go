@@ -32,6 +32,7 @@ var contractColumns = []string{
 	"`permissions`",
 	"`trusts`",
 	"`extra`",
+	"`updatehistory`",
 }
 
 // InsertNativeContract inserts native contract into database.
@@ -41,6 +42,13 @@ func InsertNativeContract(contract *models.ContractState) {
 	})
 }
 
+// UpdateNativeContract updates the persisted native contract.
+func UpdateNativeContract(contract *models.ContractState) {
+	mysql.Trans(func(sqlTx *sql.Tx) error {
+		return updateContract(sqlTx, contract, contract.Hash)
+	})
+}
+
 // InsertContract inserts contract state into database.
 func InsertContract(contract *models.ContractState, notiPK uint, contractHash string, newAsset *models.Asset) {
 	mysql.Trans(func(sqlTx *sql.Tx) error {
@@ -130,6 +138,7 @@ func insertContract(sqlTx *sql.Tx, contract *models.ContractState) error {
 		contract.Manifest.Permissions,
 		contract.Manifest.Trusts,
 		contract.Manifest.Extra,
+		contract.MarshalUpdateHistory(),
 	}
 
 	_, err := sqlTx.Exec(mysql.Compose(query), args...)
@@ -162,7 +171,8 @@ func updateContract(sqlTx *sql.Tx, contract *models.ContractState, contractHash
 		"`abi` = ?,",
 		"`permissions` = ?,",
 		"`trusts` = ?,",
-		"`extra` = ?",
+		"`extra` = ?,",
+		"`updatehistory` = ?",
 		fmt.Sprintf("WHERE `hash` = '%s'", contractHash),
 		"LIMIT 1",
 	}
@@ -188,6 +198,7 @@ func updateContract(sqlTx *sql.Tx, contract *models.ContractState, contractHash
 		contract.Manifest.Permissions,
 		contract.Manifest.Trusts,
 		contract.Manifest.Extra,
+		contract.MarshalUpdateHistory(),
 	}
 
 	_, err := sqlTx.Exec(mysql.Compose(query), args...)
@@ -219,7 +230,6 @@ func GetAllNativeContracts() []*models.ContractState {
 		fmt.Sprintf("SELECT %s", strings.Join(contractColumns, ", ")),
 		"FROM `contract`",
 		"WHERE `contract_id` <= 0",
-		"AND `block_index` = 0",
 		"ORDER BY `id` ASC",
 	}
 
@@ -258,6 +268,7 @@ func getContractQueryRow(query []string) *models.ContractState {
 	var contract models.ContractState
 	supportedStandards := []byte{}
 	abi := []byte{}
+	updateHistory := []byte{}
 
 	err := mysql.QueryRow(mysql.Compose(query), nil,
 		&contract.ID,
@@ -282,6 +293,7 @@ func getContractQueryRow(query []string) *models.ContractState {
 		&contract.Manifest.Permissions,
 		&contract.Manifest.Trusts,
 		&contract.Manifest.Extra,
+		&updateHistory,
 	)
 
 	if err != nil {
@@ -295,6 +307,7 @@ func getContractQueryRow(query []string) *models.ContractState {
 
 	contract.UnmarshalSupportedStandards(supportedStandards)
 	contract.UnmarshalABI(abi)
+	contract.UnmarshalUpdateHistory(updateHistory)
 
 	return &contract
 }
@@ -314,6 +327,7 @@ func getContractQuery(query []string) []*models.ContractState {
 		var contract models.ContractState
 		supportedStandards := []byte{}
 		abi := []byte{}
+		updateHistory := []byte{}
 
 		err := rows.Scan(
 			&contract.ID,
@@ -338,6 +352,7 @@ func getContractQuery(query []string) []*models.ContractState {
 			&contract.Manifest.Permissions,
 			&contract.Manifest.Trusts,
 			&contract.Manifest.Extra,
+			&updateHistory,
 		)
 		if err != nil {
 			log.Panic(err)
@@ -345,6 +360,7 @@ func getContractQuery(query []string) []*models.ContractState {
 
 		contract.UnmarshalSupportedStandards(supportedStandards)
 		contract.UnmarshalABI(abi)
+		contract.UnmarshalUpdateHistory(updateHistory)
 
 		contracts = append(contracts, &contract)
 	}
@@ -15,6 +15,9 @@ type Counter struct {
 	ContractNotiPK uint
 
 	AddrCount uint
+	Network   uint32
+	// AppLogStart is the block the applog sync started from, -1 if not started.
+	AppLogStart int
 }
 
 /* ------------------------------
@@ -31,6 +34,41 @@ func GetContractNotiPK() uint {
 	return getCounterInstance().ContractNotiPK
 }
 
+// GetNetwork returns the network magic of persisted data, 0 if not set yet.
+func GetNetwork() uint32 {
+	return getCounterInstance().Network
+}
+
+// SetNetwork sets the network magic of persisted data if not set yet.
+func SetNetwork(magic uint32) {
+	query := []string{
+		"UPDATE `counter`",
+		fmt.Sprintf("SET `network` = %d", magic),
+		"WHERE `id` = 1 AND `network` = 0",
+		"LIMIT 1",
+	}
+
+	_, err := mysql.Exec(mysql.Compose(query))
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// SetAppLogStart sets the block the applog sync started from if not set yet.
+func SetAppLogStart(blockIndex uint) {
+	query := []string{
+		"UPDATE `counter`",
+		fmt.Sprintf("SET `app_log_start` = %d", blockIndex),
+		"WHERE `id` = 1 AND `app_log_start` = -1",
+		"LIMIT 1",
+	}
+
+	_, err := mysql.Exec(mysql.Compose(query))
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
 // UpdateContractNotiPK updates `contract_noti_pk` counter.
 func UpdateContractNotiPK(pk uint) error {
 	return mysql.Trans(func(sqlTx *sql.Tx) error {
@@ -40,7 +78,7 @@ func UpdateContractNotiPK(pk uint) error {
 
 func getCounterInstance() Counter {
 	query := []string{
-		"SELECT `id`, `block_index`, `contract_noti_pk`, `addr_count`",
+		"SELECT `id`, `block_index`, `contract_noti_pk`, `addr_count`, `network`, `app_log_start`",
 		"FROM `counter`",
 		"WHERE `id` = 1",
 		"LIMIT 1",
@@ -53,6 +91,8 @@ func getCounterInstance() Counter {
 		&counter.BlockIndex,
 		&counter.ContractNotiPK,
 		&counter.AddrCount,
+		&counter.Network,
+		&counter.AppLogStart,
 	)
 
 	if err != nil {
@@ -0,0 +1,205 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"neo3-squirrel/models"
+	"neo3-squirrel/pkg/mysql"
+	"neo3-squirrel/util/log"
+	"strings"
+)
+
+// AppLogSource is a block or transaction whose
+// application log may contain notifications.
+type AppLogSource struct {
+	BlockIndex uint
+	BlockTime  uint64
+	Hash       string
+}
+
+// GetBlockIndexes returns persisted block indexes within [from, to] in order.
+func GetBlockIndexes(from, to uint) []uint {
+	query := []string{
+		"SELECT `index`",
+		"FROM `block`",
+		fmt.Sprintf("WHERE `index` BETWEEN %d AND %d", from, to),
+		"ORDER BY `index` ASC",
+	}
+
+	return queryIndexes(query)
+}
+
+// GetIncompleteTxBlocks returns indexes of blocks within [from, to]
+// which have fewer transactions persisted than they contain.
+func GetIncompleteTxBlocks(from, to uint) []uint {
+	subQuery := []string{
+		"SELECT `block_index`, COUNT(`id`) `cnt`",
+		"FROM `transaction`",
+		fmt.Sprintf("WHERE `block_index` BETWEEN %d AND %d", from, to),
+		"GROUP BY `block_index`",
+	}
+
+	query := []string{
+		"SELECT `block`.`index`",
+		"FROM `block`",
+		fmt.Sprintf("LEFT JOIN (%s) `t`", mysql.Compose(subQuery)),
+		"ON `t`.`block_index` = `block`.`index`",
+		fmt.Sprintf("WHERE `block`.`index` BETWEEN %d AND %d", from, to),
+		"AND `block`.`txs` != IFNULL(`t`.`cnt`, 0)",
+		"ORDER BY `block`.`index` ASC",
+	}
+
+	return queryIndexes(query)
+}
+
+// GetAppLogSourcesWithoutNotis returns blocks and transactions within
+// [from, to] whose application logs were never saved. Sources from the
+// block the applog sync started from were all saved by the sync, those
+// without notification persisted simply have no notification.
+func GetAppLogSourcesWithoutNotis(from, to uint) []*AppLogSource {
+	sources := []*AppLogSource{}
+
+	if start := getCounterInstance().AppLogStart; start >= 0 {
+		if from >= uint(start) {
+			return sources
+		}
+
+		if to >= uint(start) {
+			to = uint(start) - 1
+		}
+	}
+
+	query := []string{
+		"SELECT `index`, `time`, `hash`",
+		"FROM `block`",
+		fmt.Sprintf("WHERE `index` BETWEEN %d AND %d", from, to),
+		"AND NOT EXISTS (SELECT `id` FROM `notification` WHERE `notification`.`hash` = `block`.`hash`)",
+		"UNION ALL",
+		"SELECT `block_index`, `block_time`, `hash`",
+		"FROM `transaction`",
+		fmt.Sprintf("WHERE `block_index` BETWEEN %d AND %d", from, to),
+		"AND NOT EXISTS (SELECT `id` FROM `notification` WHERE `notification`.`hash` = `transaction`.`hash`)",
+	}
+
+	rows, err := mysql.Query(mysql.Compose(query))
+	if err != nil {
+		log.Error(mysql.Compose(query))
+		log.Panic(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var src AppLogSource
+		if err := rows.Scan(&src.BlockIndex, &src.BlockTime, &src.Hash); err != nil {
+			log.Panic(err)
+		}
+
+		sources = append(sources, &src)
+	}
+
+	return sources
+}
+
+// InsertMissingBlocks inserts blocks missing from database
+// without moving the block index counter.
+func InsertMissingBlocks(blocks []*models.Block, txBulk *models.TxBulk) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	return mysql.Trans(func(sqlTx *sql.Tx) error {
+		return insertBlocks(sqlTx, blocks, txBulk)
+	})
+}
+
+// ReplaceBlockTxs replaces all transactions of the given blocks.
+func ReplaceBlockTxs(blockIndexes []uint, txBulk *models.TxBulk) error {
+	if len(blockIndexes) == 0 {
+		return nil
+	}
+
+	indexes := make([]string, len(blockIndexes))
+	for i, index := range blockIndexes {
+		indexes[i] = fmt.Sprint(index)
+	}
+
+	inBlocks := fmt.Sprintf("`transaction`.`block_index` IN (%s)", strings.Join(indexes, ", "))
+
+	cmds := []string{
+		generateInsertCmdForTxs(txBulk.Txs),
+		generateInsertCmdForTxSigners(txBulk.TxSigners),
+		generateInsertCmdForTxAttrs(txBulk.TxAttrs),
+		generateInsertCmdForTxWitnesses(txBulk.TxWitnesses),
+		generateInsertCmdForTxSignerWitnesses(txBulk.TxSignerWitnesses),
+		generateInsertCmdForPubKeys(txBulk.PubKeys),
+	}
+	cmds = append(cmds, generateInsertCmdsForTxSignerScopes(txBulk)...)
+	cmds = append(cmds, generateInsertCmdsForMultiSigAccounts(txBulk.MultiSigAccounts)...)
+
+	return mysql.Trans(func(sqlTx *sql.Tx) error {
+		for _, table := range txChildTables {
+			query := []string{
+				fmt.Sprintf("DELETE `%s` FROM `%s`", table, table),
+				fmt.Sprintf("JOIN `transaction` ON `%s`.`transaction_hash` = `transaction`.`hash`", table),
+				fmt.Sprintf("WHERE %s", inBlocks),
+			}
+
+			if _, err := sqlTx.Exec(mysql.Compose(query)); err != nil {
+				log.Error(err)
+				return err
+			}
+		}
+
+		query := []string{
+			"DELETE FROM `transaction`",
+			fmt.Sprintf("WHERE %s", inBlocks),
+		}
+
+		result, err := sqlTx.Exec(mysql.Compose(query))
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+
+		for _, cmd := range cmds {
+			if cmd == "" {
+				continue
+			}
+			if _, err := sqlTx.Exec(cmd); err != nil {
+				log.Error(err)
+				return err
+			}
+		}
+
+		return updateTxCounter(sqlTx, len(txBulk.Txs)-int(deleted))
+	})
+}
+
+func queryIndexes(query []string) []uint {
+	rows, err := mysql.Query(mysql.Compose(query))
+	if err != nil {
+		log.Error(mysql.Compose(query))
+		log.Panic(err)
+	}
+
+	defer rows.Close()
+
+	indexes := []uint{}
+	for rows.Next() {
+		var index uint
+		if err := rows.Scan(&index); err != nil {
+			log.Panic(err)
+		}
+
+		indexes = append(indexes, index)
+	}
+
+	return indexes
+}
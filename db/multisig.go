@@ -0,0 +1,147 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"neo3-squirrel/models"
+	"neo3-squirrel/pkg/mysql"
+	"neo3-squirrel/util/log"
+	"strings"
+)
+
+// InsertMultiSigAccounts registers multi-signature accounts
+// and their members, registered ones are ignored.
+func InsertMultiSigAccounts(accounts []*models.MultiSigAccount) error {
+	cmds := generateInsertCmdsForMultiSigAccounts(accounts)
+	if len(cmds) == 0 {
+		return nil
+	}
+
+	return mysql.Trans(func(sqlTx *sql.Tx) error {
+		for _, cmd := range cmds {
+			if _, err := sqlTx.Exec(cmd); err != nil {
+				log.Error(err)
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func generateInsertCmdsForMultiSigAccounts(accounts []*models.MultiSigAccount) []string {
+	if len(accounts) == 0 {
+		return nil
+	}
+
+	var accountsBuilder, membersBuilder strings.Builder
+	accountsBuilder.WriteString("INSERT IGNORE INTO `multisig` (`address`, `script_hash`, `threshold`, `members`) VALUES ")
+	membersBuilder.WriteString("INSERT IGNORE INTO `multisig_member` (`multisig`, `pubkey`, `address`) VALUES ")
+
+	for _, account := range accounts {
+		accountsBuilder.WriteString(fmt.Sprintf("('%s', '%s', %d, %d),",
+			account.Address,
+			account.ScriptHash,
+			account.Threshold,
+			len(account.Members),
+		))
+
+		for _, member := range account.Members {
+			membersBuilder.WriteString(fmt.Sprintf("('%s', '%s', '%s'),",
+				account.Address,
+				member.PubKey,
+				member.Address,
+			))
+		}
+	}
+
+	return []string{
+		strings.TrimSuffix(accountsBuilder.String(), ","),
+		strings.TrimSuffix(membersBuilder.String(), ","),
+	}
+}
+
+// GetMultiSigAccount returns the registered multi-signature
+// account of the address, or nil if not registered.
+func GetMultiSigAccount(address string) *models.MultiSigAccount {
+	query := []string{
+		"SELECT `address`, `script_hash`, `threshold`",
+		"FROM `multisig`",
+		fmt.Sprintf("WHERE `address` = '%s'", address),
+		"LIMIT 1",
+	}
+
+	account := models.MultiSigAccount{}
+	err := mysql.QueryRow(mysql.Compose(query), nil,
+		&account.Address,
+		&account.ScriptHash,
+		&account.Threshold,
+	)
+	if err != nil {
+		if mysql.IsRecordNotFoundError(err) {
+			return nil
+		}
+
+		log.Error(mysql.Compose(query))
+		log.Panic(err)
+	}
+
+	query = []string{
+		"SELECT `pubkey`, `address`",
+		"FROM `multisig_member`",
+		fmt.Sprintf("WHERE `multisig` = '%s'", address),
+		"ORDER BY `id` ASC",
+	}
+
+	rows, err := mysql.Query(mysql.Compose(query))
+	if err != nil {
+		log.Error(mysql.Compose(query))
+		log.Panic(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var member models.MultiSigMember
+		if err := rows.Scan(&member.PubKey, &member.Address); err != nil {
+			log.Panic(err)
+		}
+
+		account.Members = append(account.Members, member)
+	}
+
+	return &account
+}
+
+// GetWitnessVerifications returns up to limit verification scripts of
+// the witness table with id greater than fromID, and the last id read.
+func GetWitnessVerifications(table string, fromID uint, limit int) ([]string, uint) {
+	query := []string{
+		"SELECT `id`, `verification`",
+		fmt.Sprintf("FROM `%s`", table),
+		fmt.Sprintf("WHERE `id` > %d", fromID),
+		"ORDER BY `id` ASC",
+		fmt.Sprintf("LIMIT %d", limit),
+	}
+
+	rows, err := mysql.Query(mysql.Compose(query))
+	if err != nil {
+		log.Error(mysql.Compose(query))
+		log.Panic(err)
+	}
+
+	defer rows.Close()
+
+	verifications := []string{}
+	lastID := fromID
+	for rows.Next() {
+		var verification string
+		if err := rows.Scan(&lastID, &verification); err != nil {
+			log.Panic(err)
+		}
+
+		verifications = append(verifications, verification)
+	}
+
+	return verifications, lastID
+}
@@ -104,12 +104,13 @@ func generateNotificationInsertCmd(tableName string, notiCount int) string {
 	return strBuilder.String()
 }
 
-// GetLastNotification returns the last notification record.
+// GetLastNotification returns the last notification record
+// of the highest block, backfilled ones may have larger ids.
 func GetLastNotification() *models.Notification {
 	query := []string{
 		fmt.Sprintf("SELECT %s", strings.Join(appLogNotiColumns, ", ")),
 		"FROM `notification`",
-		"ORDER BY `id` DESC",
+		"ORDER BY `block_index` DESC, `id` DESC",
 		"LIMIT 1",
 	}
 
@@ -129,6 +130,32 @@ func GetContractNotifications(startPK, limit uint) []*models.Notification {
 	return getAppLogNotiQuery(query)
 }
 
+// GetContractNotificationsAbove returns contract
+// notifications above the given block index.
+func GetContractNotificationsAbove(blockIndex uint) []*models.Notification {
+	query := []string{
+		fmt.Sprintf("SELECT %s", strings.Join(appLogNotiColumns, ", ")),
+		"FROM `contract_notification`",
+		fmt.Sprintf("WHERE `block_index` > %d", blockIndex),
+		"ORDER BY `id` ASC",
+	}
+
+	return getAppLogNotiQuery(query)
+}
+
+// GetContractNotificationsTill returns contract notifications
+// at or below the given block index, the latest first.
+func GetContractNotificationsTill(blockIndex uint) []*models.Notification {
+	query := []string{
+		fmt.Sprintf("SELECT %s", strings.Join(appLogNotiColumns, ", ")),
+		"FROM `contract_notification`",
+		fmt.Sprintf("WHERE `block_index` <= %d", blockIndex),
+		"ORDER BY `block_index` DESC, `id` DESC",
+	}
+
+	return getAppLogNotiQuery(query)
+}
+
 // GetLastNotiForNEP17Task returns the last notification
 // of the NEP17 transfer record.
 func GetLastNotiForNEP17Task() *models.Notification {
@@ -0,0 +1,493 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"neo3-squirrel/cache/native"
+	"neo3-squirrel/models"
+	"neo3-squirrel/pkg/mysql"
+	"neo3-squirrel/util/log"
+	"strings"
+)
+
+// RollbackResult records cached entries removed by a rollback.
+type RollbackResult struct {
+	Addresses []string
+	Assets    []string
+	// Balances changed above the rollback height, which must be
+	// queried again as not all changes have transfer records.
+	// map[contract][]address
+	Balances map[string][]string
+}
+
+// Rollback removes all blocks above the given height and reverts every
+// derived table to the state of that height in one db transaction.
+// deployedContracts are contracts deployed above the given height,
+// revertedContracts are states as of the height of contracts
+// updated or destroyed above it.
+func Rollback(height uint, deployedContracts []string, revertedContracts []*models.ContractState) *RollbackResult {
+	var result *RollbackResult
+
+	mysql.Trans(func(sqlTx *sql.Tx) error {
+		var err error
+		result, err = rollback(sqlTx, height, deployedContracts, revertedContracts)
+		return err
+	})
+
+	return result
+}
+
+func rollback(sqlTx *sql.Tx, height uint, deployedContracts []string, revertedContracts []*models.ContractState) (*RollbackResult, error) {
+	result := RollbackResult{}
+
+	affectedAddrs, err := getTransferAddrsAbove(sqlTx, height)
+	if err != nil {
+		return nil, err
+	}
+
+	affectedAssets, err := getTransferContractsAbove(sqlTx, height)
+	if err != nil {
+		return nil, err
+	}
+
+	changedBalances, err := getBalanceChangesAbove(sqlTx, height)
+	if err != nil {
+		return nil, err
+	}
+
+	// Balances are reverted from transfers,
+	// so transfers must be deleted after them.
+	if err := rollbackAddrAssets(sqlTx, height); err != nil {
+		return nil, err
+	}
+
+	if err := execDelete(sqlTx, "transfer", height); err != nil {
+		return nil, err
+	}
+
+	if err := recountAddrAssetTransfers(sqlTx, affectedAddrs); err != nil {
+		return nil, err
+	}
+
+	if err := recountAssets(sqlTx, affectedAssets); err != nil {
+		return nil, err
+	}
+
+	result.Addresses, err = rollbackAddresses(sqlTx, affectedAddrs)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Assets, err = rollbackAssets(sqlTx, height)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Balances = filterBalanceChanges(changedBalances, result.Addresses, result.Assets)
+
+	if err := rollbackContracts(sqlTx, height, deployedContracts); err != nil {
+		return nil, err
+	}
+
+	if err := revertContracts(sqlTx, revertedContracts); err != nil {
+		return nil, err
+	}
+
+	for _, table := range []string{"notification", "contract_notification", "pubkey"} {
+		if err := execDelete(sqlTx, table, height); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rollbackTransactions(sqlTx, height); err != nil {
+		return nil, err
+	}
+
+	if err := rollbackBlocks(sqlTx, height); err != nil {
+		return nil, err
+	}
+
+	if err := resetCounter(sqlTx, height); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func rollbackAddrAssets(sqlTx *sql.Tx, height uint) error {
+	// Senders get the amount back, receivers lose it.
+	query := []string{
+		"UPDATE `addr_asset` JOIN (",
+		"SELECT `addr`, `contract`, SUM(`amount`) `amount` FROM (",
+		"SELECT `from` `addr`, `contract`, IF(`from` = `to`, 0, `amount`) `amount`",
+		"FROM `transfer`",
+		fmt.Sprintf("WHERE `block_index` > %d AND `from` <> ''", height),
+		"UNION ALL",
+		"SELECT `to` `addr`, `contract`, -`amount` `amount`",
+		"FROM `transfer`",
+		fmt.Sprintf("WHERE `block_index` > %d AND `to` <> '' AND `to` <> `from`", height),
+		") `tbl` GROUP BY `addr`, `contract`",
+		") `delta`",
+		"ON `addr_asset`.`address` = `delta`.`addr` AND `addr_asset`.`contract` = `delta`.`contract`",
+		"SET `addr_asset`.`balance` = GREATEST(`addr_asset`.`balance` + `delta`.`amount`, 0)",
+	}
+
+	_, err := sqlTx.Exec(mysql.Compose(query))
+	if err != nil {
+		log.Error(err)
+	}
+
+	return err
+}
+
+// recountAddrAssetTransfers recounts transfers of the given addresses.
+func recountAddrAssetTransfers(sqlTx *sql.Tx, addrs []string) error {
+	if len(addrs) == 0 {
+		return nil
+	}
+
+	query := []string{
+		"UPDATE `addr_asset`",
+		"SET `transfers` = (",
+		"SELECT COUNT(`id`) FROM `transfer`",
+		"WHERE `transfer`.`contract` = `addr_asset`.`contract`",
+		"AND (`transfer`.`from` = `addr_asset`.`address` OR `transfer`.`to` = `addr_asset`.`address`)",
+		")",
+		fmt.Sprintf("WHERE `address` IN (%s)", quoteList(addrs)),
+	}
+
+	_, err := sqlTx.Exec(mysql.Compose(query))
+	if err != nil {
+		log.Error(err)
+	}
+
+	return err
+}
+
+// recountAssets recounts holding addresses and transfers of the given assets.
+func recountAssets(sqlTx *sql.Tx, assets []string) error {
+	if len(assets) == 0 {
+		return nil
+	}
+
+	query := []string{
+		"UPDATE `asset`",
+		"SET `addresses` = (",
+		"SELECT COUNT(`id`) FROM `addr_asset`",
+		"WHERE `addr_asset`.`contract` = `asset`.`contract` AND `addr_asset`.`balance` > 0",
+		"), `transfers` = (",
+		"SELECT COUNT(`id`) FROM `transfer`",
+		"WHERE `transfer`.`contract` = `asset`.`contract`",
+		")",
+		fmt.Sprintf("WHERE `contract` IN (%s)", quoteList(assets)),
+	}
+
+	_, err := sqlTx.Exec(mysql.Compose(query))
+	if err != nil {
+		log.Error(err)
+	}
+
+	return err
+}
+
+func getTransferContractsAbove(sqlTx *sql.Tx, height uint) ([]string, error) {
+	query := []string{
+		"SELECT DISTINCT `contract` FROM `transfer`",
+		fmt.Sprintf("WHERE `block_index` > %d", height),
+	}
+
+	return queryStrings(sqlTx, query)
+}
+
+func getTransferAddrsAbove(sqlTx *sql.Tx, height uint) ([]string, error) {
+	query := []string{
+		"SELECT `from` `addr` FROM `transfer`",
+		fmt.Sprintf("WHERE `block_index` > %d AND `from` <> ''", height),
+		"UNION",
+		"SELECT `to` `addr` FROM `transfer`",
+		fmt.Sprintf("WHERE `block_index` > %d AND `to` <> ''", height),
+	}
+
+	return queryStrings(sqlTx, query)
+}
+
+// getBalanceChangesAbove returns balances changed above the given height,
+// including GAS of transaction senders paying fees.
+func getBalanceChangesAbove(sqlTx *sql.Tx, height uint) (map[string][]string, error) {
+	query := []string{
+		"SELECT `from` `addr`, `contract` FROM `transfer`",
+		fmt.Sprintf("WHERE `block_index` > %d AND `from` <> ''", height),
+		"UNION",
+		"SELECT `to` `addr`, `contract` FROM `transfer`",
+		fmt.Sprintf("WHERE `block_index` > %d AND `to` <> ''", height),
+		"UNION",
+		fmt.Sprintf("SELECT `sender` `addr`, '%s' `contract` FROM `transaction`", native.GAS()),
+		fmt.Sprintf("WHERE `block_index` > %d", height),
+	}
+
+	rows, err := sqlTx.Query(mysql.Compose(query))
+	if err != nil {
+		log.Error(mysql.Compose(query))
+		log.Error(err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	balances := map[string][]string{}
+	for rows.Next() {
+		var addr, contract string
+		if err := rows.Scan(&addr, &contract); err != nil {
+			log.Error(err)
+			return nil, err
+		}
+
+		balances[contract] = append(balances[contract], addr)
+	}
+
+	return balances, rows.Err()
+}
+
+// filterBalanceChanges drops balances of removed addresses and assets.
+func filterBalanceChanges(balances map[string][]string, removedAddrs, removedAssets []string) map[string][]string {
+	removed := map[string]bool{}
+	for _, addr := range removedAddrs {
+		removed[addr] = true
+	}
+	for _, contract := range removedAssets {
+		removed[contract] = true
+	}
+
+	filtered := map[string][]string{}
+	for contract, addrs := range balances {
+		if removed[contract] {
+			continue
+		}
+
+		for _, addr := range addrs {
+			if !removed[addr] {
+				filtered[contract] = append(filtered[contract], addr)
+			}
+		}
+	}
+
+	return filtered
+}
+
+// rollbackAddresses recalculates first and last tx time of the given
+// addresses, addresses without any transfer left will be deleted.
+func rollbackAddresses(sqlTx *sql.Tx, addrs []string) ([]string, error) {
+	if len(addrs) == 0 {
+		return nil, nil
+	}
+
+	addrList := quoteList(addrs)
+
+	query := []string{
+		"UPDATE `address` JOIN (",
+		"SELECT `addr`, MIN(`block_time`) `first_tx_time`, MAX(`block_time`) `last_tx_time` FROM (",
+		"SELECT `from` `addr`, `block_time` FROM `transfer`",
+		fmt.Sprintf("WHERE `from` IN (%s)", addrList),
+		"UNION ALL",
+		"SELECT `to` `addr`, `block_time` FROM `transfer`",
+		fmt.Sprintf("WHERE `to` IN (%s)", addrList),
+		") `tbl` GROUP BY `addr`",
+		") `times`",
+		"ON `address`.`address` = `times`.`addr`",
+		"SET `address`.`first_tx_time` = `times`.`first_tx_time`,",
+		"`address`.`last_tx_time` = `times`.`last_tx_time`",
+	}
+
+	if _, err := sqlTx.Exec(mysql.Compose(query)); err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	query = []string{
+		"SELECT `address` FROM `address`",
+		fmt.Sprintf("WHERE `address` IN (%s)", addrList),
+		"AND NOT EXISTS (SELECT `id` FROM `transfer` WHERE `from` = `address`.`address`)",
+		"AND NOT EXISTS (SELECT `id` FROM `transfer` WHERE `to` = `address`.`address`)",
+	}
+
+	removed, err := queryStrings(sqlTx, query)
+	if err != nil || len(removed) == 0 {
+		return nil, err
+	}
+
+	removedList := quoteList(removed)
+	query = []string{
+		"DELETE FROM `address`",
+		fmt.Sprintf("WHERE `address` IN (%s);", removedList),
+		"DELETE FROM `addr_asset`",
+		fmt.Sprintf("WHERE `address` IN (%s)", removedList),
+		"AND `balance` = 0 AND `transfers` = 0",
+	}
+
+	if _, err := sqlTx.Exec(mysql.Compose(query)); err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	return removed, nil
+}
+
+// rollbackAssets deletes assets first seen above the given height.
+func rollbackAssets(sqlTx *sql.Tx, height uint) ([]string, error) {
+	query := []string{
+		"SELECT `contract` FROM `asset`",
+		fmt.Sprintf("WHERE `block_index` > %d", height),
+	}
+
+	assets, err := queryStrings(sqlTx, query)
+	if err != nil || len(assets) == 0 {
+		return nil, err
+	}
+
+	for _, assetHash := range assets {
+		if err := deleteAsset(sqlTx, assetHash); err != nil {
+			return nil, err
+		}
+
+		if err := deleteAssetAddrBalances(sqlTx, assetHash); err != nil {
+			return nil, err
+		}
+	}
+
+	return assets, nil
+}
+
+func rollbackContracts(sqlTx *sql.Tx, height uint, deployedContracts []string) error {
+	if len(deployedContracts) == 0 {
+		return nil
+	}
+
+	query := []string{
+		"DELETE FROM `contract`",
+		fmt.Sprintf("WHERE `hash` IN (%s)", quoteList(deployedContracts)),
+		fmt.Sprintf("AND `block_index` > %d", height),
+	}
+
+	_, err := sqlTx.Exec(mysql.Compose(query))
+	if err != nil {
+		log.Error(err)
+	}
+
+	return err
+}
+
+// revertContracts replaces persisted contracts with their reverted states.
+func revertContracts(sqlTx *sql.Tx, contracts []*models.ContractState) error {
+	for _, contract := range contracts {
+		if err := deleteContract(sqlTx, contract.Hash); err != nil {
+			return err
+		}
+
+		if err := insertContract(sqlTx, contract); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func rollbackTransactions(sqlTx *sql.Tx, height uint) error {
+	for _, table := range txChildTables {
+		query := []string{
+			fmt.Sprintf("DELETE `%s` FROM `%s`", table, table),
+			fmt.Sprintf("JOIN `transaction` ON `%s`.`transaction_hash` = `transaction`.`hash`", table),
+			fmt.Sprintf("WHERE `transaction`.`block_index` > %d", height),
+		}
+
+		if _, err := sqlTx.Exec(mysql.Compose(query)); err != nil {
+			log.Error(err)
+			return err
+		}
+	}
+
+	return execDelete(sqlTx, "transaction", height)
+}
+
+func rollbackBlocks(sqlTx *sql.Tx, height uint) error {
+	query := []string{
+		"DELETE `block_witness` FROM `block_witness`",
+		"JOIN `block` ON `block_witness`.`block_hash` = `block`.`hash`",
+		fmt.Sprintf("WHERE `block`.`index` > %d;", height),
+		"DELETE FROM `block`",
+		fmt.Sprintf("WHERE `index` > %d", height),
+	}
+
+	_, err := sqlTx.Exec(mysql.Compose(query))
+	if err != nil {
+		log.Error(err)
+	}
+
+	return err
+}
+
+// resetCounter recounts the counter row from the rolled back tables.
+func resetCounter(sqlTx *sql.Tx, height uint) error {
+	query := []string{
+		"UPDATE `counter` SET",
+		fmt.Sprintf("`block_index` = LEAST(`block_index`, %d),", height),
+		"`contract_noti_pk` = LEAST(`contract_noti_pk`, (SELECT IFNULL(MAX(`id`), 0) FROM `contract_notification`)),",
+		"`tx_count` = (SELECT COUNT(`id`) FROM `transaction`),",
+		"`addr_count` = (SELECT COUNT(`id`) FROM `address`)",
+		"WHERE `id` = 1",
+		"LIMIT 1",
+	}
+
+	_, err := sqlTx.Exec(mysql.Compose(query))
+	if err != nil {
+		log.Error(err)
+	}
+
+	return err
+}
+
+func execDelete(sqlTx *sql.Tx, table string, height uint) error {
+	query := []string{
+		fmt.Sprintf("DELETE FROM `%s`", table),
+		fmt.Sprintf("WHERE `block_index` > %d", height),
+	}
+
+	_, err := sqlTx.Exec(mysql.Compose(query))
+	if err != nil {
+		log.Error(err)
+	}
+
+	return err
+}
+
+func queryStrings(sqlTx *sql.Tx, query []string) ([]string, error) {
+	rows, err := sqlTx.Query(mysql.Compose(query))
+	if err != nil {
+		log.Error(mysql.Compose(query))
+		log.Error(err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	values := []string{}
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			log.Error(err)
+			return nil, err
+		}
+
+		values = append(values, value)
+	}
+
+	return values, rows.Err()
+}
+
+func quoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = fmt.Sprintf("'%s'", value)
+	}
+
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,165 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"neo3-squirrel/cache/native"
+	"neo3-squirrel/models"
+	"strings"
+	"testing"
+)
+
+// recorder is a database driver recording executed statements,
+// queries return no rows.
+type recorder struct {
+	stmts []string
+}
+
+func (r *recorder) Open(string) (driver.Conn, error)      { return r, nil }
+func (r *recorder) Prepare(q string) (driver.Stmt, error) { return &recordedStmt{r, q}, nil }
+func (r *recorder) Close() error                          { return nil }
+func (r *recorder) Begin() (driver.Tx, error)             { return r, nil }
+func (r *recorder) Commit() error                         { return nil }
+func (r *recorder) Rollback() error                       { return nil }
+
+type recordedStmt struct {
+	r     *recorder
+	query string
+}
+
+func (s *recordedStmt) Close() error  { return nil }
+func (s *recordedStmt) NumInput() int { return -1 }
+
+func (s *recordedStmt) Exec([]driver.Value) (driver.Result, error) {
+	s.r.stmts = append(s.r.stmts, s.query)
+	return driver.RowsAffected(0), nil
+}
+
+func (s *recordedStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.r.stmts = append(s.r.stmts, s.query)
+	return emptyRows{}, nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string         { return []string{"value"} }
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+var rec = &recorder{}
+
+func init() {
+	sql.Register("recorder", rec)
+}
+
+func TestRollback(t *testing.T) {
+	conn, err := sql.Open("recorder", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	gas := "0xd2a4cff31913016155e38e474a2c06d08be276cf"
+	native.Register(native.GasToken, gas)
+
+	reverted := &models.ContractState{Hash: "0xef4073a0f2b305a38ec4050e4d3d28bc40ea63f5", BlockIndex: 50}
+
+	cases := []struct {
+		height            uint
+		deployedContracts []string
+		revertedContracts []*models.ContractState
+	}{
+		{0, nil, nil},
+		{100, nil, nil},
+		{100, []string{"0xd2a4cff31913016155e38e474a2c06d08be276cf"}, nil},
+		{100, nil, []*models.ContractState{reverted}},
+	}
+
+	for _, c := range cases {
+		rec.stmts = nil
+
+		sqlTx, err := conn.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := rollback(sqlTx, c.height, c.deployedContracts, c.revertedContracts); err != nil {
+			t.Fatal(err)
+		}
+		sqlTx.Commit()
+
+		above := fmt.Sprintf("`block_index` > %d", c.height)
+
+		expected := []string{
+			"DELETE FROM `transfer` WHERE " + above,
+			"DELETE FROM `notification` WHERE " + above,
+			"DELETE FROM `contract_notification` WHERE " + above,
+			"DELETE FROM `pubkey` WHERE " + above,
+			"DELETE FROM `transaction` WHERE " + above,
+			fmt.Sprintf("DELETE FROM `block` WHERE `index` > %d", c.height),
+			fmt.Sprintf("`block_index` = LEAST(`block_index`, %d)", c.height),
+			// GAS of transaction senders is changed by fees without transfers.
+			fmt.Sprintf("SELECT `sender` `addr`, '%s' `contract` FROM `transaction` WHERE %s", gas, above),
+		}
+
+		// Child rows of transactions are deleted before transactions.
+		for _, table := range append(txChildTables, "block_witness") {
+			expected = append(expected, fmt.Sprintf("DELETE `%s` FROM `%s`", table, table))
+		}
+
+		if len(c.deployedContracts) > 0 {
+			expected = append(expected, "DELETE FROM `contract` WHERE `hash` IN ('"+c.deployedContracts[0]+"')")
+		}
+
+		// Contracts updated above the height are replaced with the reverted states.
+		for _, cs := range c.revertedContracts {
+			expected = append(expected,
+				"DELETE FROM `contract` WHERE `hash` = '"+cs.Hash+"'",
+				"INSERT INTO `contract`")
+		}
+
+		executed := strings.Join(rec.stmts, "\n")
+		for _, stmt := range expected {
+			if !strings.Contains(executed, stmt) {
+				t.Fatalf("Rollback to %d must execute %q, got:\n%s", c.height, stmt, executed)
+			}
+		}
+
+		if len(c.deployedContracts) == 0 && len(c.revertedContracts) == 0 &&
+			strings.Contains(executed, "DELETE FROM `contract`") {
+			t.Fatalf("Contracts must not be deleted without deployments above %d", c.height)
+		}
+
+		for _, table := range txChildTables {
+			stmt := fmt.Sprintf("DELETE `%s` FROM `%s`", table, table)
+			if strings.Index(executed, stmt) > strings.Index(executed, "DELETE FROM `transaction`") {
+				t.Fatalf("Rows of `%s` must be deleted before transactions", table)
+			}
+		}
+
+		// Every deletion is bounded by the rollback height.
+		for _, stmt := range rec.stmts {
+			if strings.HasPrefix(stmt, "DELETE") &&
+				!strings.Contains(stmt, fmt.Sprintf("> %d", c.height)) &&
+				!strings.Contains(stmt, "`hash` IN") &&
+				!strings.Contains(stmt, "`hash` =") &&
+				!strings.Contains(stmt, "`address` IN") {
+				t.Fatalf("Unbounded deletion in rollback to %d: %s", c.height, stmt)
+			}
+		}
+	}
+}
+
+func TestFilterBalanceChanges(t *testing.T) {
+	balances := map[string][]string{
+		"0xa": {"addr1", "addr2"},
+		"0xb": {"addr1"},
+	}
+
+	filtered := filterBalanceChanges(balances, []string{"addr2"}, []string{"0xb"})
+	if len(filtered) != 1 || len(filtered["0xa"]) != 1 || filtered["0xa"][0] != "addr1" {
+		t.Fatalf("Balances of removed addresses and assets must be dropped, got %v", filtered)
+	}
+}
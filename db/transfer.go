@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"math/big"
+	"neo3-squirrel/cache/native"
 	"neo3-squirrel/models"
 	"neo3-squirrel/pkg/mysql"
 	"neo3-squirrel/util/convert"
@@ -54,7 +55,7 @@ func InsertNEP17Transfers(transfers []*models.Transfer,
 
 		// Update GAS total supply if it changed.
 		if newGASTotalSupply != nil {
-			if err := updateContractTotalSupply(sqlTx, models.GasToken, newGASTotalSupply); err != nil {
+			if err := updateContractTotalSupply(sqlTx, native.GAS(), newGASTotalSupply); err != nil {
 				return err
 			}
 		}
@@ -230,8 +231,14 @@ func updateNEP17Balances(sqlTx *sql.Tx, addrAssets []*models.AddrAsset, updateAs
 		}
 
 		if addrAssetRec == nil {
-			insertsStrBuilder.WriteString(fmt.Sprintf(", ('%s', '%s', %s, %d)",
-				address, contract, convert.BigFloatToString(balance), newTransfers))
+			openingBalance := convert.Zero
+			if addrAsset.OpeningBalance != nil {
+				openingBalance = addrAsset.OpeningBalance
+			}
+
+			insertsStrBuilder.WriteString(fmt.Sprintf(", ('%s', '%s', %s, %d, %s)",
+				address, contract, convert.BigFloatToString(balance), newTransfers,
+				convert.BigFloatToString(openingBalance)))
 			continue
 		}
 
@@ -291,6 +298,7 @@ func getNEP17AddrAssetRecord(sqlTx *sql.Tx, address, contract string) (*models.A
 
 	var addrAsset models.AddrAsset
 	var balanceStr string
+	var openingBalanceStr string
 	row := sqlTx.QueryRow(mysql.Compose(query))
 	err := row.Scan(
 		&addrAsset.ID,
@@ -298,6 +306,7 @@ func getNEP17AddrAssetRecord(sqlTx *sql.Tx, address, contract string) (*models.A
 		&addrAsset.Contract,
 		&balanceStr,
 		&addrAsset.Transfers,
+		&openingBalanceStr,
 	)
 
 	if err != nil {
@@ -310,6 +319,7 @@ func getNEP17AddrAssetRecord(sqlTx *sql.Tx, address, contract string) (*models.A
 	}
 
 	addrAsset.Balance = convert.ToDecimal(balanceStr)
+	addrAsset.OpeningBalance = convert.ToDecimal(openingBalanceStr)
 	return &addrAsset, nil
 }
 
@@ -1,10 +1,11 @@
 module neo3-squirrel
 
-go 1.13
+go 1.14
 
 require (
 	github.com/go-errors/errors v1.1.1
 	github.com/go-sql-driver/mysql v1.5.0
+	github.com/gorilla/websocket v1.4.2
 	github.com/mr-tron/base58 v1.2.0
 	github.com/niemeyer/pretty v0.0.0-20200227124842-a10e7caefd8e // indirect
 	github.com/sirupsen/logrus v1.6.0
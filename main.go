@@ -1,13 +1,20 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"neo3-squirrel/config"
 	"neo3-squirrel/db"
+	"neo3-squirrel/rpc"
 	"neo3-squirrel/tasks"
+	"neo3-squirrel/util/color"
 	"neo3-squirrel/util/log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 var (
@@ -15,6 +22,9 @@ var (
 	pprofPort    int
 	debug        bool
 	debugSQL     bool
+	recordDir    string
+	replayDir    string
+	backfill     bool
 )
 
 func init() {
@@ -22,6 +32,9 @@ func init() {
 	flag.BoolVar(&pprofEnabled, "pprof", false, "enable pprof")
 	flag.BoolVar(&debug, "debug", false, "enable debug mode")
 	flag.BoolVar(&debugSQL, "debugsql", false, "enable sql debug mode")
+	flag.StringVar(&recordDir, "record", "", "record rpc traffic into the given directory")
+	flag.StringVar(&replayDir, "replay", "", "replay rpc traffic recorded in the given directory")
+	flag.BoolVar(&backfill, "backfill", false, "fill gaps of persisted data alongside the live sync")
 }
 
 func main() {
@@ -31,13 +44,130 @@ func main() {
 	log.SetPrefix(config.GetLabel())
 	db.Init()
 
+	if flag.Arg(0) == "rollback" {
+		rollback(flag.Args()[1:])
+		return
+	}
+
+	if flag.Arg(0) == "gaps" {
+		setTransport()
+		tasks.ScanGaps()
+		return
+	}
+
+	if flag.Arg(0) == "witnesses" {
+		tasks.VerifyWitnesses()
+		return
+	}
+
+	if flag.Arg(0) == "multisig" {
+		showMultiSig(flag.Args()[1:])
+		return
+	}
+
 	if pprofEnabled {
 		enablePProf()
 	}
 
-	tasks.Run()
+	setTransport()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+
+	go func() {
+		tasks.Run(ctx, backfill)
+		close(stopped)
+	}()
+
+	waitForShutdown(cancel, stopped)
+}
+
+// waitForShutdown stops sync tasks on SIGINT or SIGTERM, and waits for
+// them to flush pending data within the configured timeout.
+// A second signal exits immediately.
+func waitForShutdown(cancel context.CancelFunc, stopped <-chan struct{}) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+	var sig os.Signal
+	select {
+	case sig = <-sigs:
+	case <-stopped:
+		// All tasks reached the configured end height.
+		cancel()
+		log.Info(color.BGreen("All sync tasks finished"))
+		return
+	}
+
+	timeout := config.GetShutdownTimeout()
+	log.Info(color.BYellowf("Received %s, stopping sync tasks (timeout %s)", sig, timeout))
+	cancel()
 
-	select {}
+	select {
+	case <-stopped:
+		log.Info(color.BGreen("All sync tasks stopped"))
+	case <-time.After(timeout):
+		log.Error(color.BRed("Timed out waiting for sync tasks to stop"))
+		os.Exit(1)
+	case sig := <-sigs:
+		log.Error(color.BRedf("Received %s again, exit immediately", sig))
+		os.Exit(1)
+	}
+}
+
+// rollback handles `rollback --to-height N`.
+// Stop the running syncer before rolling back.
+func rollback(args []string) {
+	fs := flag.NewFlagSet("rollback", flag.ExitOnError)
+	toHeight := fs.Int("to-height", -1, "block height to roll back to")
+	fs.Parse(args)
+
+	if *toHeight < 0 {
+		log.Fatal("rollback requires a non-negative --to-height")
+	}
+
+	tasks.Rollback(uint(*toHeight))
+}
+
+// showMultiSig handles `multisig ADDRESS` and `multisig rebuild`.
+func showMultiSig(args []string) {
+	if len(args) != 1 {
+		log.Fatal("multisig requires an address, or 'rebuild' to rebuild the registry")
+	}
+
+	if args[0] == "rebuild" {
+		tasks.RebuildMultiSigRegistry()
+		return
+	}
+
+	tasks.ShowMultiSigAccount(args[0])
+}
+
+// setTransport records or replays rpc traffic if required.
+func setTransport() {
+	if recordDir != "" && replayDir != "" {
+		log.Fatal("-record and -replay cannot be used together")
+	}
+
+	if recordDir != "" {
+		t, err := rpc.NewRecordTransport(rpc.NewHTTPTransport(), recordDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		rpc.SetTransport(t)
+		log.Infof("Recording rpc traffic into %s", recordDir)
+	}
+
+	if replayDir != "" {
+		t, err := rpc.NewReplayTransport(replayDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		rpc.SetTransport(t)
+		log.Infof("Replaying rpc traffic recorded in %s", replayDir)
+	}
 }
 
 func enablePProf() {
@@ -26,4 +26,7 @@ type AddrAsset struct {
 	Contract  string
 	Balance   *big.Float
 	Transfers int
+	// OpeningBalance is the balance before the sync start height,
+	// only set for syncs started in the middle of the chain.
+	OpeningBalance *big.Float
 }
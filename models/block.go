@@ -25,6 +25,11 @@ func (block *Block) GetTxs() []*Transaction {
 	return block.txs
 }
 
+// MemSize estimates the memory in bytes held by the block and its transactions.
+func (block *Block) MemSize() int64 {
+	return rpc.EstimateMemSize(block.Size, len(block.txs))
+}
+
 // SetTxs sets transactions for the current block.
 func (block *Block) SetTxs(txs []*Transaction) {
 	block.txs = txs
@@ -6,34 +6,6 @@ import (
 	"neo3-squirrel/util/log"
 )
 
-// Neo3 Native Contracts
-const (
-	ContractManagement = "0xfffdc93764dbaddd97c48f252a53ea4643faa3fd"
-	StdLib             = "0xacce6fd80d44e1796aa0c2c625e9e4e0ce39efc0"
-	CryptoLib          = "0x726cb6e0cd8628a1350a611384688911ab75f51b"
-	LedgerContract     = "0xda65b600f7124ce6c79950c1772a36403104f2be"
-	NeoToken           = "0xef4073a0f2b305a38ec4050e4d3d28bc40ea63f5"
-	GasToken           = "0xd2a4cff31913016155e38e474a2c06d08be276cf"
-	PolicyContract     = "0xcc5e4edd9f5f8dba8bb65734541df7a1c081c67b"
-	RoleManagement     = "0x49cf4e5378ffcd4dec034fd98a174c5491e395e2"
-	OracleContract     = "0xfe924b7cfe89ddd271abaf7210a80a7e11178758"
-)
-
-// NativeContractHashes returns all 6 Neo3 native contracts.
-func NativeContractHashes() []string {
-	return []string{
-		ContractManagement,
-		StdLib,
-		CryptoLib,
-		LedgerContract,
-		NeoToken,
-		GasToken,
-		PolicyContract,
-		RoleManagement,
-		OracleContract,
-	}
-}
-
 // EventName defines notification event name type.
 type EventName string
 
@@ -58,6 +30,7 @@ type ContractState struct {
 	State         string
 	Script        string
 	Manifest      ContractManifest
+	UpdateHistory []uint
 }
 
 type NEF struct {
@@ -124,6 +97,35 @@ func (cs *ContractState) MarshalSupportedStandards() []byte {
 	return marshalField(cs.Manifest.SupportedStandards)
 }
 
+// ParseNativeContractState parses struct *rpc.NativeContractState to *models.ContractState.
+func ParseNativeContractState(blockIndex uint, blockTime uint64, rawCS *rpc.NativeContractState) *ContractState {
+	if rawCS == nil {
+		return nil
+	}
+
+	cs := ParseContractState(blockIndex, blockTime, "", "", &rawCS.ContractState)
+	cs.UpdateHistory = rawCS.UpdateHistory
+
+	return cs
+}
+
+// MarshalUpdateHistory is the shortcut of json.Marshal(cs.UpdateHistory).
+func (cs *ContractState) MarshalUpdateHistory() []byte {
+	if cs.UpdateHistory == nil {
+		return []byte("[]")
+	}
+
+	return marshalField(cs.UpdateHistory)
+}
+
+// UnmarshalUpdateHistory is the shortcut of json.Unmarshal(cs.UpdateHistory).
+func (cs *ContractState) UnmarshalUpdateHistory(updateHistory []byte) {
+	err := json.Unmarshal(updateHistory, &cs.UpdateHistory)
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
 // MarshalABI is the shortcut of json.Marshal(cs.Manifest.ABI).
 func (cs *ContractState) MarshalABI() []byte {
 	return marshalField(cs.Manifest.ABI)
@@ -0,0 +1,15 @@
+package models
+
+// MultiSigAccount is an m-of-n multi-signature account.
+type MultiSigAccount struct {
+	Address    string
+	ScriptHash string
+	Threshold  int
+	Members    []MultiSigMember
+}
+
+// MultiSigMember is a member key of a multi-signature account.
+type MultiSigMember struct {
+	PubKey  string
+	Address string
+}
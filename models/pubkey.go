@@ -0,0 +1,9 @@
+package models
+
+// PubKey links a standard account address to its public key.
+type PubKey struct {
+	Address string
+	PubKey  string
+	// BlockIndex is the block the address first signed in.
+	BlockIndex uint
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"neo3-squirrel/rpc"
+	"neo3-squirrel/util/log"
+	"strings"
+)
+
+// Witness condition types.
+const (
+	ConditionBoolean          = "Boolean"
+	ConditionNot              = "Not"
+	ConditionAnd              = "And"
+	ConditionOr               = "Or"
+	ConditionScriptHash       = "ScriptHash"
+	ConditionGroup            = "Group"
+	ConditionCalledByEntry    = "CalledByEntry"
+	ConditionCalledByContract = "CalledByContract"
+	ConditionCalledByGroup    = "CalledByGroup"
+)
+
+// TransactionSignerContract is a contract allowed by the CustomContracts scope.
+type TransactionSignerContract struct {
+	TransactionHash string
+	Account         string
+	Contract        string
+}
+
+// TransactionSignerGroup is a group allowed by the CustomGroups scope.
+type TransactionSignerGroup struct {
+	TransactionHash string
+	Account         string
+	Group           string
+}
+
+// TransactionSignerRule is a witness rule of the WitnessRules scope.
+type TransactionSignerRule struct {
+	TransactionHash string
+	Account         string
+	RuleIndex       int
+	Action          string
+	// Condition is nil if the raw condition is malformed.
+	Condition *WitnessCondition
+}
+
+// TransactionSignerCondition is a node of a witness rule condition tree,
+// the root node of each rule has no parent.
+type TransactionSignerCondition struct {
+	TransactionHash string
+	Account         string
+	RuleIndex       int
+	NodeIndex       int
+	ParentIndex     int
+	*WitnessCondition
+}
+
+// WitnessCondition is the typed witness rule condition tree.
+type WitnessCondition struct {
+	Type string `json:"type"`
+	// Expression is the value of Boolean conditions.
+	Expression *bool `json:"expression,omitempty"`
+	// Expressions are the operands of Not, And and Or conditions.
+	Expressions []*WitnessCondition `json:"expressions,omitempty"`
+	Hash        string              `json:"hash,omitempty"`
+	Group       string              `json:"group,omitempty"`
+}
+
+// ParseWitnessCondition converts the raw condition into a condition tree.
+func ParseWitnessCondition(raw rpc.WitnessCondition) (*WitnessCondition, error) {
+	cond := WitnessCondition{Type: raw.Type}
+
+	switch raw.Type {
+	case ConditionBoolean:
+		// Expression may be encoded as a bool or a string.
+		var value bool
+		switch strings.Trim(strings.ToLower(string(raw.Expression)), `"`) {
+		case "true":
+			value = true
+		case "false":
+		default:
+			return nil, fmt.Errorf("invalid boolean condition %s", string(raw.Expression))
+		}
+
+		cond.Expression = &value
+	case ConditionNot:
+		inner := rpc.WitnessCondition{}
+		if err := json.Unmarshal(raw.Expression, &inner); err != nil {
+			return nil, err
+		}
+
+		expr, err := ParseWitnessCondition(inner)
+		if err != nil {
+			return nil, err
+		}
+
+		cond.Expressions = []*WitnessCondition{expr}
+	case ConditionAnd, ConditionOr:
+		for _, inner := range raw.Expressions {
+			expr, err := ParseWitnessCondition(inner)
+			if err != nil {
+				return nil, err
+			}
+
+			cond.Expressions = append(cond.Expressions, expr)
+		}
+	case ConditionScriptHash, ConditionCalledByContract:
+		cond.Hash = raw.Hash
+	case ConditionGroup, ConditionCalledByGroup:
+		cond.Group = raw.Group
+	case ConditionCalledByEntry:
+	default:
+		return nil, fmt.Errorf("unknown witness condition %q", raw.Type)
+	}
+
+	return &cond, nil
+}
+
+// Flatten returns nodes of the condition tree in pre-order.
+func (rule *TransactionSignerRule) Flatten() []*TransactionSignerCondition {
+	if rule.Condition == nil {
+		return nil
+	}
+
+	return rule.flatten(nil, rule.Condition, -1)
+}
+
+func (rule *TransactionSignerRule) flatten(nodes []*TransactionSignerCondition, cond *WitnessCondition, parent int) []*TransactionSignerCondition {
+	node := TransactionSignerCondition{
+		TransactionHash:  rule.TransactionHash,
+		Account:          rule.Account,
+		RuleIndex:        rule.RuleIndex,
+		NodeIndex:        len(nodes),
+		ParentIndex:      parent,
+		WitnessCondition: cond,
+	}
+
+	nodes = append(nodes, &node)
+	for _, expr := range cond.Expressions {
+		nodes = rule.flatten(nodes, expr, node.NodeIndex)
+	}
+
+	return nodes
+}
+
+func appendTxSignerScopes(bulk *TxBulk, rawTx *rpc.Tx) {
+	for _, signer := range rawTx.Signers {
+		for _, contract := range signer.AllowedContracts {
+			bulk.TxSignerContracts = append(bulk.TxSignerContracts, &TransactionSignerContract{
+				TransactionHash: rawTx.Hash,
+				Account:         signer.Account,
+				Contract:        contract,
+			})
+		}
+
+		for _, group := range signer.AllowedGroups {
+			bulk.TxSignerGroups = append(bulk.TxSignerGroups, &TransactionSignerGroup{
+				TransactionHash: rawTx.Hash,
+				Account:         signer.Account,
+				Group:           group,
+			})
+		}
+
+		for i, rawRule := range signer.Rules {
+			rule := TransactionSignerRule{
+				TransactionHash: rawTx.Hash,
+				Account:         signer.Account,
+				RuleIndex:       i,
+				Action:          rawRule.Action,
+			}
+
+			cond, err := ParseWitnessCondition(rawRule.Condition)
+			if err != nil {
+				log.Warnf("Malformed witness rule %d of signer %s in tx %s: %v", i, signer.Account, rawTx.Hash, err)
+			}
+
+			rule.Condition = cond
+			bulk.TxSignerRules = append(bulk.TxSignerRules, &rule)
+			bulk.TxSignerConditions = append(bulk.TxSignerConditions, rule.Flatten()...)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"encoding/json"
+	"neo3-squirrel/rpc"
+	"neo3-squirrel/util/log"
+	"os"
+	"testing"
+)
+
+func TestParseTxSignerScopes(t *testing.T) {
+	log.Init(true)
+	defer func() {
+		os.RemoveAll("./logs")
+	}()
+
+	rawSigner := `{
+		"account": "0xb3f1f587042a20dd0eef2e47f137504f1419b054",
+		"scopes": "CustomContracts, WitnessRules",
+		"allowedcontracts": ["0xd2a4cff31913016155e38e474a2c06d08be276cf"],
+		"rules": [{
+			"action": "Allow",
+			"condition": {
+				"type": "And",
+				"expressions": [
+					{"type": "Not", "expression": {"type": "Boolean", "expression": "false"}},
+					{"type": "CalledByContract", "hash": "0xef4073a0f2b305a38ec4050e4d3d28bc40ea63f5"}
+				]
+			}
+		}, {
+			"action": "Deny",
+			"condition": {"type": "Unknown"}
+		}]
+	}`
+
+	signer := rpc.Signer{}
+	if err := json.Unmarshal([]byte(rawSigner), &signer); err != nil {
+		t.Fatal(err)
+	}
+
+	bulk := TxBulk{}
+	appendTxSignerScopes(&bulk, &rpc.Tx{Hash: "0x01", Signers: []rpc.Signer{signer}})
+
+	if len(bulk.TxSignerContracts) != 1 ||
+		bulk.TxSignerContracts[0].Contract != "0xd2a4cff31913016155e38e474a2c06d08be276cf" {
+		t.Fatalf("Incorrect allowed contracts: %+v", bulk.TxSignerContracts)
+	}
+
+	if len(bulk.TxSignerRules) != 2 {
+		t.Fatalf("Expected 2 witness rules, got %d", len(bulk.TxSignerRules))
+	}
+
+	// Malformed conditions are kept without the condition tree.
+	if bulk.TxSignerRules[1].Action != "Deny" || bulk.TxSignerRules[1].Condition != nil {
+		t.Fatalf("Incorrect malformed witness rule: %+v", bulk.TxSignerRules[1])
+	}
+
+	expected := []struct {
+		condType string
+		parent   int
+	}{
+		{ConditionAnd, -1},
+		{ConditionNot, 0},
+		{ConditionBoolean, 1},
+		{ConditionCalledByContract, 0},
+	}
+
+	conds := bulk.TxSignerConditions
+	if len(conds) != len(expected) {
+		t.Fatalf("Expected %d condition nodes, got %d", len(expected), len(conds))
+	}
+
+	for i, exp := range expected {
+		if conds[i].NodeIndex != i || conds[i].Type != exp.condType || conds[i].ParentIndex != exp.parent {
+			t.Fatalf("Incorrect condition node %d: %+v", i, conds[i])
+		}
+	}
+
+	if conds[2].Expression == nil || *conds[2].Expression {
+		t.Fatalf("Incorrect boolean condition: %+v", conds[2])
+	}
+
+	if conds[3].Hash != "0xef4073a0f2b305a38ec4050e4d3d28bc40ea63f5" {
+		t.Fatalf("Incorrect contract condition: %+v", conds[3])
+	}
+}
@@ -1,11 +1,17 @@
 package models
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"math/big"
 	"neo3-squirrel/rpc"
 	"neo3-squirrel/util/convert"
+	"neo3-squirrel/util/hashutil"
 	"neo3-squirrel/util/log"
+	"neo3-squirrel/util/witness"
+	"strings"
 )
 
 // TxBulk contains splited tx structure for bulk persistant.
@@ -14,6 +20,17 @@ type TxBulk struct {
 	TxSigners   []*TransactionSigner
 	TxAttrs     []*TransactionAttribute
 	TxWitnesses []*TransactionWitness
+	// TxSignerWitnesses are parsed from witnesses of tx signers.
+	TxSignerWitnesses []*TransactionSignerWitness
+	// PubKeys are revealed by witnesses of standard accounts.
+	PubKeys []*PubKey
+	// MultiSigAccounts are revealed by multi-signature witnesses.
+	MultiSigAccounts []*MultiSigAccount
+	// Scopes of tx signers beyond the scope flags.
+	TxSignerContracts  []*TransactionSignerContract
+	TxSignerGroups     []*TransactionSignerGroup
+	TxSignerRules      []*TransactionSignerRule
+	TxSignerConditions []*TransactionSignerCondition
 }
 
 // Transaction db model.
@@ -55,6 +72,51 @@ type TransactionWitness struct {
 	Verification    string
 }
 
+// Signature states of tx signer witnesses.
+const (
+	SignatureUnchecked = "unchecked"
+	SignatureValid     = "valid"
+	SignatureInvalid   = "invalid"
+)
+
+// TransactionSignerWitness represents the account type and
+// public keys of a tx signer parsed from its witness.
+type TransactionSignerWitness struct {
+	TransactionHash string
+	Account         string
+	Type            string
+	Threshold       int
+	PubKeys         []string
+	Signature       string
+
+	invocation   []byte
+	verification []byte
+}
+
+// Verify checks signatures of the signer witness, only
+// witnesses of standard and multi-signature accounts are checked.
+func (w *TransactionSignerWitness) Verify(magic uint32) {
+	if w.Type != witness.Standard && w.Type != witness.MultiSig {
+		return
+	}
+
+	w.Signature = SignatureInvalid
+
+	account, err := hashutil.GetScriptHashFromAssetID(strings.TrimPrefix(w.Account, "0x"))
+	if err != nil || !bytes.Equal(hashutil.Hash160(w.verification), account) {
+		return
+	}
+
+	hash, err := hashutil.GetScriptHashFromAssetID(strings.TrimPrefix(w.TransactionHash, "0x"))
+	if err != nil {
+		return
+	}
+
+	if witness.Verify(witness.GetSignData(magic, hash), w.invocation, w.verification) == nil {
+		w.Signature = SignatureValid
+	}
+}
+
 // ParseTx parses all *rpc.Transaction in the given block to *models.Transaction.
 func ParseTx(block *rpc.Block) []*Transaction {
 	txs := []*Transaction{}
@@ -73,8 +135,10 @@ func ParseTxs(blocks []*rpc.Block) *TxBulk {
 		for _, tx := range block.Tx {
 			bulk.Txs = appendTx(bulk.Txs, block.Index, block.Time, &tx)
 			bulk.TxSigners = appendTxSigners(bulk.TxSigners, &tx)
+			appendTxSignerScopes(&bulk, &tx)
 			bulk.TxAttrs = appendTxAttrs(bulk.TxAttrs, &tx)
 			bulk.TxWitnesses = appendTxWitnesses(bulk.TxWitnesses, &tx)
+			bulk.TxSignerWitnesses = appendTxSignerWitnesses(bulk.TxSignerWitnesses, &tx)
 		}
 	}
 
@@ -143,6 +207,43 @@ func appendTxWitnesses(witnesses []*TransactionWitness, rawTx *rpc.Tx) []*Transa
 	return witnesses
 }
 
+// appendTxSignerWitnesses parses the witness of each signer,
+// witnesses are in the same order as signers.
+func appendTxSignerWitnesses(witnesses []*TransactionSignerWitness, rawTx *rpc.Tx) []*TransactionSignerWitness {
+	for i, signer := range rawTx.Signers {
+		if i >= len(rawTx.Witnesses) {
+			break
+		}
+
+		// Witnesses are not covered by tx hashes, malformed ones are kept as custom.
+		invocation, invErr := base64.StdEncoding.DecodeString(rawTx.Witnesses[i].Invocation)
+		verification, verErr := base64.StdEncoding.DecodeString(rawTx.Witnesses[i].Verification)
+
+		accountType, threshold, pubKeys := witness.Classify(verification)
+		if invErr != nil || verErr != nil {
+			accountType, threshold, pubKeys = witness.Custom, 0, nil
+		}
+		signerWitness := TransactionSignerWitness{
+			TransactionHash: rawTx.Hash,
+			Account:         signer.Account,
+			Type:            accountType,
+			Threshold:       threshold,
+			PubKeys:         []string{},
+			Signature:       SignatureUnchecked,
+			invocation:      invocation,
+			verification:    verification,
+		}
+
+		for _, pubKey := range pubKeys {
+			signerWitness.PubKeys = append(signerWitness.PubKeys, hex.EncodeToString(pubKey))
+		}
+
+		witnesses = append(witnesses, &signerWitness)
+	}
+
+	return witnesses
+}
+
 func marshalTxAttributes(txAttrs interface{}) []byte {
 	dat, err := json.Marshal(txAttrs)
 	if err != nil {
@@ -1,6 +1,9 @@
 package models
 
-import "math/big"
+import (
+	"math/big"
+	"neo3-squirrel/cache/native"
+)
 
 // Transfer db model.
 type Transfer struct {
@@ -17,7 +20,7 @@ type Transfer struct {
 
 // IsGASClaimTransfer tells if this transfer is GAS claim transfer.
 func (transfer *Transfer) IsGASClaimTransfer() bool {
-	if transfer.Contract == GasToken &&
+	if transfer.Contract == native.GAS() &&
 		transfer.From == "" &&
 		transfer.To != "" {
 		return true
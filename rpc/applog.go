@@ -44,30 +44,78 @@ type State struct {
 }
 
 // GetApplicationLog reflects the rpc call 'getapplicationlog'.
-func GetApplicationLog(minBlockIndex uint, txID string) *ApplicationLog {
-	params := []interface{}{txID}
+// It retries until succeeded, or returns nil once cancelled returns true.
+func GetApplicationLog(minBlockIndex uint, txID string, cancelled func() bool) *ApplicationLog {
+	appLogs := GetApplicationLogs(minBlockIndex, []string{txID}, cancelled)
+	if appLogs == nil {
+		return nil
+	}
+
+	return appLogs[0]
+}
+
+// GetApplicationLogs queries applicationlogs of the given txIDs in batch
+// requests. Failed queries are retried until all succeeded,
+// or returns nil once cancelled returns true.
+func GetApplicationLogs(minBlockIndex uint, txIDs []string, cancelled func() bool) []*ApplicationLog {
 	const method = "getapplicationlog"
-	args := generateRequestBody(method, params)
-	resp := ApplicationLogResponse{}
+	appLogs := make([]*ApplicationLog, len(txIDs))
 	retryCnt := uint(0)
 	delay := 0
 
+	// pending holds indexes of txIDs waiting for query.
+	pending := make([]int, len(txIDs))
+	for i := range pending {
+		pending[i] = i
+	}
+
 	for {
-		request(minBlockIndex, args, &resp)
-		if resp.Result != nil {
-			return resp.Result
+		if cancelled != nil && cancelled() {
+			return nil
+		}
+
+		paramsList := make([][]interface{}, len(pending))
+		resps := make([]ApplicationLogResponse, len(pending))
+		targets := make([]interface{}, len(pending))
+		for j, i := range pending {
+			paramsList[j] = []interface{}{txIDs[i]}
+			targets[j] = &resps[j]
 		}
 
-		if resp.Error != nil {
-			log.Warnf("Invalid '%s' call: params=%s", method, args)
+		args, err := generateBatchRequestBody(method, paramsList)
+		if err != nil {
+			log.Panic(err)
 		}
 
+		requestBatch(minBlockIndex, args, targets)
+
+		failed := []int{}
+		for j, i := range pending {
+			if resps[j].Result != nil {
+				appLogs[i] = resps[j].Result
+				continue
+			}
+
+			if resps[j].Error != nil {
+				log.Warnf("Invalid '%s' call: txID=%s, error=%s", method, txIDs[i], resps[j].Error.Message)
+			}
+
+			failed = append(failed, i)
+		}
+
+		if len(failed) == 0 {
+			return appLogs
+		}
+
+		pending = failed
+
 		retryCnt++
 		if delay < 10*1000 {
 			delay = rand.Intn(1<<retryCnt) + 1000
 		}
 
-		log.Warnf("Cannot get applicationlog of txID: %s. Delay for %d msecs and retry(retry=%d).", txID, delay, retryCnt)
+		log.Warnf("Cannot get applicationlog of txID: %s and %d others. Delay for %d msecs and retry(retry=%d).",
+			txIDs[pending[0]], len(pending)-1, delay, retryCnt)
 
 		time.Sleep(time.Duration(delay) * time.Millisecond)
 	}
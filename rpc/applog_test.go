@@ -1,36 +1,35 @@
 package rpc
 
 import (
-	"neo3-squirrel/tests"
-	"neo3-squirrel/util/log"
-	"os"
+	"neo3-squirrel/tests/fullnode"
 	"testing"
 )
 
 func TestGetApplicationLog(t *testing.T) {
-	if testing.Short() {
-		t.SkipNow()
-	}
-
-	log.Init(true)
-	setRPCforTest(tests.GetTestRPC(t))
-	defer func() {
-		os.RemoveAll("./logs")
-	}()
+	fullnode.Setup(t, UseFullnodes)
 
 	if bestHeight.Get() < 0 {
-		t.Skip("No upstream fullnode available, test skipped")
+		t.Fatal("Fake fullnode unavailable")
 	}
 
-	// Get transactions of block index 0.
-	block := SyncBlock(0)
-	if block == nil {
-		t.Fatal("failed to get block of index 0")
+	// Get transactions of block index 1.
+	block := SyncBlock(1)
+	if block == nil || len(block.Tx) == 0 {
+		t.Fatal("failed to get block of index 1")
+	}
+
+	appLogResult := GetApplicationLog(1, block.Hash, nil)
+	if appLogResult == nil ||
+		appLogResult.BlockHash != block.Hash {
+		t.Fatalf("Incorrect 'GetApplicationLog' func, blockhash=%s", block.Hash)
 	}
 
-	appLogResult := GetApplicationLog(0, block.Hash)
+	txID := block.Tx[0].Hash
+	appLogResult = GetApplicationLog(1, txID, nil)
 	if appLogResult == nil ||
-		appLogResult.BlockHash == "" {
-		t.Fatalf("Incorrect 'GetApplicationLog' func, txid=%s", block.Hash)
+		appLogResult.TxID != txID ||
+		len(appLogResult.Executions) != 1 ||
+		len(appLogResult.Executions[0].Notifications) != 1 {
+		t.Fatalf("Incorrect 'GetApplicationLog' func, txid=%s", txID)
 	}
 }
@@ -1,5 +1,7 @@
 package rpc
 
+import "neo3-squirrel/util/log"
+
 // BlockCountResponse returns block height of chain.
 type BlockCountResponse struct {
 	responseCommon
@@ -20,25 +22,129 @@ type Block struct {
 	PreviousBlockHash string    `json:"previousblockhash"`
 	MerkleRoot        string    `json:"merkleroot"`
 	Time              uint64    `json:"time"`
+	Nonce             string    `json:"nonce"`
 	Index             uint      `json:"index"`
 	Primary           uint      `json:"primary"`
 	NextConsensus     string    `json:"nextconsensus"`
 	Witnesses         []Witness `json:"witnesses"`
 	Tx                []Tx      `json:"tx"`
+
+	// Source is the url of the fullnode serving the block.
+	Source string `json:"-"`
+}
+
+const (
+	// memFactor scales the serialized size to the memory held by
+	// decoded data, hex and base64 fields take more than raw bytes.
+	memFactor = 3
+	// memOverhead is the estimated memory held by each decoded
+	// block or transaction besides its serialized content.
+	memOverhead = 1024
+)
+
+// EstimateMemSize estimates the memory in bytes held by a decoded
+// block of the given serialized size and number of transactions.
+func EstimateMemSize(size, txs int) int64 {
+	return int64(size*memFactor + (txs+1)*memOverhead)
+}
+
+// MemSize estimates the memory in bytes held by the block.
+func (b *Block) MemSize() int64 {
+	return EstimateMemSize(b.Size, len(b.Tx))
 }
 
 // SyncBlock from rpc server.
 func SyncBlock(index uint) *Block {
+	return SyncBlockExcept(index, nil)
+}
+
+// SyncBlockExcept gets the block from fullnodes other than the excluded ones.
+func SyncBlockExcept(index uint, excluded map[string]bool) *Block {
 	params := []interface{}{index, 1}
-	args := generateRequestBody("getblock", params)
+	args, err := generateRequestBody("getblock", params)
+	if err != nil {
+		log.Error(err)
+		return nil
+	}
 
 	respData := BlockResponse{}
-	request(index, args, &respData)
+	url := requestTo(index, args, &respData, func(url string) bool {
+		return !excluded[url]
+	})
 
 	block := respData.Result
-	if block != nil && block.Index > 0 {
-		bestHeight.SetIfHigher(int(block.Index))
+	if block != nil {
+		block.Source = url
+		if block.Index > 0 {
+			bestHeight.SetIfHigher(int(block.Index))
+		}
 	}
 
 	return block
 }
+
+// SyncBlocks gets blocks of the given indexes in one batch request.
+// Blocks failed to get are left nil in the returned slice.
+func SyncBlocks(indexes []uint) []*Block {
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	paramsList := make([][]interface{}, len(indexes))
+	resps := make([]BlockResponse, len(indexes))
+	targets := make([]interface{}, len(indexes))
+	maxIndex := uint(0)
+
+	for i, index := range indexes {
+		paramsList[i] = []interface{}{index, 1}
+		targets[i] = &resps[i]
+		if index > maxIndex {
+			maxIndex = index
+		}
+	}
+
+	blocks := make([]*Block, len(indexes))
+
+	args, err := generateBatchRequestBody("getblock", paramsList)
+	if err != nil {
+		log.Error(err)
+		return blocks
+	}
+
+	url := requestBatch(maxIndex, args, targets)
+
+	for i, resp := range resps {
+		block := resp.Result
+		if block != nil {
+			block.Source = url
+			if block.Index > 0 {
+				bestHeight.SetIfHigher(int(block.Index))
+			}
+		}
+
+		blocks[i] = block
+	}
+
+	return blocks
+}
+
+// BlockHashResponse returns block hash of a specific index.
+type BlockHashResponse struct {
+	responseCommon
+	Result string `json:"result"`
+}
+
+// GetBlockHash reflects the rpc call 'getblockhash'.
+func GetBlockHash(index uint) string {
+	params := []interface{}{index}
+	args, err := generateRequestBody("getblockhash", params)
+	if err != nil {
+		log.Error(err)
+		return ""
+	}
+
+	respData := BlockHashResponse{}
+	request(index, args, &respData)
+
+	return respData.Result
+}
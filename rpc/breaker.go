@@ -0,0 +1,158 @@
+package rpc
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// breakerThreshold opens the breaker after consecutive transport failures.
+	breakerThreshold = 3
+	// breakerCooldown is the initial duration an open breaker rejects requests.
+	breakerCooldown = 2 * time.Second
+	// breakerMaxCooldown limits the cooldown growth of repeatedly failed trials.
+	breakerMaxCooldown = 60 * time.Second
+)
+
+// BreakerState is the circuit breaker state of a fullnode.
+type BreakerState int
+
+// Circuit breaker states.
+const (
+	// BreakerClosed passes all requests.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects all requests until cooldown.
+	BreakerOpen
+	// BreakerHalfOpen passes a single trial request.
+	BreakerHalfOpen
+)
+
+func (state BreakerState) String() string {
+	switch state {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// breaker is the circuit breaker of a single fullnode.
+// Only transport errors trip the breaker, JSON-RPC errors don't.
+type breaker struct {
+	mu sync.Mutex
+
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	cooldown time.Duration
+	// trial indicates if the trial request of half-open state is in flight.
+	trial bool
+}
+
+// map[url(string)]*breaker
+var breakers sync.Map
+
+func getBreaker(url string) *breaker {
+	b, _ := breakers.LoadOrStore(url, &breaker{cooldown: breakerCooldown})
+	return b.(*breaker)
+}
+
+// available tells if the breaker would pass a request now.
+func (b *breaker) available() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		return time.Since(b.openedAt) >= b.cooldown
+	case BreakerHalfOpen:
+		return !b.trial
+	default:
+		return true
+	}
+}
+
+// acquire reserves a request, the breaker turns half-open
+// and reserves the trial request once cooldown elapsed.
+func (b *breaker) acquire() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+
+		b.state = BreakerHalfOpen
+		b.trial = true
+		return true
+	case BreakerHalfOpen:
+		if b.trial {
+			return false
+		}
+
+		b.trial = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = BreakerClosed
+	b.failures = 0
+	b.cooldown = breakerCooldown
+	b.trial = false
+}
+
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+
+	switch b.state {
+	case BreakerHalfOpen:
+		// The trial failed, wait longer before the next one.
+		b.cooldown *= 2
+		if b.cooldown > breakerMaxCooldown {
+			b.cooldown = breakerMaxCooldown
+		}
+		b.open()
+	case BreakerClosed:
+		if b.failures >= breakerThreshold {
+			b.open()
+		}
+	}
+}
+
+func (b *breaker) open() {
+	b.state = BreakerOpen
+	b.openedAt = time.Now()
+	b.trial = false
+}
+
+func (b *breaker) getState() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+// GetBreakerStates returns circuit breaker states of all fullnodes.
+func GetBreakerStates() map[string]BreakerState {
+	states := map[string]BreakerState{}
+	for url := range getNodes() {
+		states[url] = getBreaker(url).getState()
+	}
+
+	return states
+}
@@ -0,0 +1,80 @@
+package rpc
+
+import (
+	"errors"
+	"neo3-squirrel/tests/fullnode"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	b := &breaker{cooldown: breakerCooldown}
+
+	for i := 0; i < breakerThreshold; i++ {
+		if !b.acquire() {
+			t.Fatal("Closed breaker must pass requests")
+		}
+		b.failure()
+	}
+
+	if b.getState() != BreakerOpen || b.available() || b.acquire() {
+		t.Fatalf("Breaker must be open after %d failures", breakerThreshold)
+	}
+
+	// Cooldown elapsed, only a single trial is allowed.
+	b.openedAt = time.Now().Add(-breakerCooldown)
+	if !b.acquire() || b.getState() != BreakerHalfOpen {
+		t.Fatal("Breaker must turn half-open after cooldown")
+	}
+
+	if b.available() || b.acquire() {
+		t.Fatal("Half-open breaker must pass only a single trial")
+	}
+
+	b.failure()
+	if b.getState() != BreakerOpen || b.cooldown != 2*breakerCooldown {
+		t.Fatalf("Failed trial must reopen the breaker with a longer cooldown, got %s, %v", b.getState(), b.cooldown)
+	}
+
+	b.openedAt = time.Now().Add(-b.cooldown)
+	if !b.acquire() {
+		t.Fatal("Breaker must turn half-open after cooldown")
+	}
+
+	b.success()
+	if b.getState() != BreakerClosed || !b.available() {
+		t.Fatal("Succeeded trial must close the breaker")
+	}
+}
+
+func TestRPCErrorNotTripBreaker(t *testing.T) {
+	node := fullnode.Setup(t, UseFullnodes)
+
+	for i := 0; i < breakerThreshold+1; i++ {
+		if contract := GetContractState(0, "0x0000000000000000000000000000000000000000"); contract != nil {
+			t.Fatal("Contract must not exist")
+		}
+	}
+
+	if calls := node.Calls("getcontractstate"); calls != breakerThreshold+1 {
+		t.Fatalf("Expected %d 'getcontractstate' calls, got %d", breakerThreshold+1, calls)
+	}
+
+	if state := GetBreakerStates()[node.URL]; state != BreakerClosed {
+		t.Fatalf("JSON-RPC errors must not trip the breaker, got %s", state)
+	}
+}
+
+func TestTransportErrorKind(t *testing.T) {
+	server := httptest.NewServer(nil)
+	url := server.URL
+	server.Close()
+
+	_, err := NewHTTPTransport().Post(url, []byte("{}"))
+
+	var tErr *TransportError
+	if !errors.As(err, &tErr) || tErr.Kind != ErrConnRefused {
+		t.Fatalf("Closed server must be classified as connection refused, got %v", err)
+	}
+}
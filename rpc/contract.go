@@ -43,6 +43,18 @@ type ContractState struct {
 	Manifest      ContractManifest `json:"manifest"`
 }
 
+// NativeContractsResponse is the response structure of rpc call 'getnativecontracts'.
+type NativeContractsResponse struct {
+	responseCommon
+	Result []*NativeContractState `json:"result"`
+}
+
+// NativeContractState represents native contract item of 'getnativecontracts' query result.
+type NativeContractState struct {
+	ContractState
+	UpdateHistory []uint `json:"updatehistory"`
+}
+
 type ContractNEF struct {
 	Magic    uint64      `json:"magic"`
 	Compiler string      `json:"compiler"`
@@ -64,7 +76,7 @@ type ContractManifest struct {
 }
 
 // InvokeScript reflects the rpc call 'invokescript'.
-func InvokeScript(minBlockIndex uint, script string) *InvokeFunctionResult {
+func InvokeScript(minBlockIndex uint, script string) (*InvokeFunctionResult, error) {
 	const method = "invokescript"
 	params := []interface{}{script}
 
@@ -72,18 +84,23 @@ func InvokeScript(minBlockIndex uint, script string) *InvokeFunctionResult {
 }
 
 // InvokeFunction reflects the rpc call 'invokefunction'.
-func InvokeFunction(minBlockIndex uint, contract, invokeFunc string, parameters []interface{}) *InvokeFunctionResult {
+// Returns error if any parameter mismatches its type.
+func InvokeFunction(minBlockIndex uint, contract, invokeFunc string, parameters []ContractParameter) (*InvokeFunctionResult, error) {
 	const method = "invokefunction"
 	params := []interface{}{contract, invokeFunc}
 	if len(parameters) > 0 {
-		params = append(params, parameters...)
+		params = append(params, parameters)
 	}
 
 	return doInvoke(minBlockIndex, method, params)
 }
 
-func doInvoke(minBlockIndex uint, method string, params []interface{}) *InvokeFunctionResult {
-	args := generateRequestBody(method, params)
+func doInvoke(minBlockIndex uint, method string, params []interface{}) (*InvokeFunctionResult, error) {
+	args, err := generateRequestBody(method, params)
+	if err != nil {
+		return nil, err
+	}
+
 	resp := InvokefunctionResponse{}
 	retryCnt := uint(0)
 	delay := 0
@@ -91,7 +108,7 @@ func doInvoke(minBlockIndex uint, method string, params []interface{}) *InvokeFu
 	for {
 		request(minBlockIndex, args, &resp)
 		if resp.Result != nil {
-			return resp.Result
+			return resp.Result, nil
 		}
 
 		if resp.Error != nil {
@@ -114,8 +131,29 @@ func GetContractState(fromBlockIndex uint, hash string) *ContractState {
 	const method = "getcontractstate"
 	params := []interface{}{hash}
 
-	args := generateRequestBody(method, params)
+	args, err := generateRequestBody(method, params)
+	if err != nil {
+		log.Error(err)
+		return nil
+	}
+
 	resp := ContractStatesResponse{}
 	request(fromBlockIndex, args, &resp)
 	return resp.Result
 }
+
+// GetNativeContracts reflects the rpc call 'getnativecontracts'.
+func GetNativeContracts() []*NativeContractState {
+	const method = "getnativecontracts"
+	params := []interface{}{}
+
+	args, err := generateRequestBody(method, params)
+	if err != nil {
+		log.Error(err)
+		return nil
+	}
+
+	resp := NativeContractsResponse{}
+	request(0, args, &resp)
+	return resp.Result
+}
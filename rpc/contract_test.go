@@ -0,0 +1,25 @@
+package rpc
+
+import (
+	"neo3-squirrel/tests/fullnode"
+	"testing"
+)
+
+func TestGetNativeContracts(t *testing.T) {
+	fullnode.Setup(t, UseFullnodes)
+
+	natives := GetNativeContracts()
+	if len(natives) != 1 {
+		t.Fatalf("Incorrect 'GetNativeContracts' result size, expected 1, got %d", len(natives))
+	}
+
+	gas := natives[0]
+	if gas.Manifest.Name != "GasToken" ||
+		gas.Hash != "0xd2a4cff31913016155e38e474a2c06d08be276cf" {
+		t.Fatalf("Incorrect native contract: %s(%s)", gas.Manifest.Name, gas.Hash)
+	}
+
+	if len(gas.UpdateHistory) != 1 || gas.UpdateHistory[0] != 0 {
+		t.Fatalf("Incorrect native contract update history: %v", gas.UpdateHistory)
+	}
+}
@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/valyala/fasthttp"
+)
+
+// TransportErrorKind classifies transport errors.
+type TransportErrorKind int
+
+// Transport error kinds.
+const (
+	ErrUnknown TransportErrorKind = iota
+	ErrTimeout
+	ErrConnRefused
+	ErrConnClosed
+	ErrBadStatus
+)
+
+// TransportError means no valid response was received from a fullnode,
+// the fullnode itself may be down.
+type TransportError struct {
+	URL  string
+	Kind TransportErrorKind
+	Err  error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("transport error from %s: %v", e.URL, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// Expected tells if the error is a common failure of unavailable fullnodes.
+func (e *TransportError) Expected() bool {
+	return e.Kind != ErrUnknown
+}
+
+// RPCError is the JSON-RPC error object returned by a fullnode,
+// the fullnode is alive but rejected the request, e.g., 'Unknown block'.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+func newTransportError(url string, err error) *TransportError {
+	return &TransportError{
+		URL:  url,
+		Kind: classifyTransportError(err),
+		Err:  err,
+	}
+}
+
+func classifyTransportError(err error) TransportErrorKind {
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, fasthttp.ErrTimeout),
+		errors.Is(err, fasthttp.ErrDialTimeout),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return ErrTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrConnRefused
+	case errors.Is(err, fasthttp.ErrConnectionClosed),
+		errors.Is(err, syscall.ECONNRESET):
+		return ErrConnClosed
+	default:
+		return ErrUnknown
+	}
+}
@@ -2,17 +2,13 @@ package rpc
 
 import (
 	"encoding/json"
-	"math/rand"
 	"neo3-squirrel/config"
 	"neo3-squirrel/util/color"
 	"neo3-squirrel/util/counter"
 	"neo3-squirrel/util/log"
 	"os"
-	"strings"
 	"sync"
 	"time"
-
-	"github.com/valyala/fasthttp"
 )
 
 var (
@@ -59,13 +55,22 @@ func updateNodes(mp map[string]int) {
 	}
 }
 
-// GetStatus prints rpc host with its current best height.
+// GetStatus prints rpc host with its current best height and breaker state.
 func GetStatus() {
+	breakerStates := GetBreakerStates()
+	quarantinedNodes := GetQuarantinedNodes()
+
 	for host, height := range getNodes() {
-		if height < 0 {
+		if reason, ok := quarantinedNodes[host]; ok {
+			log.Warnf(color.Redf("%s: quarantined, %s", host, reason))
+		} else if height < 0 {
 			log.Warnf(color.Redf("%s: failed to get block height, rpc unavailable", host))
 		} else {
-			log.Infof("%s: %d\n", host, height)
+			score := getScore(host)
+			score.mu.Lock()
+			log.Infof("%s: %d (latency=%.1fms, failures=%d, breaker=%s)\n",
+				host, height, score.latency, score.totalFailures, breakerStates[host])
+			score.mu.Unlock()
 		}
 	}
 }
@@ -98,10 +103,11 @@ func refreshNodesHeight() int {
 
 // TraceBestHeight traces the best block height of listed rpc nodes.
 func TraceBestHeight() {
-	rpcs := config.GetRPCs()
+	rpcs := config.GetRPCNodes()
 	newodeHeight := make(map[string]int)
 	for _, rpc := range rpcs {
-		newodeHeight[rpc] = -2
+		newodeHeight[rpc.URL] = -2
+		setNodeOptions(rpc.URL, rpc.Weight, rpc.Priority)
 	}
 
 	if len(newodeHeight) == 0 {
@@ -112,6 +118,13 @@ func TraceBestHeight() {
 
 	log.Info("Checking all fullnodes...")
 
+	if checkNetworks() == 0 {
+		log.Error(color.BRed("No fullnode verified on the expected network"))
+		os.Exit(1)
+	}
+
+	go traceNetworks()
+
 	refreshNodesHeight()
 	GetStatus()
 
@@ -120,6 +133,15 @@ func TraceBestHeight() {
 		os.Exit(1)
 	}
 
+	// WebSocket traffic can't be replayed.
+	if _, replay := transport.(*ReplayTransport); !replay {
+		for _, rpc := range rpcs {
+			if rpc.WS != "" {
+				SubscribeBlocks(rpc.URL, rpc.WS)
+			}
+		}
+	}
+
 	go func() {
 		for {
 			bestHeight := refreshNodesHeight()
@@ -152,9 +174,10 @@ func TraceBestHeight() {
 	}()
 }
 
-func selectNode(minHeight uint) (string, bool, int) {
-	// Suppose all nodes are qualified.
-	candidates := []string{}
+// selectNode picks a fullnode not lower than minHeight and accepted
+// by the filter, a nil filter accepts all nodes.
+func selectNode(minHeight uint, filter func(url string) bool) (string, bool, int) {
+	candidates := []candidate{}
 	currBestHeight := -1
 
 	for url, height := range getNodes() {
@@ -162,23 +185,31 @@ func selectNode(minHeight uint) (string, bool, int) {
 			currBestHeight = height
 		}
 
-		if height >= int(minHeight) {
-			// Increase the possibility to select local nodes.
-			if strings.Contains(url, "127.0.0.1") ||
-				strings.Contains(url, "localhost") {
-				candidates = append(candidates, url)
-			}
+		if filter != nil && !filter(url) {
+			continue
+		}
 
-			candidates = append(candidates, url)
+		if height >= int(minHeight) && getBreaker(url).available() {
+			candidates = append(candidates, candidate{url: url, height: height})
 		}
 	}
 
-	l := len(candidates)
-	if l == 0 {
-		return "", false, currBestHeight
+	for len(candidates) > 0 {
+		url := pickNode(candidates, currBestHeight)
+		if getBreaker(url).acquire() {
+			return url, true, currBestHeight
+		}
+
+		// The trial request of the half-open breaker has been taken.
+		for i, c := range candidates {
+			if c.url == url {
+				candidates = append(candidates[:i], candidates[i+1:]...)
+				break
+			}
+		}
 	}
 
-	return candidates[rand.Intn(l)], true, currBestHeight
+	return "", false, currBestHeight
 }
 
 // getHeights gets best height of all fullnodes.
@@ -192,6 +223,11 @@ func getHeights() map[string]int {
 
 	for url := range nodes {
 		go func(url string, c chan<- nodeInfo) {
+			if isQuarantined(url) {
+				c <- nodeInfo{url: url, height: -1}
+				return
+			}
+
 			height, _ := getHeightFrom(url)
 			c <- nodeInfo{
 				url:    url,
@@ -214,21 +250,23 @@ func getHeights() map[string]int {
 
 func getHeightFrom(url string) (int, error) {
 	params := []interface{}{}
-	args := generateRequestBody("getblockcount", params)
-	resp := fasthttp.AcquireResponse()
-	req := fasthttp.AcquireRequest()
-	req.Header.SetMethod("POST")
-	req.SetBody([]byte(args))
-	req.SetRequestURI(url)
+	args, err := generateRequestBody("getblockcount", params)
+	if err != nil {
+		return -1, err
+	}
 
 	respData := BlockCountResponse{}
-	err := client.Do(req, resp)
+	start := time.Now()
+	respBody, err := transport.Post(url, []byte(args))
 	if err != nil {
 		// log.Debug(err)
+		recordFailure(url)
 		return -1, err
 	}
 
-	err = json.Unmarshal(resp.Body(), &respData)
+	recordSuccess(url, time.Since(start))
+
+	err = json.Unmarshal(respBody, &respData)
 	if err != nil {
 		log.Error(err)
 		return -1, err
@@ -237,12 +275,34 @@ func getHeightFrom(url string) (int, error) {
 	return respData.Result - 1, nil
 }
 
-func nodeUnavailable(url string) {
-	updateNodes(map[string]int{url: -1})
-}
+// UseFullnodes replaces all traced fullnodes with the given urls
+// and refreshes their heights, e.g., to run tasks against fake fullnodes.
+func UseFullnodes(urls ...string) {
+	nodeHeights.Range(func(key, _ interface{}) bool {
+		nodeHeights.Delete(key)
+		return true
+	})
+
+	quarantined.Range(func(key, _ interface{}) bool {
+		quarantined.Delete(key)
+		return true
+	})
+
+	networkMu.Lock()
+	network = nil
+	networkMu.Unlock()
+
+	historicSupport.Range(func(key, _ interface{}) bool {
+		historicSupport.Delete(key)
+		return true
+	})
+
+	nodes := map[string]int{}
+	for _, url := range urls {
+		nodes[url] = 0
+	}
 
-func setRPCforTest(rpcAddr string) {
-	nodes := map[string]int{rpcAddr: 0}
 	updateNodes(nodes)
+	checkNetworks()
 	refreshNodesHeight()
 }
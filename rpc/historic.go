@@ -0,0 +1,153 @@
+package rpc
+
+import (
+	"encoding/json"
+	"math/rand"
+	"neo3-squirrel/util/color"
+	"neo3-squirrel/util/log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors telling fullnodes cannot serve historic invocations.
+const (
+	errCodeMethodNotFound   = -32601
+	errCodeUnknownStateRoot = -106
+)
+
+// historicRecheckInterval is the time after which fullnodes
+// marked unsupported are probed again, e.g., after upgraded.
+var historicRecheckInterval = 10 * time.Minute
+
+// historicState is the historic invocation support of a fullnode.
+type historicState struct {
+	supported bool
+	checkedAt time.Time
+}
+
+// historicSupport records if fullnodes support historic invocations.
+// Nodes not probed yet are treated as supported until they fail.
+var historicSupport sync.Map
+
+func supportsHistoric(url string) bool {
+	state, ok := historicSupport.Load(url)
+	if !ok {
+		return true
+	}
+
+	s := state.(historicState)
+	return s.supported || time.Since(s.checkedAt) >= historicRecheckInterval
+}
+
+func setHistoricSupport(url string, supported bool) {
+	prev, loaded := historicSupport.Load(url)
+	historicSupport.Store(url, historicState{supported: supported, checkedAt: time.Now()})
+
+	if loaded && prev.(historicState).supported == supported {
+		return
+	}
+
+	if !supported {
+		log.Warn(color.BYellowf("Fullnode %s does not support historic invocations", url))
+	}
+}
+
+// historicUnsupported tells if the error means the fullnode
+// cannot serve historic invocations at all.
+func historicUnsupported(err *RPCError) bool {
+	return err.Code == errCodeMethodNotFound ||
+		err.Code == errCodeUnknownStateRoot ||
+		strings.Contains(strings.ToLower(err.Message), "unknown state root")
+}
+
+// HistoricInvocationAvailable tells if any fullnode
+// may serve historic invocations.
+func HistoricInvocationAvailable() bool {
+	for url, height := range getNodes() {
+		if height >= 0 && supportsHistoric(url) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// InvokeScriptHistoric reflects the rpc call 'invokescripthistoric',
+// which runs the script on the state right after the given block.
+// Returns false if no fullnode supports historic invocations.
+func InvokeScriptHistoric(blockIndex uint, script string) (*InvokeFunctionResult, bool, error) {
+	const method = "invokescripthistoric"
+	params := []interface{}{blockIndex, script}
+
+	return doInvokeHistoric(blockIndex, method, params)
+}
+
+// InvokeFunctionHistoric reflects the rpc call 'invokefunctionhistoric',
+// which invokes the contract on the state right after the given block.
+// Returns false if no fullnode supports historic invocations,
+// and error if any parameter mismatches its type.
+func InvokeFunctionHistoric(blockIndex uint, contract, invokeFunc string, parameters []ContractParameter) (*InvokeFunctionResult, bool, error) {
+	const method = "invokefunctionhistoric"
+	params := []interface{}{blockIndex, contract, invokeFunc}
+	if len(parameters) > 0 {
+		params = append(params, parameters)
+	}
+
+	return doInvokeHistoric(blockIndex, method, params)
+}
+
+func doInvokeHistoric(blockIndex uint, method string, params []interface{}) (*InvokeFunctionResult, bool, error) {
+	args, err := generateRequestBody(method, params)
+	if err != nil {
+		return nil, false, err
+	}
+
+	retryCnt := uint(0)
+	delay := 0
+
+	// Nodes found unsupported are not retried in the same invocation.
+	unsupported := map[string]bool{}
+	filter := func(url string) bool {
+		return !unsupported[url] && supportsHistoric(url)
+	}
+
+	for {
+		respBody, url, ok := postTo(blockIndex, args, filter)
+		if !ok {
+			return nil, false, nil
+		}
+
+		resp := InvokefunctionResponse{}
+		if err := json.Unmarshal(respBody, &resp); err != nil {
+			log.Error(err)
+			log.Errorf("Response: %v", string(respBody))
+		}
+
+		// Nodes without the method, or without the states of
+		// the given block, cannot serve historic invocations.
+		// Other errors are retried like failed invocations.
+		if resp.Error != nil {
+			log.Warnf("Invalid '%s' call on %s: %v", method, url, resp.Error)
+			if historicUnsupported(resp.Error) {
+				unsupported[url] = true
+				setHistoricSupport(url, false)
+				continue
+			}
+		}
+
+		if resp.Result != nil {
+			setHistoricSupport(url, true)
+			return resp.Result, true, nil
+		}
+
+		retryCnt++
+		if delay < 10*1000 {
+			delay = rand.Intn(1<<retryCnt) + 1000
+		}
+
+		log.Warnf("Failed to invoke smartcontract: %v. Delay for %d msecs and retry(retry=%d).", args, delay, retryCnt)
+
+		time.Sleep(time.Duration(delay) * time.Millisecond)
+	}
+}
@@ -0,0 +1,136 @@
+package rpc
+
+import (
+	"encoding/json"
+	"neo3-squirrel/tests/fullnode"
+	"neo3-squirrel/util/log"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestInvokeFunctionHistoric(t *testing.T) {
+	log.Init(true)
+	defer func() {
+		os.RemoveAll("./logs")
+	}()
+
+	const gas = "0xd2a4cff31913016155e38e474a2c06d08be276cf"
+
+	legacy := fullnode.New()
+	defer legacy.Close()
+	legacy.Handle("invokefunctionhistoric", fullnode.MethodNotFound)
+
+	archive := fullnode.New()
+	defer archive.Close()
+
+	for _, node := range []*fullnode.Fullnode{legacy, archive} {
+		if err := node.LoadFixtures(fullnode.Testdata()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	UseFullnodes(legacy.URL, archive.URL)
+
+	for i := 0; i < 10; i++ {
+		result, ok, _ := InvokeFunctionHistoric(1, gas, "symbol", nil)
+		if !ok || result == nil || len(result.Stack) != 1 {
+			t.Fatalf("Incorrect 'invokefunctionhistoric' result: %+v", result)
+		}
+	}
+
+	if calls := legacy.Calls("invokefunctionhistoric"); calls > 1 {
+		t.Fatalf("Unsupported fullnode must be avoided after detected, called %d times", calls)
+	}
+
+	if archive.Calls("invokefunctionhistoric") < 9 {
+		t.Fatalf("Historic invocations must be served by the supported fullnode")
+	}
+
+	if !HistoricInvocationAvailable() {
+		t.Fatalf("Historic invocations must be available")
+	}
+
+	UseFullnodes(legacy.URL)
+
+	if _, ok, _ := InvokeFunctionHistoric(1, gas, "symbol", nil); ok {
+		t.Fatalf("Historic invocations must fail without supported fullnodes")
+	}
+
+	if HistoricInvocationAvailable() {
+		t.Fatalf("Historic invocations must not be available")
+	}
+}
+
+func TestHistoricSupportErrors(t *testing.T) {
+	log.Init(true)
+	defer func() {
+		os.RemoveAll("./logs")
+	}()
+
+	const gas = "0xd2a4cff31913016155e38e474a2c06d08be276cf"
+
+	pruned := fullnode.New()
+	defer pruned.Close()
+	pruned.Handle("invokefunctionhistoric", func([]json.RawMessage) (interface{}, *fullnode.Error) {
+		return nil, &fullnode.Error{Code: fullnode.ErrCodeUnknownStateRoot, Message: "Unknown state root"}
+	})
+
+	// A node failing once for other reasons is retried, not marked unsupported.
+	failed := false
+	flaky := fullnode.New()
+	defer flaky.Close()
+	flaky.Handle("invokefunctionhistoric", func([]json.RawMessage) (interface{}, *fullnode.Error) {
+		if !failed {
+			failed = true
+			return nil, &fullnode.Error{Code: fullnode.ErrCodeUnknownItem, Message: "Unknown contract"}
+		}
+
+		return map[string]interface{}{
+			"state": "HALT",
+			"stack": []map[string]interface{}{{"type": "ByteString", "value": "R0FT"}},
+		}, nil
+	})
+
+	for _, node := range []*fullnode.Fullnode{pruned, flaky} {
+		if err := node.LoadFixtures(fullnode.Testdata()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	UseFullnodes(flaky.URL)
+
+	if result, ok, _ := InvokeFunctionHistoric(1, gas, "symbol", nil); !ok || len(result.Stack) != 1 {
+		t.Fatalf("Historic invocation must be retried after other errors, got %+v", result)
+	}
+
+	if !supportsHistoric(flaky.URL) {
+		t.Fatalf("Fullnode must not be marked unsupported on other errors")
+	}
+
+	UseFullnodes(pruned.URL)
+
+	if _, ok, _ := InvokeFunctionHistoric(1, gas, "symbol", nil); ok {
+		t.Fatalf("Historic invocations must fail without states")
+	}
+
+	if supportsHistoric(pruned.URL) {
+		t.Fatalf("Fullnode without states must be marked unsupported")
+	}
+
+	// Unsupported fullnodes are probed again after the recheck interval.
+	defer func(interval time.Duration) {
+		historicRecheckInterval = interval
+	}(historicRecheckInterval)
+	historicRecheckInterval = 0
+
+	if !supportsHistoric(pruned.URL) {
+		t.Fatalf("Unsupported fullnode must be rechecked after the interval")
+	}
+
+	calls := pruned.Calls("invokefunctionhistoric")
+	InvokeFunctionHistoric(1, gas, "symbol", nil)
+	if pruned.Calls("invokefunctionhistoric") == calls {
+		t.Fatalf("Unsupported fullnode must be probed again after the interval")
+	}
+}
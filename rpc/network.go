@@ -0,0 +1,206 @@
+package rpc
+
+import (
+	"neo3-squirrel/util/color"
+	"neo3-squirrel/util/log"
+	"sync"
+	"time"
+)
+
+// networkCheckInterval is the interval to recheck networks of fullnodes.
+const networkCheckInterval = 60 * time.Second
+
+var (
+	networkMu sync.RWMutex
+	// network is the protocol settings all fullnodes must match,
+	// nil until determined by the first network check.
+	network *ProtocolSettings
+	// expectedMagic is the network magic of persisted data, 0 if unknown.
+	expectedMagic uint32
+
+	// map[url(string)]reason(string)
+	quarantined sync.Map
+)
+
+// SetNetwork sets the expected network magic, fullnodes on other networks
+// will be quarantined. Must be called before TraceBestHeight.
+func SetNetwork(magic uint32) {
+	networkMu.Lock()
+	defer networkMu.Unlock()
+
+	expectedMagic = magic
+}
+
+// GetNetwork returns protocol settings of the verified network.
+func GetNetwork() (ProtocolSettings, bool) {
+	networkMu.RLock()
+	defer networkMu.RUnlock()
+
+	if network == nil {
+		return ProtocolSettings{}, false
+	}
+
+	return *network, true
+}
+
+func isQuarantined(url string) bool {
+	_, ok := quarantined.Load(url)
+	return ok
+}
+
+// sameNetwork tells if the given settings belong to the same network.
+func sameNetwork(a, b ProtocolSettings) bool {
+	return a.Network == b.Network &&
+		a.AddressVersion == b.AddressVersion &&
+		a.ValidatorsCount == b.ValidatorsCount &&
+		a.MSPerBlock == b.MSPerBlock
+}
+
+// networkKey reduces the settings to the fields compared by sameNetwork.
+func networkKey(s ProtocolSettings) ProtocolSettings {
+	return ProtocolSettings{
+		Network:         s.Network,
+		AddressVersion:  s.AddressVersion,
+		ValidatorsCount: s.ValidatorsCount,
+		MSPerBlock:      s.MSPerBlock,
+	}
+}
+
+// checkNetworks gets versions of all fullnodes, fullnodes on other networks
+// and fullnodes never verified are quarantined. Returns the number of
+// verified fullnodes.
+func checkNetworks() int {
+	nodes := getNodes()
+	settings := map[string]ProtocolSettings{}
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+
+	for url := range nodes {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+
+			version, err := getVersionFrom(url)
+			if err != nil {
+				return
+			}
+
+			mu.Lock()
+			settings[url] = version.Protocol
+			mu.Unlock()
+		}(url)
+	}
+
+	wg.Wait()
+
+	reference, ok := getReferenceNetwork(settings)
+	if !ok {
+		for url := range nodes {
+			quarantine(url, "network unverified")
+		}
+		return 0
+	}
+
+	verified := 0
+	for url := range nodes {
+		s, ok := settings[url]
+		switch {
+		case !ok:
+			// Keep the result of the last check if the fullnode is unavailable.
+			if !isQuarantined(url) && !wasVerified(url) {
+				quarantine(url, "network unverified")
+			}
+		case sameNetwork(s, reference):
+			release(url)
+		default:
+			quarantine(url, color.Redf("network %d mismatch, expected %d", s.Network, reference.Network))
+		}
+
+		if !isQuarantined(url) {
+			verified++
+		}
+	}
+
+	return verified
+}
+
+// getReferenceNetwork returns the determined network, or determines
+// it by the majority of fullnodes on the expected network.
+func getReferenceNetwork(settings map[string]ProtocolSettings) (ProtocolSettings, bool) {
+	networkMu.Lock()
+	defer networkMu.Unlock()
+
+	if network != nil {
+		return *network, true
+	}
+
+	votes := map[ProtocolSettings]int{}
+	var reference ProtocolSettings
+	for _, s := range settings {
+		if expectedMagic != 0 && s.Network != expectedMagic {
+			continue
+		}
+
+		key := networkKey(s)
+		votes[key]++
+
+		if votes[key] > votes[networkKey(reference)] {
+			reference = s
+		}
+	}
+
+	if len(votes) == 0 {
+		return ProtocolSettings{}, false
+	}
+
+	network = &reference
+	log.Info(color.Greenf("Network determined: magic=%d, addressversion=%d",
+		reference.Network, reference.AddressVersion))
+
+	return reference, true
+}
+
+// map[url(string)]bool
+var verifiedNodes sync.Map
+
+func wasVerified(url string) bool {
+	_, ok := verifiedNodes.Load(url)
+	return ok
+}
+
+func quarantine(url, reason string) {
+	if old, ok := quarantined.Load(url); !ok || old.(string) != reason {
+		log.Warn(color.BYellowf("Fullnode %s quarantined: %s", url, reason))
+	}
+
+	quarantined.Store(url, reason)
+	updateNodes(map[string]int{url: -1})
+}
+
+func release(url string) {
+	if _, ok := quarantined.Load(url); ok {
+		log.Info(color.BGreenf("Fullnode %s verified, released from quarantine", url))
+	}
+
+	quarantined.Delete(url)
+	verifiedNodes.Store(url, true)
+}
+
+// traceNetworks rechecks networks of fullnodes periodically.
+func traceNetworks() {
+	for {
+		time.Sleep(networkCheckInterval)
+		checkNetworks()
+	}
+}
+
+// GetQuarantinedNodes returns quarantined fullnodes with reasons.
+func GetQuarantinedNodes() map[string]string {
+	nodes := map[string]string{}
+	quarantined.Range(func(key, value interface{}) bool {
+		nodes[key.(string)] = value.(string)
+		return true
+	})
+
+	return nodes
+}
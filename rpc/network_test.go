@@ -0,0 +1,83 @@
+package rpc
+
+import (
+	"neo3-squirrel/tests/fullnode"
+	"neo3-squirrel/util/log"
+	"os"
+	"testing"
+)
+
+func TestCheckNetworks(t *testing.T) {
+	log.Init(true)
+	defer func() {
+		os.RemoveAll("./logs")
+	}()
+
+	mainnet1 := fullnode.New()
+	defer mainnet1.Close()
+	mainnet2 := fullnode.New()
+	defer mainnet2.Close()
+	testnet := fullnode.New()
+	defer testnet.Close()
+	testnet.SetNetwork(877933390)
+
+	for _, node := range []*fullnode.Fullnode{mainnet1, mainnet2, testnet} {
+		if err := node.LoadFixtures(fullnode.Testdata()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	UseFullnodes(mainnet1.URL, mainnet2.URL, testnet.URL)
+
+	quarantinedNodes := GetQuarantinedNodes()
+	if len(quarantinedNodes) != 1 || quarantinedNodes[testnet.URL] == "" {
+		t.Fatalf("Only the fullnode on another network must be quarantined, got %v", quarantinedNodes)
+	}
+
+	if network, ok := GetNetwork(); !ok || network.Network != 860833102 {
+		t.Fatalf("Network must be determined by majority, got %d", network.Network)
+	}
+
+	// Persisted data belongs to the minority network.
+	SetNetwork(877933390)
+	defer SetNetwork(0)
+
+	UseFullnodes(mainnet1.URL, mainnet2.URL, testnet.URL)
+
+	quarantinedNodes = GetQuarantinedNodes()
+	if len(quarantinedNodes) != 2 || quarantinedNodes[testnet.URL] != "" {
+		t.Fatalf("Fullnodes not on the expected network must be quarantined, got %v", quarantinedNodes)
+	}
+
+	if url, ok, _ := selectNode(0, nil); !ok || url != testnet.URL {
+		t.Fatalf("Quarantined fullnodes must not be selected, got %s", url)
+	}
+}
+
+func TestGetReferenceNetwork(t *testing.T) {
+	log.Init(true)
+	defer func() {
+		os.RemoveAll("./logs")
+	}()
+
+	defer func() {
+		network = nil
+	}()
+
+	// Settings of the same network may differ in fields not compared.
+	settings := map[string]ProtocolSettings{
+		"node1": {Network: 860833102, AddressVersion: 53, MaxTraceableBlocks: 2102400},
+		"node2": {Network: 860833102, AddressVersion: 53, MaxTraceableBlocks: 1000},
+		"node3": {Network: 877933390, AddressVersion: 53, MaxTraceableBlocks: 2102400},
+	}
+
+	// Map iteration order is random, the majority must win in any order.
+	for i := 0; i < 20; i++ {
+		network = nil
+
+		reference, ok := getReferenceNetwork(settings)
+		if !ok || reference.Network != 860833102 {
+			t.Fatalf("Network must be determined by majority, got %d", reference.Network)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package rpc
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// ContractParameterType is the type of contract parameters.
+type ContractParameterType string
+
+// Contract parameter types.
+const (
+	AnyType       ContractParameterType = "Any"
+	BooleanType   ContractParameterType = "Boolean"
+	IntegerType   ContractParameterType = "Integer"
+	ByteArrayType ContractParameterType = "ByteArray"
+	StringType    ContractParameterType = "String"
+	Hash160Type   ContractParameterType = "Hash160"
+	Hash256Type   ContractParameterType = "Hash256"
+	PublicKeyType ContractParameterType = "PublicKey"
+	SignatureType ContractParameterType = "Signature"
+	ArrayType     ContractParameterType = "Array"
+	MapType       ContractParameterType = "Map"
+)
+
+// ContractParameter is a typed parameter of contract invocations.
+// Use the constructors to create parameters of the matched value types.
+type ContractParameter struct {
+	Type  ContractParameterType
+	Value interface{}
+}
+
+// ContractParameterMapEntry is a key-value pair of Map parameters.
+type ContractParameterMapEntry struct {
+	Key   ContractParameter `json:"key"`
+	Value ContractParameter `json:"value"`
+}
+
+// NewAnyParam creates a null parameter.
+func NewAnyParam() ContractParameter {
+	return ContractParameter{Type: AnyType}
+}
+
+// NewBoolParam creates a Boolean parameter.
+func NewBoolParam(value bool) ContractParameter {
+	return ContractParameter{Type: BooleanType, Value: value}
+}
+
+// NewIntegerParam creates an Integer parameter.
+func NewIntegerParam(value *big.Int) ContractParameter {
+	return ContractParameter{Type: IntegerType, Value: value}
+}
+
+// NewInt64Param creates an Integer parameter from int64.
+func NewInt64Param(value int64) ContractParameter {
+	return NewIntegerParam(big.NewInt(value))
+}
+
+// NewByteArrayParam creates a ByteArray parameter.
+func NewByteArrayParam(value []byte) ContractParameter {
+	return ContractParameter{Type: ByteArrayType, Value: value}
+}
+
+// NewStringParam creates a String parameter.
+func NewStringParam(value string) ContractParameter {
+	return ContractParameter{Type: StringType, Value: value}
+}
+
+// NewHash160Param creates a Hash160 parameter from big-endian hex string,
+// e.g., script hashes of addresses or contracts.
+func NewHash160Param(hash string) ContractParameter {
+	return ContractParameter{Type: Hash160Type, Value: hash}
+}
+
+// NewHash256Param creates a Hash256 parameter from big-endian hex string.
+func NewHash256Param(hash string) ContractParameter {
+	return ContractParameter{Type: Hash256Type, Value: hash}
+}
+
+// NewPublicKeyParam creates a PublicKey parameter from encoded public key.
+func NewPublicKeyParam(publicKey []byte) ContractParameter {
+	return ContractParameter{Type: PublicKeyType, Value: publicKey}
+}
+
+// NewSignatureParam creates a Signature parameter.
+func NewSignatureParam(signature []byte) ContractParameter {
+	return ContractParameter{Type: SignatureType, Value: signature}
+}
+
+// NewArrayParam creates an Array parameter.
+func NewArrayParam(items ...ContractParameter) ContractParameter {
+	if items == nil {
+		items = []ContractParameter{}
+	}
+
+	return ContractParameter{Type: ArrayType, Value: items}
+}
+
+// NewMapParam creates a Map parameter.
+func NewMapParam(entries ...ContractParameterMapEntry) ContractParameter {
+	if entries == nil {
+		entries = []ContractParameterMapEntry{}
+	}
+
+	return ContractParameter{Type: MapType, Value: entries}
+}
+
+type contractParameterJSON struct {
+	Type  ContractParameterType `json:"type"`
+	Value interface{}           `json:"value,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p ContractParameter) MarshalJSON() ([]byte, error) {
+	value, err := p.jsonValue()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(contractParameterJSON{
+		Type:  p.Type,
+		Value: value,
+	})
+}
+
+func (p ContractParameter) jsonValue() (interface{}, error) {
+	switch p.Type {
+	case AnyType:
+		if p.Value == nil {
+			return nil, nil
+		}
+	case BooleanType:
+		if v, ok := p.Value.(bool); ok {
+			return v, nil
+		}
+	case IntegerType:
+		if v, ok := p.Value.(*big.Int); ok && v != nil {
+			return v.String(), nil
+		}
+	case ByteArrayType, SignatureType:
+		if v, ok := p.Value.([]byte); ok {
+			return base64.StdEncoding.EncodeToString(v), nil
+		}
+	case StringType, Hash160Type, Hash256Type:
+		if v, ok := p.Value.(string); ok {
+			return v, nil
+		}
+	case PublicKeyType:
+		if v, ok := p.Value.([]byte); ok {
+			return hex.EncodeToString(v), nil
+		}
+	case ArrayType:
+		if v, ok := p.Value.([]ContractParameter); ok {
+			return v, nil
+		}
+	case MapType:
+		if v, ok := p.Value.([]ContractParameterMapEntry); ok {
+			return v, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported contract parameter type %q", p.Type)
+	}
+
+	return nil, fmt.Errorf("invalid value of contract parameter type %s: %T(%v)", p.Type, p.Value, p.Value)
+}
@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestContractParameterJSON(t *testing.T) {
+	amount, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+
+	cases := []struct {
+		param    ContractParameter
+		expected string
+	}{
+		{NewAnyParam(), `{"type":"Any"}`},
+		{NewBoolParam(false), `{"type":"Boolean","value":false}`},
+		{NewIntegerParam(amount), `{"type":"Integer","value":"123456789012345678901234567890"}`},
+		{NewInt64Param(-1), `{"type":"Integer","value":"-1"}`},
+		{NewByteArrayParam([]byte("neo")), `{"type":"ByteArray","value":"bmVv"}`},
+		{NewStringParam(`"quoted"\`), `{"type":"String","value":"\"quoted\"\\"}`},
+		{NewHash160Param("b3f1f587042a20dd0eef2e47f137504f1419b054"), `{"type":"Hash160","value":"b3f1f587042a20dd0eef2e47f137504f1419b054"}`},
+		{NewPublicKeyParam([]byte{0x02, 0xab}), `{"type":"PublicKey","value":"02ab"}`},
+		{NewSignatureParam([]byte{0x01}), `{"type":"Signature","value":"AQ=="}`},
+		{NewArrayParam(), `{"type":"Array","value":[]}`},
+		{
+			NewArrayParam(NewInt64Param(1), NewArrayParam(NewStringParam("a"))),
+			`{"type":"Array","value":[{"type":"Integer","value":"1"},{"type":"Array","value":[{"type":"String","value":"a"}]}]}`,
+		},
+		{
+			NewMapParam(ContractParameterMapEntry{Key: NewStringParam("k"), Value: NewBoolParam(true)}),
+			`{"type":"Map","value":[{"key":{"type":"String","value":"k"},"value":{"type":"Boolean","value":true}}]}`,
+		},
+	}
+
+	for _, c := range cases {
+		data, err := json.Marshal(c.param)
+		if err != nil {
+			t.Fatalf("Failed to marshal %s parameter: %v", c.param.Type, err)
+		}
+
+		if string(data) != c.expected {
+			t.Fatalf("Incorrect %s parameter json, expected %s, got %s", c.param.Type, c.expected, string(data))
+		}
+	}
+
+	invalid := ContractParameter{Type: IntegerType, Value: "1"}
+	if _, err := json.Marshal(invalid); err == nil {
+		t.Fatal("Mismatched parameter value must fail to marshal")
+	}
+}
+
+func TestGenerateRequestBody(t *testing.T) {
+	body, err := generateRequestBody("invokefunction", []interface{}{
+		"0xd2a4cff31913016155e38e474a2c06d08be276cf",
+		"balanceOf",
+		[]ContractParameter{NewHash160Param("b3f1f587042a20dd0eef2e47f137504f1419b054")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"jsonrpc":"2.0","method":"invokefunction","params":["0xd2a4cff31913016155e38e474a2c06d08be276cf","balanceOf",` +
+		`[{"type":"Hash160","value":"b3f1f587042a20dd0eef2e47f137504f1419b054"}]],"id":1}`
+	if body != expected {
+		t.Fatalf("Incorrect request body, expected %s, got %s", expected, body)
+	}
+}
+
+func TestInvokeFunctionInvalidParam(t *testing.T) {
+	invalid := []ContractParameter{{Type: IntegerType, Value: "1"}}
+
+	// Mismatched parameters fail before sent to any fullnode.
+	if result, err := InvokeFunction(0, "0xd2a4cff31913016155e38e474a2c06d08be276cf", "balanceOf", invalid); err == nil || result != nil {
+		t.Fatalf("Invocation with mismatched parameters must fail, got %+v", result)
+	}
+
+	if result, ok, err := InvokeFunctionHistoric(0, "0xd2a4cff31913016155e38e474a2c06d08be276cf", "balanceOf", invalid); err == nil || ok || result != nil {
+		t.Fatalf("Historic invocation with mismatched parameters must fail, got %+v", result)
+	}
+}
@@ -0,0 +1,297 @@
+package rpc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// record is a request/response pair of a single call written to disk.
+type record struct {
+	URL      string          `json:"url"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response"`
+}
+
+// call is a single JSON-RPC call of a request body.
+type call struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// recordKey identifies records of calls with the same method and params,
+// regardless of request ids and batches the calls were sent in.
+func recordKey(c call) string {
+	params := bytes.Buffer{}
+	if err := json.Compact(&params, c.Params); err != nil {
+		params.Write(c.Params)
+	}
+
+	hash := sha256.Sum256(append([]byte(c.Method+"\n"), params.Bytes()...))
+	return hex.EncodeToString(hash[:])
+}
+
+// splitCalls splits the request body into raw calls,
+// and tells if the body is a batch request.
+func splitCalls(body []byte) ([]json.RawMessage, bool, error) {
+	body = bytes.TrimSpace(body)
+	if !bytes.HasPrefix(body, []byte("[")) {
+		return []json.RawMessage{body}, false, nil
+	}
+
+	raws := []json.RawMessage{}
+	if err := json.Unmarshal(body, &raws); err != nil {
+		return nil, true, err
+	}
+
+	return raws, true, nil
+}
+
+// splitResponses maps responses of the request body by their ids.
+func splitResponses(respBody []byte, batch bool) (map[string]json.RawMessage, error) {
+	raws := []json.RawMessage{respBody}
+	if batch {
+		raws = nil
+		if err := json.Unmarshal(respBody, &raws); err != nil {
+			return nil, err
+		}
+	}
+
+	responses := map[string]json.RawMessage{}
+	for _, raw := range raws {
+		resp := struct {
+			ID json.RawMessage `json:"id"`
+		}{}
+		if err := json.Unmarshal(raw, &resp); err != nil {
+			return nil, err
+		}
+
+		responses[string(resp.ID)] = raw
+	}
+
+	return responses, nil
+}
+
+// withID replaces the id of the recorded response.
+func withID(resp json.RawMessage, id json.RawMessage) (json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(resp, &fields); err != nil {
+		return nil, err
+	}
+
+	fields["id"] = id
+	return json.Marshal(fields)
+}
+
+// withoutID returns the recorded response without its id,
+// to compare responses of the same call.
+func withoutID(resp json.RawMessage) (string, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(resp, &fields); err != nil {
+		return "", err
+	}
+
+	delete(fields, "id")
+	data, err := json.Marshal(fields)
+	return string(data), err
+}
+
+// RecordTransport writes every request/response pair of the wrapped
+// transport into a directory. Responses same as the last recorded one
+// of the call are not recorded again, so that polling calls such as
+// getblockcount only record changes, which replays the same way.
+type RecordTransport struct {
+	inner Transport
+	dir   string
+
+	mu sync.Mutex
+	// seq counts records of each call.
+	seq map[string]int
+	// last is the last recorded response of each call without id.
+	last map[string]string
+}
+
+// NewRecordTransport creates a record transport writing to the given directory.
+func NewRecordTransport(inner Transport, dir string) (*RecordTransport, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &RecordTransport{
+		inner: inner,
+		dir:   dir,
+		seq:   map[string]int{},
+		last:  map[string]string{},
+	}, nil
+}
+
+// Post implements Transport. Calls of batch requests are recorded one by one.
+func (t *RecordTransport) Post(url string, body []byte) ([]byte, error) {
+	respBody, err := t.inner.Post(url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Not a valid json request or response, returns the response as is.
+	raws, batch, err := splitCalls(body)
+	if err != nil {
+		return respBody, nil
+	}
+
+	responses, err := splitResponses(respBody, batch)
+	if err != nil {
+		return respBody, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, raw := range raws {
+		c := call{}
+		if err := json.Unmarshal(raw, &c); err != nil {
+			continue
+		}
+
+		resp, ok := responses[string(c.ID)]
+		if !ok {
+			continue
+		}
+
+		key := recordKey(c)
+		content, err := withoutID(resp)
+		if err != nil {
+			continue
+		}
+
+		if last, ok := t.last[key]; ok && last == content {
+			continue
+		}
+
+		data, err := json.MarshalIndent(record{
+			URL:      url,
+			Request:  raw,
+			Response: resp,
+		}, "", "    ")
+		if err != nil {
+			continue
+		}
+
+		path := filepath.Join(t.dir, fmt.Sprintf("%s-%06d.json", key, t.seq[key]))
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			return nil, err
+		}
+
+		t.seq[key]++
+		t.last[key] = content
+	}
+
+	return respBody, nil
+}
+
+// ReplayTransport serves responses recorded by RecordTransport. Responses
+// of the same call are served in recorded order, and the last one is
+// repeated once all served. Calls are matched by method and params,
+// so batches may be split differently from the recorded run.
+type ReplayTransport struct {
+	mu sync.Mutex
+	// map[key(string)][]response
+	responses map[string][]json.RawMessage
+	// served counts responses served of each call.
+	served map[string]int
+}
+
+// NewReplayTransport loads recorded responses from the given directory.
+func NewReplayTransport(dir string) (*ReplayTransport, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort files to keep the recorded order.
+	sort.Strings(files)
+
+	t := &ReplayTransport{
+		responses: map[string][]json.RawMessage{},
+		served:    map[string]int{},
+	}
+
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		r := record{}
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+
+		key := strings.SplitN(filepath.Base(file), "-", 2)[0]
+		t.responses[key] = append(t.responses[key], r.Response)
+	}
+
+	if len(t.responses) == 0 {
+		return nil, fmt.Errorf("no records found in %s", dir)
+	}
+
+	return t, nil
+}
+
+// Post implements Transport.
+func (t *ReplayTransport) Post(url string, body []byte) ([]byte, error) {
+	raws, batch, err := splitCalls(body)
+	if err != nil {
+		return nil, &TransportError{URL: url, Kind: ErrUnknown, Err: err}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	replies := []json.RawMessage{}
+	for _, raw := range raws {
+		c := call{}
+		if err := json.Unmarshal(raw, &c); err != nil {
+			return nil, &TransportError{URL: url, Kind: ErrUnknown, Err: err}
+		}
+
+		key := recordKey(c)
+		responses, ok := t.responses[key]
+		if !ok {
+			return nil, &TransportError{
+				URL:  url,
+				Kind: ErrUnknown,
+				Err:  errors.New("no recorded response of request " + string(raw)),
+			}
+		}
+
+		i := t.served[key]
+		if i >= len(responses) {
+			i = len(responses) - 1
+		}
+
+		t.served[key]++
+
+		reply, err := withID(responses[i], c.ID)
+		if err != nil {
+			return nil, &TransportError{URL: url, Kind: ErrUnknown, Err: err}
+		}
+
+		replies = append(replies, reply)
+	}
+
+	if !batch {
+		return replies[0], nil
+	}
+
+	return json.Marshal(replies)
+}
@@ -0,0 +1,168 @@
+package rpc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"neo3-squirrel/tests/fullnode"
+	"neo3-squirrel/util/log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	log.Init(true)
+	dir, err := ioutil.TempDir("", "neo3-squirrel-record")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll("./logs")
+		os.RemoveAll(dir)
+		SetTransport(NewHTTPTransport())
+	}()
+
+	node := fullnode.New()
+	if err := node.LoadFixtures(fullnode.Testdata()); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder, err := NewRecordTransport(NewHTTPTransport(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetTransport(recorder)
+	UseFullnodes(node.URL)
+
+	recordedBlock := SyncBlock(1)
+	if recordedBlock == nil || len(recordedBlock.Tx) == 0 {
+		t.Fatal("Failed to get block of index 1")
+	}
+
+	recordedAppLog := GetApplicationLog(1, recordedBlock.Tx[0].Hash, nil)
+
+	// Replay without any fullnode.
+	node.Close()
+
+	replayer, err := NewReplayTransport(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetTransport(replayer)
+	UseFullnodes(node.URL)
+
+	if GetBestHeight() != 2 {
+		t.Fatalf("Incorrect replayed best height, expected 2, got %d", GetBestHeight())
+	}
+
+	if block := SyncBlock(1); !reflect.DeepEqual(block, recordedBlock) {
+		t.Fatalf("Replayed block mismatch, expected %+v, got %+v", recordedBlock, block)
+	}
+
+	if appLog := GetApplicationLog(1, recordedBlock.Tx[0].Hash, nil); !reflect.DeepEqual(appLog, recordedAppLog) {
+		t.Fatalf("Replayed applicationlog mismatch, expected %+v, got %+v", recordedAppLog, appLog)
+	}
+}
+
+func TestReplayBatchesSplitDifferently(t *testing.T) {
+	log.Init(true)
+	dir, err := ioutil.TempDir("", "neo3-squirrel-record")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll("./logs")
+		os.RemoveAll(dir)
+		SetTransport(NewHTTPTransport())
+	}()
+
+	node := fullnode.New()
+	if err := node.LoadFixtures(fullnode.Testdata()); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder, err := NewRecordTransport(NewHTTPTransport(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetTransport(recorder)
+	UseFullnodes(node.URL)
+
+	recorded := SyncBlocks([]uint{0, 1, 2})
+	if len(recorded) != 3 {
+		t.Fatalf("Failed to get blocks 0-2, got %d blocks", len(recorded))
+	}
+
+	node.Close()
+
+	replayer, err := NewReplayTransport(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetTransport(replayer)
+	UseFullnodes(node.URL)
+
+	if block := SyncBlock(2); !reflect.DeepEqual(block, recorded[2]) {
+		t.Fatalf("Replayed block mismatch, expected %+v, got %+v", recorded[2], block)
+	}
+
+	blocks := SyncBlocks([]uint{1, 0})
+	if len(blocks) != 2 || !reflect.DeepEqual(blocks[0], recorded[1]) || !reflect.DeepEqual(blocks[1], recorded[0]) {
+		t.Fatalf("Replayed batch mismatch, got %+v", blocks)
+	}
+}
+
+func TestRecordSkipsRepeatedResponses(t *testing.T) {
+	log.Init(true)
+	dir, err := ioutil.TempDir("", "neo3-squirrel-record")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		os.RemoveAll("./logs")
+		os.RemoveAll(dir)
+	}()
+
+	node := fullnode.New()
+	defer node.Close()
+
+	if err := node.LoadFixtures(fullnode.Testdata()); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder, err := NewRecordTransport(node, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	poll := func(id int) {
+		body := fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"getblockcount","params":[]}`, id)
+		if _, err := recorder.Post(node.URL, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Polling the same height is recorded once, a new height is recorded again.
+	poll(1)
+	poll(2)
+	node.SetHeight(1)
+	poll(3)
+	poll(4)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 records of getblockcount, got %d", len(files))
+	}
+}
@@ -5,120 +5,172 @@ import (
 	"errors"
 	"fmt"
 	"neo3-squirrel/util/log"
-	"strings"
 	"sync"
 	"time"
 
 	eParser "github.com/go-errors/errors"
-	"github.com/valyala/fasthttp"
 )
 
-var (
-	client = &fasthttp.Client{
-		MaxConnWaitTimeout: 10 * time.Second,
+var reqLock sync.RWMutex
+
+type responseCommon struct {
+	JSONRPC string    `json:"jsonrpc"`
+	ID      int       `json:"id"`
+	Error   *RPCError `json:"error"`
+}
+
+type requestBody struct {
+	JSONRPC string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+	ID      int           `json:"id"`
+}
+
+func generateRequestBody(method string, params []interface{}) (string, error) {
+	return generateRequestBodyWithID(1, method, params)
+}
+
+// generateBatchRequestBody generates a JSON-RPC batch request,
+// the id of each request is its index in paramsList.
+func generateBatchRequestBody(method string, paramsList [][]interface{}) (string, error) {
+	bodies := make([]requestBody, len(paramsList))
+	for i, params := range paramsList {
+		bodies[i] = newRequestBody(i, method, params)
 	}
 
-	reqLock sync.RWMutex
-)
+	return marshalRequestBody(bodies)
+}
 
-type responseCommon struct {
-	JSONRPC string `json:"jsonrpc"`
-	ID      int    `json:"id"`
-	Error   *struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-	} `json:"error"`
+func generateRequestBodyWithID(id int, method string, params []interface{}) (string, error) {
+	return marshalRequestBody(newRequestBody(id, method, params))
 }
 
-func generateRequestBody(method string, params []interface{}) string {
-	p := ""
-
-	for _, param := range params {
-		switch param := param.(type) {
-		case int8, uint8,
-			int16, uint16,
-			int, uint,
-			int32, uint32,
-			int64, uint64:
-			p += fmt.Sprintf("%d, ", param)
-		case string:
-			p += fmt.Sprintf("\"%s\", ", param)
-		case []StackItem:
-			parsed := ""
-			for _, stackItem := range param {
-				parsed += fmt.Sprintf(", {\"type\": \"%s\", \"value\": \"%s\"}", stackItem.Type, stackItem.Value)
-			}
-			p += fmt.Sprintf("[%s], ", parsed[2:])
-		default:
-			err := fmt.Errorf("the RPC parameter type must be integer or string. current type=%T, value=%v", param, param)
-			panic(err)
-		}
+func newRequestBody(id int, method string, params []interface{}) requestBody {
+	if params == nil {
+		params = []interface{}{}
 	}
 
-	if p != "" {
-		p = p[:len(p)-2]
+	return requestBody{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+		ID:      id,
 	}
+}
 
-	body := `{
-		"jsonrpc": "2.0",
-		"method": "` + method + `",
-		"params": [
-			` + p + `
-		],
-		"id": 1
+// marshalRequestBody encodes the request body, it fails
+// if any contract parameter mismatches its type.
+func marshalRequestBody(body interface{}) (string, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode rpc request: %v", err)
 	}
-	`
-	return body
+
+	return string(data), nil
+}
+
+func request(minHeight uint, params string, target interface{}) string {
+	return requestTo(minHeight, params, target, nil)
 }
 
-func request(minHeight uint, params string, target interface{}) {
+// requestTo works like request but only sends to fullnodes accepted
+// by the filter. Returns the url of the responding node.
+func requestTo(minHeight uint, params string, target interface{}, filter func(url string) bool) string {
+	bodyBytes, url, ok := postTo(minHeight, params, filter)
+	if !ok {
+		return ""
+	}
+
+	err := json.Unmarshal(bodyBytes, target)
+	if err != nil {
+		log.Error(errors.New(eParser.Wrap(err, 0).ErrorStack()))
+		log.Errorf("Request body: %v", params)
+		log.Errorf("Response: %v", string(bodyBytes))
+	}
+
+	return url
+}
+
+// requestBatch sends a JSON-RPC batch request and decodes each response
+// into the target of its id. Targets of failed requests are left untouched.
+// Returns the url of the responding node.
+func requestBatch(minHeight uint, params string, targets []interface{}) string {
+	bodyBytes, url, ok := postTo(minHeight, params, nil)
+	if !ok {
+		return ""
+	}
+
+	rawResps := []json.RawMessage{}
+	err := json.Unmarshal(bodyBytes, &rawResps)
+	if err != nil {
+		log.Error(errors.New(eParser.Wrap(err, 0).ErrorStack()))
+		log.Errorf("Request body: %v", params)
+		log.Errorf("Response: %v", string(bodyBytes))
+		return url
+	}
+
+	for _, rawResp := range rawResps {
+		common := responseCommon{}
+		if err := json.Unmarshal(rawResp, &common); err != nil {
+			log.Error(err)
+			continue
+		}
+
+		if common.ID < 0 || common.ID >= len(targets) {
+			log.Errorf("Unexpected batch response id %d: %s", common.ID, string(rawResp))
+			continue
+		}
+
+		if err := json.Unmarshal(rawResp, targets[common.ID]); err != nil {
+			log.Error(err)
+			log.Errorf("Response: %v", string(rawResp))
+		}
+	}
+
+	return url
+}
+
+// postTo sends the request body to a fullnode whose height is not lower
+// than minHeight and accepted by the filter, and returns the response
+// body and the url of the responding node.
+func postTo(minHeight uint, params string, filter func(url string) bool) ([]byte, string, bool) {
 	reqLock.RLock()
 	// log.Debugf("rpc request: minHeight=%d, params=%s", minHeight, params)
 
 	requestBody := []byte(params)
-	resp := fasthttp.AcquireResponse()
-	req := fasthttp.AcquireRequest()
-	req.Header.SetMethod("POST")
-	req.SetBody(requestBody)
 
 	for {
-		url, ok, currBestHeight := selectNode(minHeight)
+		url, ok, currBestHeight := selectNode(minHeight, filter)
 		if !ok {
 			time.Sleep(50 * time.Millisecond)
 			if allNodesDown || currBestHeight == -1 {
 				reqLock.RUnlock()
-				request(minHeight, params, target)
-				return
+				return postTo(minHeight, params, filter)
 			}
 
 			reqLock.RUnlock()
-			return
+			return nil, "", false
 		}
 
-		req.SetRequestURI(url)
-		err := client.Do(req, resp)
+		start := time.Now()
+		respBody, err := transport.Post(url, requestBody)
 		if err != nil {
-			if !strings.Contains(err.Error(), "timed out") &&
-				!strings.Contains(err.Error(), "connection refused") &&
-				!strings.Contains(err.Error(), "the server closed connection before returning the first response byte.") {
+			var tErr *TransportError
+			if !errors.As(err, &tErr) || !tErr.Expected() {
 				log.Error(err)
 			}
-			nodeUnavailable(url)
+
+			recordFailure(url)
+			getBreaker(url).failure()
 			time.Sleep(100 * time.Millisecond)
 			continue
 		}
 
-		break
-	}
+		recordSuccess(url, time.Since(start))
+		getBreaker(url).success()
 
-	bodyBytes := resp.Body()
+		reqLock.RUnlock()
 
-	err := json.Unmarshal(bodyBytes, target)
-	if err != nil {
-		log.Error(errors.New(eParser.Wrap(err, 0).ErrorStack()))
-		log.Errorf("Request body: %v", string(requestBody))
-		log.Errorf("Response: %v", string(bodyBytes))
+		return respBody, url, true
 	}
-
-	reqLock.RUnlock()
 }
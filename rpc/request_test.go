@@ -0,0 +1,76 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"neo3-squirrel/util/log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRequestBatch(t *testing.T) {
+	log.Init(true)
+	defer func() {
+		os.RemoveAll("./logs")
+	}()
+
+	// Responds batch requests in reverse order, with the request of id 1 failed.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if strings.Contains(string(body), "getversion") {
+			fmt.Fprint(w, `{"jsonrpc": "2.0", "id": 1, "result": {"protocol": {"network": 860833102, "addressversion": 53}}}`)
+			return
+		}
+
+		if !strings.HasPrefix(string(body), "[") {
+			fmt.Fprint(w, `{"jsonrpc": "2.0", "id": 1, "result": 100}`)
+			return
+		}
+
+		reqs := []struct {
+			ID     int           `json:"id"`
+			Params []interface{} `json:"params"`
+		}{}
+		if err := json.Unmarshal(body, &reqs); err != nil {
+			t.Error(err)
+			return
+		}
+
+		resps := []string{}
+		for i := len(reqs) - 1; i >= 0; i-- {
+			req := reqs[i]
+			if req.ID == 1 {
+				resps = append(resps, `{"jsonrpc": "2.0", "id": 1, "error": {"code": -100, "message": "Unknown block"}}`)
+				continue
+			}
+
+			resps = append(resps, fmt.Sprintf(`{"jsonrpc": "2.0", "id": %d, "result": {"index": %v}}`, req.ID, req.Params[0]))
+		}
+
+		fmt.Fprint(w, "["+strings.Join(resps, ",")+"]")
+	}))
+	defer server.Close()
+
+	UseFullnodes(server.URL)
+
+	blocks := SyncBlocks([]uint{10, 11, 12})
+	if len(blocks) != 3 {
+		t.Fatalf("Incorrect 'SyncBlocks' result size, expected 3, got %d", len(blocks))
+	}
+
+	if blocks[0] == nil || blocks[0].Index != 10 {
+		t.Fatalf("Incorrect 'SyncBlocks' result of index 10: %+v", blocks[0])
+	}
+
+	if blocks[1] != nil {
+		t.Fatalf("Failed request of index 11 must be nil, got %+v", blocks[1])
+	}
+
+	if blocks[2] == nil || blocks[2].Index != 12 {
+		t.Fatalf("Incorrect 'SyncBlocks' result of index 12: %+v", blocks[2])
+	}
+}
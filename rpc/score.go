@@ -0,0 +1,195 @@
+package rpc
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// latencyAlpha is the smoothing factor of the latency EWMA.
+	latencyAlpha = 0.2
+	// defaultLatency is assumed for nodes without any response yet.
+	defaultLatency = 100.0
+	// maxFailures marks a node unhealthy after consecutive failures.
+	maxFailures = 3
+)
+
+// nodeScore traces the health of a fullnode.
+type nodeScore struct {
+	mu sync.Mutex
+
+	weight   float64
+	priority int
+
+	// latency is the EWMA of response time in milliseconds.
+	latency float64
+	// failures counts consecutive failed requests.
+	failures int
+	// totalFailures counts all failed requests.
+	totalFailures uint64
+}
+
+type candidate struct {
+	url    string
+	height int
+}
+
+// map[url(string)]*nodeScore
+var scores sync.Map
+
+func getScore(url string) *nodeScore {
+	score, _ := scores.LoadOrStore(url, &nodeScore{
+		weight:  1,
+		latency: defaultLatency,
+	})
+
+	return score.(*nodeScore)
+}
+
+func setNodeOptions(url string, weight float64, priority int) {
+	score := getScore(url)
+	score.mu.Lock()
+	defer score.mu.Unlock()
+
+	score.weight = weight
+	score.priority = priority
+}
+
+func recordSuccess(url string, elapsed time.Duration) {
+	score := getScore(url)
+	score.mu.Lock()
+	defer score.mu.Unlock()
+
+	ms := float64(elapsed) / float64(time.Millisecond)
+	score.latency = latencyAlpha*ms + (1-latencyAlpha)*score.latency
+	score.failures = 0
+}
+
+func recordFailure(url string) {
+	score := getScore(url)
+	score.mu.Lock()
+	defer score.mu.Unlock()
+
+	score.failures++
+	score.totalFailures++
+}
+
+func (score *nodeScore) healthy() bool {
+	score.mu.Lock()
+	defer score.mu.Unlock()
+
+	return score.failures < maxFailures
+}
+
+func (score *nodeScore) getPriority() int {
+	score.mu.Lock()
+	defer score.mu.Unlock()
+
+	return score.priority
+}
+
+// value returns the selection score of the node, higher is better.
+// It decreases with latency, consecutive failures and height lag.
+func (score *nodeScore) value(lag int) float64 {
+	score.mu.Lock()
+	defer score.mu.Unlock()
+
+	latency := score.latency
+	if latency < 1 {
+		latency = 1
+	}
+
+	failurePenalty := float64(1 + score.failures*score.failures)
+
+	return score.weight / latency / failurePenalty / float64(1+lag)
+}
+
+// pickNode picks a node among the healthy ones with the highest
+// priority, with a chance in proportion to its score.
+func pickNode(candidates []candidate, bestHeight int) string {
+	pool := []candidate{}
+	for _, c := range candidates {
+		if getScore(c.url).healthy() {
+			pool = append(pool, c)
+		}
+	}
+
+	// Try all nodes if none is healthy.
+	if len(pool) == 0 {
+		pool = candidates
+	}
+
+	topPriority := getScore(pool[0].url).getPriority()
+	for _, c := range pool[1:] {
+		if p := getScore(c.url).getPriority(); p > topPriority {
+			topPriority = p
+		}
+	}
+
+	top := []candidate{}
+	values := []float64{}
+	total := 0.0
+	for _, c := range pool {
+		score := getScore(c.url)
+		if score.getPriority() != topPriority {
+			continue
+		}
+
+		v := score.value(bestHeight - c.height)
+		top = append(top, c)
+		values = append(values, v)
+		total += v
+	}
+
+	if total <= 0 {
+		return top[rand.Intn(len(top))].url
+	}
+
+	r := rand.Float64() * total
+	for i, v := range values {
+		r -= v
+		if r < 0 {
+			return top[i].url
+		}
+	}
+
+	return top[len(top)-1].url
+}
+
+// NodeHealth summarizes the health of traced fullnodes.
+type NodeHealth struct {
+	Total   int
+	Healthy int
+	// Latency is the average response time of healthy nodes in milliseconds.
+	Latency float64
+}
+
+// GetNodeHealth returns the health summary of traced fullnodes.
+func GetNodeHealth() NodeHealth {
+	health := NodeHealth{}
+
+	for url, height := range getNodes() {
+		health.Total++
+
+		score := getScore(url)
+		if height < 0 ||
+			isQuarantined(url) ||
+			!getBreaker(url).available() ||
+			!score.healthy() {
+			continue
+		}
+
+		health.Healthy++
+
+		score.mu.Lock()
+		health.Latency += score.latency
+		score.mu.Unlock()
+	}
+
+	if health.Healthy > 0 {
+		health.Latency /= float64(health.Healthy)
+	}
+
+	return health
+}
@@ -0,0 +1,56 @@
+package rpc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPickNode(t *testing.T) {
+	const (
+		fast   = "http://fast:10332"
+		slow   = "http://slow:10332"
+		broken = "http://broken:10332"
+		backup = "http://backup:10332"
+	)
+
+	for i := 0; i < 20; i++ {
+		recordSuccess(fast, 10*time.Millisecond)
+		recordSuccess(slow, 1000*time.Millisecond)
+	}
+	for i := 0; i < maxFailures; i++ {
+		recordFailure(broken)
+	}
+	setNodeOptions(fast, 1, 1)
+	setNodeOptions(slow, 1, 1)
+	setNodeOptions(broken, 100, 1)
+	setNodeOptions(backup, 100, 0)
+
+	candidates := []candidate{
+		{url: fast, height: 100},
+		{url: slow, height: 100},
+		{url: broken, height: 100},
+		{url: backup, height: 100},
+	}
+
+	picked := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		picked[pickNode(candidates, 100)]++
+	}
+
+	if picked[broken] > 0 {
+		t.Fatalf("Unhealthy node must not be picked, picked %d times", picked[broken])
+	}
+
+	if picked[backup] > 0 {
+		t.Fatalf("Node of lower priority must not be picked, picked %d times", picked[backup])
+	}
+
+	if picked[fast] < 900 {
+		t.Fatalf("Low latency node must be preferred, picked %d/1000 times", picked[fast])
+	}
+
+	// Fall back to nodes of lower priority.
+	if url := pickNode(candidates[2:], 100); url != backup {
+		t.Fatalf("Healthy node must be picked, got %s", url)
+	}
+}
@@ -1,11 +1,45 @@
 package rpc
 
-import "math/big"
+import (
+	"encoding/json"
+	"math/big"
+)
 
 // Signer is the raw transaction signer structure.
 type Signer struct {
-	Account string `json:"account"`
-	Scopes  string `json:"scopes"`
+	Account          string        `json:"account"`
+	Scopes           string        `json:"scopes"`
+	AllowedContracts []string      `json:"allowedcontracts,omitempty"`
+	AllowedGroups    []string      `json:"allowedgroups,omitempty"`
+	Rules            []WitnessRule `json:"rules,omitempty"`
+}
+
+// WitnessRule is the raw witness rule structure of signers.
+type WitnessRule struct {
+	Action    string           `json:"action"`
+	Condition WitnessCondition `json:"condition"`
+}
+
+// WitnessCondition is the raw witness condition structure.
+// Expression is a bool for Boolean conditions and a nested
+// condition for Not conditions.
+type WitnessCondition struct {
+	Type        string             `json:"type"`
+	Expression  json.RawMessage    `json:"expression,omitempty"`
+	Expressions []WitnessCondition `json:"expressions,omitempty"`
+	Hash        string             `json:"hash,omitempty"`
+	Group       string             `json:"group,omitempty"`
+}
+
+// TxAttribute is the raw transaction attribute structure.
+type TxAttribute struct {
+	Type   string `json:"type"`
+	ID     uint64 `json:"id"`
+	Code   string `json:"code"`
+	Result string `json:"result"`
+	Height uint32 `json:"height"`
+	Hash   string `json:"hash"`
+	NKeys  uint8  `json:"nkeys"`
 }
 
 // Tx is the transaction part of block data.
@@ -23,3 +57,22 @@ type Tx struct {
 	Script          string      `json:"script"`
 	Witnesses       []Witness   `json:"witnesses"`
 }
+
+// GetAttributes decodes the attributes of the transaction.
+func (tx *Tx) GetAttributes() ([]TxAttribute, error) {
+	attrs := []TxAttribute{}
+	if tx.Attributes == nil {
+		return attrs, nil
+	}
+
+	data, err := json.Marshal(tx.Attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		return nil, err
+	}
+
+	return attrs, nil
+}
@@ -0,0 +1,63 @@
+package rpc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Transport sends JSON-RPC request bodies to fullnodes.
+type Transport interface {
+	// Post sends the request body to the fullnode of the given url
+	// and returns the response body. Errors should be *TransportError.
+	Post(url string, body []byte) ([]byte, error)
+}
+
+// HTTPTransport posts requests to fullnodes over HTTP.
+type HTTPTransport struct {
+	client *fasthttp.Client
+}
+
+// NewHTTPTransport creates a new HTTP transport.
+func NewHTTPTransport() *HTTPTransport {
+	return &HTTPTransport{
+		client: &fasthttp.Client{
+			MaxConnWaitTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// Post implements Transport.
+func (t *HTTPTransport) Post(url string, body []byte) ([]byte, error) {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.Header.SetMethod("POST")
+	req.SetRequestURI(url)
+	req.SetBody(body)
+
+	if err := t.client.Do(req, resp); err != nil {
+		return nil, newTransportError(url, err)
+	}
+
+	if code := resp.StatusCode(); code != fasthttp.StatusOK {
+		return nil, &TransportError{
+			URL:  url,
+			Kind: ErrBadStatus,
+			Err:  fmt.Errorf("unexpected http status %d", code),
+		}
+	}
+
+	return append([]byte{}, resp.Body()...), nil
+}
+
+var transport Transport = NewHTTPTransport()
+
+// SetTransport replaces the transport of all rpc calls.
+// It must be called before any rpc calls.
+func SetTransport(t Transport) {
+	transport = t
+}
@@ -0,0 +1,415 @@
+package rpc
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"neo3-squirrel/util/base58"
+	"neo3-squirrel/util/byteutil"
+	"neo3-squirrel/util/color"
+	"neo3-squirrel/util/hashutil"
+	"neo3-squirrel/util/serialize"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupported is returned if the block contains
+// fields unknown to the verification.
+var ErrUnsupported = errors.New("unsupported block content")
+
+var witnessScopes = map[string]uint8{
+	"None":            0x00,
+	"CalledByEntry":   0x01,
+	"CustomContracts": 0x10,
+	"CustomGroups":    0x20,
+	"WitnessRules":    0x40,
+	"Global":          0x80,
+}
+
+var witnessRuleActions = map[string]uint8{
+	"Deny":  0x00,
+	"Allow": 0x01,
+}
+
+var witnessConditionTypes = map[string]uint8{
+	"Boolean":          0x00,
+	"Not":              0x01,
+	"And":              0x02,
+	"Or":               0x03,
+	"ScriptHash":       0x18,
+	"Group":            0x19,
+	"CalledByEntry":    0x20,
+	"CalledByContract": 0x28,
+	"CalledByGroup":    0x29,
+}
+
+var txAttributeTypes = map[string]uint8{
+	"HighPriority":   0x01,
+	"OracleResponse": 0x11,
+	"NotValidBefore": 0x20,
+	"Conflicts":      0x21,
+	"NotaryAssisted": 0x22,
+}
+
+var oracleResponseCodes = map[string]uint8{
+	"Success":                 0x00,
+	"ProtocolNotSupported":    0x10,
+	"ConsensusUnreachable":    0x12,
+	"NotFound":                0x14,
+	"Timeout":                 0x16,
+	"Forbidden":               0x18,
+	"ResponseTooLarge":        0x1a,
+	"InsufficientFunds":       0x1c,
+	"ContentTypeNotSupported": 0x1f,
+	"Error":                   0xff,
+}
+
+// Verify recomputes hashes of transactions, the merkle root
+// and the block hash, and compares them with the block content.
+func (b *Block) Verify() error {
+	txHashes := make([][]byte, len(b.Tx))
+	for i := range b.Tx {
+		hash, err := b.Tx[i].ComputeHash()
+		if err != nil {
+			return err
+		}
+
+		if !sameHash(hash, b.Tx[i].Hash) {
+			return fmt.Errorf("transaction hash mismatch, got %s, computed %s",
+				b.Tx[i].Hash, formatHash(hash))
+		}
+
+		txHashes[i] = hash
+	}
+
+	merkleRoot := ComputeMerkleRoot(txHashes)
+	if !sameHash(merkleRoot, b.MerkleRoot) {
+		return fmt.Errorf("merkle root mismatch, got %s, computed %s",
+			b.MerkleRoot, formatHash(merkleRoot))
+	}
+
+	hash, err := b.ComputeHash()
+	if err != nil {
+		return err
+	}
+
+	if !sameHash(hash, b.Hash) {
+		return fmt.Errorf("block hash mismatch, got %s, computed %s",
+			b.Hash, formatHash(hash))
+	}
+
+	return nil
+}
+
+// ComputeHash returns the hash of the block header.
+func (b *Block) ComputeHash() ([]byte, error) {
+	prevHash, err := decodeUInt256(b.PreviousBlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	merkleRoot, err := decodeUInt256(b.MerkleRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := strconv.ParseUint(b.Nonce, 16, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block nonce %q", b.Nonce)
+	}
+
+	nextConsensus, err := base58.CheckDecode(b.NextConsensus)
+	if err != nil || len(nextConsensus) != 21 {
+		return nil, fmt.Errorf("invalid next consensus %q", b.NextConsensus)
+	}
+
+	w := serialize.Writer{}
+	w.WriteU32(uint32(b.Version))
+	w.WriteBytes(prevHash)
+	w.WriteBytes(merkleRoot)
+	w.WriteU64(b.Time)
+	w.WriteU64(nonce)
+	w.WriteU32(uint32(b.Index))
+	w.WriteU8(uint8(b.Primary))
+	w.WriteBytes(nextConsensus[1:])
+
+	return hashutil.Sha256(w.Bytes()), nil
+}
+
+// ComputeHash returns the hash of the transaction.
+func (tx *Tx) ComputeHash() ([]byte, error) {
+	if tx.SysFee == nil || tx.NetFee == nil {
+		return nil, errors.New("transaction fees missing")
+	}
+
+	sysFee, _ := tx.SysFee.Int64()
+	netFee, _ := tx.NetFee.Int64()
+
+	w := serialize.Writer{}
+	w.WriteU8(uint8(tx.Version))
+	w.WriteU32(uint32(tx.Nonce))
+	w.WriteU64(uint64(sysFee))
+	w.WriteU64(uint64(netFee))
+	w.WriteU32(uint32(tx.ValidUntilBlock))
+
+	w.WriteVarUint(uint64(len(tx.Signers)))
+	for _, signer := range tx.Signers {
+		if err := writeSigner(&w, signer); err != nil {
+			return nil, err
+		}
+	}
+
+	attrs, err := tx.GetAttributes()
+	if err != nil {
+		return nil, err
+	}
+
+	w.WriteVarUint(uint64(len(attrs)))
+	for _, attr := range attrs {
+		if err := writeTxAttribute(&w, attr); err != nil {
+			return nil, err
+		}
+	}
+
+	script, err := base64.StdEncoding.DecodeString(tx.Script)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transaction script: %v", err)
+	}
+
+	w.WriteVarBytes(script)
+
+	return hashutil.Sha256(w.Bytes()), nil
+}
+
+// ComputeMerkleRoot returns the merkle root of the transaction hashes.
+func ComputeMerkleRoot(hashes [][]byte) []byte {
+	if len(hashes) == 0 {
+		return make([]byte, 32)
+	}
+
+	for len(hashes) > 1 {
+		parents := make([][]byte, (len(hashes)+1)/2)
+		for i := range parents {
+			left := hashes[i*2]
+			right := left
+			if i*2+1 < len(hashes) {
+				right = hashes[i*2+1]
+			}
+
+			parents[i] = hashutil.Hash256(append(append([]byte{}, left...), right...))
+		}
+
+		hashes = parents
+	}
+
+	return hashes[0]
+}
+
+// ReportInvalidBlock quarantines the fullnode serving the invalid block.
+func ReportInvalidBlock(b *Block, err error) {
+	if b.Source == "" {
+		return
+	}
+
+	quarantine(b.Source, color.Redf("invalid block %d: %v", b.Index, err))
+}
+
+func writeSigner(w *serialize.Writer, signer Signer) error {
+	account, err := decodeUInt160(signer.Account)
+	if err != nil {
+		return err
+	}
+
+	scopes, err := parseWitnessScopes(signer.Scopes)
+	if err != nil {
+		return err
+	}
+
+	w.WriteBytes(account)
+	w.WriteU8(scopes)
+
+	if scopes&witnessScopes["CustomContracts"] != 0 {
+		w.WriteVarUint(uint64(len(signer.AllowedContracts)))
+		for _, contract := range signer.AllowedContracts {
+			hash, err := decodeUInt160(contract)
+			if err != nil {
+				return err
+			}
+
+			w.WriteBytes(hash)
+		}
+	}
+
+	if scopes&witnessScopes["CustomGroups"] != 0 {
+		w.WriteVarUint(uint64(len(signer.AllowedGroups)))
+		for _, group := range signer.AllowedGroups {
+			pubKey, err := decodePublicKey(group)
+			if err != nil {
+				return err
+			}
+
+			w.WriteBytes(pubKey)
+		}
+	}
+
+	if scopes&witnessScopes["WitnessRules"] != 0 {
+		w.WriteVarUint(uint64(len(signer.Rules)))
+		for _, rule := range signer.Rules {
+			action, ok := witnessRuleActions[rule.Action]
+			if !ok {
+				return fmt.Errorf("%w: witness rule action %q", ErrUnsupported, rule.Action)
+			}
+
+			w.WriteU8(action)
+			if err := writeWitnessCondition(w, rule.Condition); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseWitnessScopes(scopes string) (uint8, error) {
+	flags := uint8(0)
+	for _, scope := range strings.Split(scopes, ",") {
+		flag, ok := witnessScopes[strings.TrimSpace(scope)]
+		if !ok {
+			return 0, fmt.Errorf("%w: witness scope %q", ErrUnsupported, scope)
+		}
+
+		flags |= flag
+	}
+
+	return flags, nil
+}
+
+func writeWitnessCondition(w *serialize.Writer, cond WitnessCondition) error {
+	condType, ok := witnessConditionTypes[cond.Type]
+	if !ok {
+		return fmt.Errorf("%w: witness condition %q", ErrUnsupported, cond.Type)
+	}
+
+	w.WriteU8(condType)
+
+	switch cond.Type {
+	case "Boolean":
+		// Expression may be encoded as a bool or a string.
+		switch strings.Trim(strings.ToLower(string(cond.Expression)), `"`) {
+		case "true":
+			w.WriteBool(true)
+		case "false":
+			w.WriteBool(false)
+		default:
+			return fmt.Errorf("invalid boolean condition %s", string(cond.Expression))
+		}
+	case "Not":
+		inner := WitnessCondition{}
+		if err := json.Unmarshal(cond.Expression, &inner); err != nil {
+			return err
+		}
+
+		return writeWitnessCondition(w, inner)
+	case "And", "Or":
+		w.WriteVarUint(uint64(len(cond.Expressions)))
+		for _, expr := range cond.Expressions {
+			if err := writeWitnessCondition(w, expr); err != nil {
+				return err
+			}
+		}
+	case "ScriptHash", "CalledByContract":
+		hash, err := decodeUInt160(cond.Hash)
+		if err != nil {
+			return err
+		}
+
+		w.WriteBytes(hash)
+	case "Group", "CalledByGroup":
+		pubKey, err := decodePublicKey(cond.Group)
+		if err != nil {
+			return err
+		}
+
+		w.WriteBytes(pubKey)
+	}
+
+	return nil
+}
+
+func writeTxAttribute(w *serialize.Writer, attr TxAttribute) error {
+	attrType, ok := txAttributeTypes[attr.Type]
+	if !ok {
+		return fmt.Errorf("%w: transaction attribute %q", ErrUnsupported, attr.Type)
+	}
+
+	w.WriteU8(attrType)
+
+	switch attr.Type {
+	case "OracleResponse":
+		code, ok := oracleResponseCodes[attr.Code]
+		if !ok {
+			return fmt.Errorf("%w: oracle response code %q", ErrUnsupported, attr.Code)
+		}
+
+		result, err := base64.StdEncoding.DecodeString(attr.Result)
+		if err != nil {
+			return fmt.Errorf("invalid oracle response result: %v", err)
+		}
+
+		w.WriteU64(attr.ID)
+		w.WriteU8(code)
+		w.WriteVarBytes(result)
+	case "NotValidBefore":
+		w.WriteU32(attr.Height)
+	case "Conflicts":
+		hash, err := decodeUInt256(attr.Hash)
+		if err != nil {
+			return err
+		}
+
+		w.WriteBytes(hash)
+	case "NotaryAssisted":
+		w.WriteU8(attr.NKeys)
+	}
+
+	return nil
+}
+
+// decodeUInt256 decodes a 0x prefixed big-endian hash to little-endian bytes.
+func decodeUInt256(hash string) ([]byte, error) {
+	return decodeHash(hash, 32)
+}
+
+// decodeUInt160 decodes a 0x prefixed big-endian script hash to little-endian bytes.
+func decodeUInt160(hash string) ([]byte, error) {
+	return decodeHash(hash, 20)
+}
+
+func decodeHash(hash string, size int) ([]byte, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(hash, "0x"))
+	if err != nil || len(b) != size {
+		return nil, fmt.Errorf("invalid hash %q", hash)
+	}
+
+	return byteutil.ReverseBytes(b), nil
+}
+
+func decodePublicKey(pubKey string) ([]byte, error) {
+	b, err := hex.DecodeString(pubKey)
+	if err != nil || len(b) != 33 {
+		return nil, fmt.Errorf("invalid public key %q", pubKey)
+	}
+
+	return b, nil
+}
+
+func sameHash(hash []byte, expected string) bool {
+	return formatHash(hash) == strings.ToLower(expected)
+}
+
+// formatHash formats little-endian hash bytes as a 0x prefixed big-endian hash.
+func formatHash(hash []byte) string {
+	return "0x" + hex.EncodeToString(byteutil.ReverseBytes(hash))
+}
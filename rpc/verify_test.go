@@ -0,0 +1,78 @@
+package rpc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"neo3-squirrel/tests/fullnode"
+	"neo3-squirrel/util/hashutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyGenesisBlock(t *testing.T) {
+	// Genesis block of N3 MainNet.
+	b := Block{
+		Hash:              "0x1f4d1defa46faa5e7b9b8d3f79a06bec777d7c26c4aa5f6f5899a291daa87c15",
+		PreviousBlockHash: "0x0000000000000000000000000000000000000000000000000000000000000000",
+		MerkleRoot:        "0x0000000000000000000000000000000000000000000000000000000000000000",
+		Time:              1468595301000,
+		Nonce:             "000000007C2BAC1D",
+		NextConsensus:     "NVg7LjGcUSrgxgjX3zEgqaksfMaiS8Z6e1",
+	}
+
+	if err := b.Verify(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyBlock(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join(fullnode.Testdata(), "blocks", "1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	load := func() *Block {
+		b := Block{}
+		if err := json.Unmarshal(data, &b); err != nil {
+			t.Fatal(err)
+		}
+
+		return &b
+	}
+
+	if err := load().Verify(); err != nil {
+		t.Fatalf("Valid block must be verified, got %v", err)
+	}
+
+	tampered := load()
+	tampered.Tx[0].SysFee = big.NewFloat(1)
+	if err := tampered.Verify(); err == nil {
+		t.Fatal("Block with tampered transaction must be rejected")
+	}
+
+	tampered = load()
+	tampered.Tx = nil
+	if err := tampered.Verify(); err == nil {
+		t.Fatal("Block with removed transactions must be rejected")
+	}
+
+	tampered = load()
+	tampered.Time++
+	if err := tampered.Verify(); err == nil {
+		t.Fatal("Block with tampered header must be rejected")
+	}
+}
+
+func TestComputeMerkleRoot(t *testing.T) {
+	concat := func(left, right []byte) []byte {
+		return hashutil.Hash256(append(append([]byte{}, left...), right...))
+	}
+
+	hashes := [][]byte{{1}, {2}, {3}}
+	want := concat(concat(hashes[0], hashes[1]), concat(hashes[2], hashes[2]))
+
+	if get := ComputeMerkleRoot(hashes); string(get) != string(want) {
+		t.Fatalf("Incorrect merkle root, get: %x, want: %x", get, want)
+	}
+}
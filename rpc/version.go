@@ -0,0 +1,65 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// VersionResponse is the response structure of rpc call 'getversion'.
+type VersionResponse struct {
+	responseCommon
+	Result *Version `json:"result"`
+}
+
+// Version represents 'getversion' query result.
+type Version struct {
+	TCPPort   uint   `json:"tcpport"`
+	WSPort    uint   `json:"wsport"`
+	Nonce     uint64 `json:"nonce"`
+	UserAgent string `json:"useragent"`
+	// Magic is returned by early Neo3 nodes instead of protocol.network.
+	Magic    uint32           `json:"magic"`
+	Protocol ProtocolSettings `json:"protocol"`
+}
+
+// ProtocolSettings represents protocol settings of a fullnode.
+type ProtocolSettings struct {
+	AddressVersion     byte   `json:"addressversion"`
+	Network            uint32 `json:"network"`
+	ValidatorsCount    uint   `json:"validatorscount"`
+	MSPerBlock         uint   `json:"msperblock"`
+	MaxTraceableBlocks uint   `json:"maxtraceableblocks"`
+}
+
+// getVersionFrom gets version of the given fullnode.
+func getVersionFrom(url string) (*Version, error) {
+	args, err := generateRequestBody("getversion", []interface{}{})
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := transport.Post(url, []byte(args))
+	if err != nil {
+		return nil, err
+	}
+
+	respData := VersionResponse{}
+	if err := json.Unmarshal(respBody, &respData); err != nil {
+		return nil, err
+	}
+
+	if respData.Error != nil {
+		return nil, respData.Error
+	}
+
+	version := respData.Result
+	if version == nil {
+		return nil, errors.New("empty 'getversion' result")
+	}
+
+	if version.Protocol.Network == 0 {
+		version.Protocol.Network = version.Magic
+	}
+
+	return version, nil
+}
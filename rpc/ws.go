@@ -0,0 +1,136 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"neo3-squirrel/util/color"
+	"neo3-squirrel/util/log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsMaxRetryDelay limits the delay of WebSocket reconnection.
+const wsMaxRetryDelay = 60 * time.Second
+
+// newBlocks receives blocks pushed by WebSocket subscriptions.
+var newBlocks = make(chan *Block, 100)
+
+// wsMessage is either a subscription response or an event notification.
+type wsMessage struct {
+	responseCommon
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// NewBlocks returns blocks pushed by WebSocket subscriptions.
+// Blocks are dropped if not consumed in time. Pushed blocks lack
+// verbose fields like size, they only tell a new block is available.
+func NewBlocks() <-chan *Block {
+	return newBlocks
+}
+
+// SubscribeBlocks subscribes new blocks from the WebSocket
+// endpoint of the given fullnode in the background.
+func SubscribeBlocks(url, wsURL string) {
+	go subscribeBlocks(url, wsURL)
+}
+
+// subscribeBlocks subscribes 'block_added' events from the WebSocket
+// endpoint of the given fullnode, and reconnects once disconnected.
+// Stops if the fullnode has no WebSocket endpoint,
+// new blocks are then detected by polling only.
+func subscribeBlocks(url, wsURL string) {
+	delay := time.Second
+
+	for {
+		subscribed, err := readBlocks(url, wsURL)
+		if errors.Is(err, websocket.ErrBadHandshake) {
+			log.Warn(color.BYellowf("%s has no WebSocket endpoint, fall back to polling", wsURL))
+			return
+		}
+
+		if subscribed {
+			delay = time.Second
+		}
+
+		log.Warnf("WebSocket subscription of %s closed: %v. Reconnect in %v", wsURL, err, delay)
+		time.Sleep(delay)
+
+		delay *= 2
+		if delay > wsMaxRetryDelay {
+			delay = wsMaxRetryDelay
+		}
+	}
+}
+
+// readBlocks subscribes new blocks and reads them until disconnected.
+func readBlocks(url, wsURL string) (bool, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		return false, err
+	}
+
+	defer conn.Close()
+
+	req, err := generateRequestBody("subscribe", []interface{}{"block_added"})
+	if err != nil {
+		return false, err
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(req)); err != nil {
+		return false, err
+	}
+
+	subscribed := false
+
+	for {
+		msg := wsMessage{}
+		if err := conn.ReadJSON(&msg); err != nil {
+			return subscribed, err
+		}
+
+		if msg.Error != nil {
+			return subscribed, msg.Error
+		}
+
+		if msg.Method != "block_added" {
+			if !subscribed {
+				subscribed = true
+				log.Info(color.Greenf("Subscribed new blocks from %s", wsURL))
+			}
+			continue
+		}
+
+		if len(msg.Params) == 0 {
+			continue
+		}
+
+		block := Block{}
+		if err := json.Unmarshal(msg.Params[0], &block); err != nil {
+			return subscribed, err
+		}
+
+		pushBlock(url, &block)
+	}
+}
+
+func pushBlock(url string, block *Block) {
+	if isQuarantined(url) {
+		return
+	}
+
+	block.Source = url
+
+	height := int(block.Index)
+	bestHeight.SetMax(height)
+
+	if h, ok := nodeHeights.Load(url); ok && h.(int) < height {
+		nodeHeights.Store(url, height)
+	}
+
+	select {
+	case newBlocks <- block:
+	default:
+	}
+}
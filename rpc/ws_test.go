@@ -0,0 +1,56 @@
+package rpc
+
+import (
+	"neo3-squirrel/util/log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSubscribeBlocks(t *testing.T) {
+	log.Init(true)
+	defer func() {
+		os.RemoveAll("./logs")
+	}()
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc": "2.0", "id": 1, "result": "0"}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc": "2.0", "method": "block_added", "params": [{"hash": "0x01", "index": 12345}]}`))
+
+		// Keep the connection until the client quits.
+		conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	go readBlocks(server.URL, wsURL)
+
+	select {
+	case b := <-NewBlocks():
+		if b.Index != 12345 {
+			t.Fatalf("Incorrect pushed block, expected index 12345, got %d", b.Index)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No block pushed")
+	}
+
+	if height := GetBestHeight(); height < 12345 {
+		t.Fatalf("Best height must be updated by pushed blocks, got %d", height)
+	}
+}
@@ -4,10 +4,12 @@ CREATE TABLE IF NOT EXISTS `counter`
     `block_index`                INT  NOT NULL DEFAULT 0,
     `addr_count`        INT UNSIGNED  NOT NULL DEFAULT 0,
     `contract_noti_pk`  INT UNSIGNED  NOT NULL DEFAULT 0,
-    `tx_count`          INT UNSIGNED  NOT NULL DEFAULT 0
+    `tx_count`          INT UNSIGNED  NOT NULL DEFAULT 0,
+    `network`           INT UNSIGNED  NOT NULL DEFAULT 0,
+    `app_log_start`              INT  NOT NULL DEFAULT -1
 ) ENGINE = InnoDB DEFAULT CHARSET = 'utf8mb4';
 
-INSERT INTO `counter`(`id`, `block_index`)
+INSERT IGNORE INTO `counter`(`id`, `block_index`)
 VALUES(1, -1);
 
 
@@ -32,7 +34,8 @@ CREATE TABLE IF NOT EXISTS `block_witness`
     `id`      INT UNSIGNED  NOT NULL AUTO_INCREMENT PRIMARY KEY,
     `block_hash`  CHAR(66)  NOT NULL,
     `invocation`      TEXT  NOT NULL,
-    `verification`    TEXT  NOT NULL
+    `verification`    TEXT  NOT NULL,
+    INDEX `idx_block_hash` (`block_hash`)
 ) ENGINE = InnoDB DEFAULT CHARSET = 'utf8mb4';
 
 
@@ -61,7 +64,67 @@ CREATE TABLE IF NOT EXISTS `transaction_signer`
     `id`            INT UNSIGNED  NOT NULL AUTO_INCREMENT PRIMARY KEY,
     `transaction_hash`  CHAR(66)  NOT NULL,
     `account`           CHAR(42)  NOT NULL,
-    `scopes`         VARCHAR(32)  NOT NULL
+    `scopes`         VARCHAR(64)  NOT NULL
+) ENGINE = InnoDB DEFAULT CHARSET = 'utf8mb4';
+
+
+CREATE TABLE IF NOT EXISTS `transaction_signer_contract`
+(
+    `id`            INT UNSIGNED  NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `transaction_hash`  CHAR(66)  NOT NULL,
+    `account`           CHAR(42)  NOT NULL,
+    `contract`          CHAR(42)  NOT NULL,
+
+    INDEX `idx_transaction_hash` (`transaction_hash`),
+    INDEX `idx_account` (`account`),
+    INDEX `idx_contract` (`contract`)
+) ENGINE = InnoDB DEFAULT CHARSET = 'utf8mb4';
+
+
+CREATE TABLE IF NOT EXISTS `transaction_signer_group`
+(
+    `id`            INT UNSIGNED  NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `transaction_hash`  CHAR(66)  NOT NULL,
+    `account`           CHAR(42)  NOT NULL,
+    `group`             CHAR(66)  NOT NULL,
+
+    INDEX `idx_transaction_hash` (`transaction_hash`),
+    INDEX `idx_account` (`account`),
+    INDEX `idx_group` (`group`)
+) ENGINE = InnoDB DEFAULT CHARSET = 'utf8mb4';
+
+
+CREATE TABLE IF NOT EXISTS `transaction_signer_rule`
+(
+    `id`            INT UNSIGNED  NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `transaction_hash`  CHAR(66)  NOT NULL,
+    `account`           CHAR(42)  NOT NULL,
+    `rule_index` TINYINT UNSIGNED  NOT NULL,
+    `action`          VARCHAR(8)  NOT NULL,
+    `condition`             JSON  NOT NULL,
+
+    INDEX `idx_transaction_hash` (`transaction_hash`),
+    INDEX `idx_account` (`account`)
+) ENGINE = InnoDB DEFAULT CHARSET = 'utf8mb4';
+
+
+CREATE TABLE IF NOT EXISTS `transaction_signer_condition`
+(
+    `id`            INT UNSIGNED  NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `transaction_hash`  CHAR(66)  NOT NULL,
+    `account`           CHAR(42)  NOT NULL,
+    `rule_index` TINYINT UNSIGNED  NOT NULL,
+    `node_index` TINYINT UNSIGNED  NOT NULL,
+    `parent_index` TINYINT UNSIGNED  NULL,
+    `type`           VARCHAR(16)  NOT NULL,
+    `expression`      TINYINT(1)  NULL,
+    `hash`              CHAR(42)  NULL,
+    `group`             CHAR(66)  NULL,
+
+    INDEX `idx_transaction_hash` (`transaction_hash`),
+    INDEX `idx_account` (`account`),
+    INDEX `idx_hash` (`hash`),
+    INDEX `idx_group` (`group`)
 ) ENGINE = InnoDB DEFAULT CHARSET = 'utf8mb4';
 
 
@@ -82,6 +145,55 @@ CREATE TABLE IF NOT EXISTS `transaction_witness`
 ) ENGINE = InnoDB DEFAULT CHARSET = 'utf8mb4';
 
 
+CREATE TABLE IF NOT EXISTS `transaction_signer_witness`
+(
+    `id`            INT UNSIGNED  NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `transaction_hash`  CHAR(66)  NOT NULL,
+    `account`           CHAR(42)  NOT NULL,
+    `type`           VARCHAR(16)  NOT NULL,
+    `threshold`  SMALLINT UNSIGNED  NOT NULL,
+    `pubkeys`               JSON  NOT NULL,
+    `signature`       VARCHAR(9)  NOT NULL,
+
+    INDEX `idx_transaction_hash` (`transaction_hash`),
+    INDEX `idx_account` (`account`)
+) ENGINE = InnoDB DEFAULT CHARSET = 'utf8mb4';
+
+
+CREATE TABLE IF NOT EXISTS `pubkey`
+(
+    `id`            INT UNSIGNED  NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `address`           CHAR(34)  NOT NULL UNIQUE,
+    `pubkey`            CHAR(66)  NOT NULL,
+    `block_index`   INT UNSIGNED  NOT NULL,
+
+    INDEX `idx_pubkey` (`pubkey`),
+    INDEX `idx_block_index` (`block_index`)
+) ENGINE = InnoDB DEFAULT CHARSET = 'utf8mb4';
+
+
+CREATE TABLE IF NOT EXISTS `multisig`
+(
+    `id`               INT UNSIGNED  NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `address`              CHAR(34)  NOT NULL UNIQUE,
+    `script_hash`          CHAR(42)  NOT NULL,
+    `threshold`   SMALLINT UNSIGNED  NOT NULL,
+    `members`     SMALLINT UNSIGNED  NOT NULL
+) ENGINE = InnoDB DEFAULT CHARSET = 'utf8mb4';
+
+
+CREATE TABLE IF NOT EXISTS `multisig_member`
+(
+    `id`          INT UNSIGNED  NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    `multisig`        CHAR(34)  NOT NULL,
+    `pubkey`          CHAR(66)  NOT NULL,
+    `address`         CHAR(34)  NOT NULL,
+
+    UNIQUE INDEX `uix_multisig_pubkey` (`multisig`, `pubkey`),
+    INDEX `idx_address` (`address`)
+) ENGINE = InnoDB DEFAULT CHARSET = 'utf8mb4';
+
+
 CREATE TABLE IF NOT EXISTS `notification`
 (
     `id`             INT UNSIGNED  NOT NULL AUTO_INCREMENT PRIMARY KEY,
@@ -100,7 +212,8 @@ CREATE TABLE IF NOT EXISTS `notification`
     `eventname`       VARCHAR(64)  NOT NULL,
     `state`                  JSON  NOT NULL,
 
-    INDEX `uix_hash` (`hash`)
+    INDEX `uix_hash` (`hash`),
+    INDEX `idx_block_index` (`block_index`)
 ) ENGINE = InnoDB DEFAULT CHARSET = 'utf8mb4';
 
 
@@ -155,6 +268,7 @@ CREATE TABLE IF NOT EXISTS `addr_asset`
     `contract`        CHAR(42)  NOT NULL,
     `balance`  DECIMAL(65, 30)  NOT NULL,
     `transfers`   INT UNSIGNED  NOT NULL,
+    `opening_balance` DECIMAL(65, 30) NOT NULL DEFAULT 0,
 
     INDEX `idx_address` (`address`),
     INDEX `idx_contract` (`contract`)
@@ -209,5 +323,6 @@ CREATE TABLE IF NOT EXISTS `contract`
     `abi`                     JSON  NOT NULL,
     `permissions`             JSON  NOT NULL,
     `trusts`                  JSON  NOT NULL,
-    `extra`                   JSON  NOT NULL
+    `extra`                   JSON  NOT NULL,
+    `updatehistory`           JSON  NOT NULL
 ) ENGINE = InnoDB DEFAULT CHARSET = 'utf8mb4';
@@ -0,0 +1,34 @@
+-- Upgrades a database created by an earlier create_table.sql.
+-- Run create_table.sql first to create new tables, then run this script once.
+
+-- Network magic the persisted data belongs to, 0 if unknown.
+ALTER TABLE `counter`
+    ADD COLUMN `network` INT UNSIGNED NOT NULL DEFAULT 0;
+
+-- Block the application log sync started from, -1 if not started.
+ALTER TABLE `counter`
+    ADD COLUMN `app_log_start` INT NOT NULL DEFAULT -1;
+UPDATE `counter`
+    SET `app_log_start` = IFNULL((SELECT MIN(`block_index`) FROM `notification`), -1)
+    WHERE `id` = 1;
+
+-- Update history of contracts, existing contracts have no update recorded.
+ALTER TABLE `contract`
+    ADD COLUMN `updatehistory` JSON NULL;
+UPDATE `contract` SET `updatehistory` = JSON_ARRAY();
+ALTER TABLE `contract`
+    MODIFY COLUMN `updatehistory` JSON NOT NULL;
+
+-- Balance of addresses at the start height.
+ALTER TABLE `addr_asset`
+    ADD COLUMN `opening_balance` DECIMAL(65, 30) NOT NULL DEFAULT 0;
+
+ALTER TABLE `notification`
+    ADD INDEX `idx_block_index` (`block_index`);
+
+ALTER TABLE `block_witness`
+    ADD INDEX `idx_block_hash` (`block_hash`);
+
+-- Scopes of signers with several flags exceed 32 characters.
+ALTER TABLE `transaction_signer`
+    MODIFY COLUMN `scopes` VARCHAR(64) NOT NULL;
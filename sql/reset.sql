@@ -7,9 +7,17 @@ TRUNCATE TABLE `counter`;
 TRUNCATE TABLE `transaction`;
 TRUNCATE TABLE `transaction_attribute`;
 TRUNCATE TABLE `transaction_signer`;
+TRUNCATE TABLE `transaction_signer_contract`;
+TRUNCATE TABLE `transaction_signer_group`;
+TRUNCATE TABLE `transaction_signer_rule`;
+TRUNCATE TABLE `transaction_signer_condition`;
 TRUNCATE TABLE `transaction_witness`;
+TRUNCATE TABLE `transaction_signer_witness`;
 TRUNCATE TABLE `transfer`;
 TRUNCATE TABLE `address`;
 TRUNCATE TABLE `contract`;
 TRUNCATE TABLE `contract_notification`;
+TRUNCATE TABLE `pubkey`;
+TRUNCATE TABLE `multisig`;
+TRUNCATE TABLE `multisig_member`;
 INSERT INTO `counter`(`id`, `block_index`) VALUES(1, -1);
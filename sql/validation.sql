@@ -116,9 +116,7 @@ UNION ALL
 
 SELECT 'check NEO & GAS transfers total amount balance', IF(
     !EXISTS(
-        SELECT @NEO = '0x0a46e2e37c9987f570b4af253fb77e7eef0f72b6',
-               @GAS = '0xa6a6c15dcdc9b997dac448b6926522d22efeedfb',
-               addr_asset.address,
+        SELECT addr_asset.address,
                addr_asset.contract,
                addr_asset.balance,
                aa.balance
@@ -126,17 +124,17 @@ SELECT 'check NEO & GAS transfers total amount balance', IF(
             SELECT `addr`, `contract`, SUM(`amount`) balance FROM (
                 SELECT `from` addr, `contract`, -SUM(amount) amount
                 FROM `transfer`
-                WHERE `from` != '' AND `contract` IN (@NEO, @GAS)
+                WHERE `from` != '' AND `contract` IN (SELECT `hash` FROM `contract` WHERE `contract_id` < 0 AND `name` IN ('NeoToken', 'GasToken'))
                 GROUP BY `from`, `contract`
                 UNION
                 SELECT `to` addr, `contract`, SUM(`amount`) amount
                 FROM `transfer`
-                WHERE `to` != '' AND `contract` IN (@NEO, @GAS)
+                WHERE `to` != '' AND `contract` IN (SELECT `hash` FROM `contract` WHERE `contract_id` < 0 AND `name` IN ('NeoToken', 'GasToken'))
                 GROUP BY `to`, `contract`
             ) a GROUP BY addr, `contract`
         ) aa
         ON `addr_asset`.`address`=aa.`addr` AND `addr_asset`.`contract`=`aa`.`contract`
-        WHERE `addr_asset`.`balance` != `aa`.`balance`
+        WHERE `addr_asset`.`balance` - `addr_asset`.`opening_balance` != `aa`.`balance`
     )
 , 'PASS', '[FAIL]')
 
@@ -1,15 +1,20 @@
 package applog
 
 import (
+	"context"
 	"fmt"
 	"neo3-squirrel/cache/block"
+	"neo3-squirrel/config"
 	"neo3-squirrel/db"
 	"neo3-squirrel/models"
 	"neo3-squirrel/rpc"
+	"neo3-squirrel/tasks/reorg"
+	"neo3-squirrel/tasks/supervisor"
 	"neo3-squirrel/util/color"
 	"neo3-squirrel/util/log"
 	"neo3-squirrel/util/timeutil"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -22,15 +27,27 @@ var (
 	// LastAppLogBlockIndex is the highest applog block index persisted.
 	LastAppLogBlockIndex uint
 
-	preAppLogChan = make(chan *preAppLog, chanSize)
-	appLogChan    = make(chan *appLogInfo, chanSize)
+	preAppLogChan chan *preAppLog
+	appLogChan    chan *appLogInfo
 
 	queryResults = []*appLogInfo{}
+
+	// epoch is the rollback epoch of the current fetching progress.
+	epoch uint
+
+	// unit is the current run of the application log sync task.
+	unit *supervisor.Unit
+
+	// reachedEnd is set once all blocks till the end height fetched,
+	// finished is set after their application logs persisted.
+	reachedEnd int32
+	finished   int32
 )
 
 type preAppLog struct {
 	BlockIndex uint
 	Hash       string
+	epoch      uint
 }
 
 type appLogInfo struct {
@@ -38,10 +55,13 @@ type appLogInfo struct {
 	BlockTime  uint64
 	Hash       string
 	appLog     *rpc.ApplicationLog
+	epoch      uint
 }
 
-// StartApplicationLogSyncTask starts application log sync task.
-func StartApplicationLogSyncTask() {
+// StartApplicationLogSyncTask starts application log sync task in the unit.
+// Queried application logs are persisted before the unit returns once ctx is cancelled.
+func StartApplicationLogSyncTask(ctx context.Context, u *supervisor.Unit) {
+	unit = u
 	lastNoti := db.GetLastNotification()
 
 	if lastNoti != nil {
@@ -59,21 +79,60 @@ func StartApplicationLogSyncTask() {
 		}
 	}
 
+	// Clear states left by the last run.
+	atomic.StoreInt32(&reachedEnd, 0)
+	atomic.StoreInt32(&finished, 0)
+	preAppLogChan = make(chan *preAppLog, chanSize)
+	appLogChan = make(chan *appLogInfo, chanSize)
+	queryResults = []*appLogInfo{}
+	clearAppLogs()
+
 	// Start tasks.
-	go fetchApplicationLogs(lastNoti)
-	go queryAppLog(3, preAppLogChan)
-	go persistApplicationLogs(appLogChan)
+	unit.Go("fetch", func() {
+		fetchApplicationLogs(ctx, lastNoti)
+	})
+	queryAppLog(3, preAppLogChan)
+
+	persistChan := appLogChan
+	unit.Go("persist", func() {
+		persistApplicationLogs(persistChan)
+	})
 }
 
-func fetchApplicationLogs(lastNoti *models.Notification) {
-	processLastBlockNotifications(lastNoti)
+func fetchApplicationLogs(ctx context.Context, lastNoti *models.Notification) {
+	// Queries of the current block have been sent to
+	// the persistence channel before channels closed.
+	defer close(appLogChan)
+	defer close(preAppLogChan)
 
-	nextBlockIndex := uint(0)
-	if lastNoti != nil {
-		nextBlockIndex = lastNoti.BlockIndex + 1
+	epoch = reorg.Epoch()
+	nextBlockIndex := resume(lastNoti)
+
+	// Applogs below the start block are found by gap scans.
+	if lastNoti == nil {
+		db.SetAppLogStart(nextBlockIndex)
 	}
 
 	for {
+		if ctx.Err() != nil || unit.IsAborted() {
+			return
+		}
+
+		// Restart from the last persisted notification
+		// if the chain has been rolled back.
+		if e := reorg.Epoch(); e != epoch {
+			epoch = e
+			queryResults = []*appLogInfo{}
+			clearAppLogs()
+
+			nextBlockIndex = resume(db.GetLastNotification())
+		}
+
+		if endHeight, ok := config.GetEndHeight(); ok && nextBlockIndex > endHeight {
+			atomic.StoreInt32(&reachedEnd, 1)
+			return
+		}
+
 		block, ok := block.GetBlock(nextBlockIndex)
 		if !ok {
 			block = db.GetBlock(nextBlockIndex)
@@ -87,6 +146,7 @@ func fetchApplicationLogs(lastNoti *models.Notification) {
 
 		nextBlockIndex++
 
+		unit.Track("fetch", fmt.Sprintf("block %d(%s)", block.Index, block.Hash))
 		preAppLogPushBlock(block)
 
 		// Send transactions to pre-applog channel
@@ -95,47 +155,88 @@ func fetchApplicationLogs(lastNoti *models.Notification) {
 			preAppLogPushTx(tx)
 		}
 
-		for i := 0; i < len(queryResults); i++ {
-			retry := 0
+		collectQueryResults()
 
-			for {
-				logInfo := queryResults[i]
+		// Clear query result array.
+		queryResults = []*appLogInfo{}
+	}
+}
 
-				// Wait for at most 300 seconds to crash unless all fullnodes down.
-				if retry > 300*1000 {
-					if rpc.AllFullnodesDown() {
-						retry = 0
-						for rpc.AllFullnodesDown() {
-							time.Sleep(100 * time.Millisecond)
-						}
-						continue
-					}
+// Finished tells if application logs of all blocks
+// till the configured end height have been persisted.
+func Finished() bool {
+	return atomic.LoadInt32(&finished) == 1
+}
 
-					log.Panicf("Failed to get applog of %s(index=%d, time=%d)",
-						logInfo.Hash, logInfo.BlockIndex, logInfo.BlockTime)
-				}
+func clearAppLogs() {
+	appLogs.Range(func(key, _ interface{}) bool {
+		appLogs.Delete(key)
+		return true
+	})
+}
+
+// resume pushes unpersisted transactions of the last notification
+// block and returns the next block index to fetch.
+func resume(lastNoti *models.Notification) uint {
+	processLastBlockNotifications(lastNoti)
+
+	if lastNoti == nil {
+		return config.GetStartHeight()
+	}
+
+	return lastNoti.BlockIndex + 1
+}
+
+// collectQueryResults waits for applog query results in order
+// and sends them to the persistence channel.
+func collectQueryResults() {
+	for i := 0; i < len(queryResults); i++ {
+		retry := 0
+
+		for {
+			// Abandon results of rolled back blocks.
+			if reorg.Epoch() != epoch || unit.IsAborted() {
+				return
+			}
 
-				hash := logInfo.Hash
+			logInfo := queryResults[i]
 
-				// Get applicationlog from query result chan.
-				result, ok := appLogs.Load(hash)
-				if !ok {
-					retry += 5
-					time.Sleep(5 * time.Millisecond)
+			// Wait for at most 300 seconds to crash unless all fullnodes down.
+			if retry > 300*1000 {
+				if rpc.AllFullnodesDown() {
+					retry = 0
+					for rpc.AllFullnodesDown() {
+						time.Sleep(100 * time.Millisecond)
+					}
 					continue
 				}
 
-				appLogs.Delete(hash)
+				log.Panicf("Failed to get applog of %s(index=%d, time=%d)",
+					logInfo.Hash, logInfo.BlockIndex, logInfo.BlockTime)
+			}
 
-				logInfo.appLog = result.(*rpc.ApplicationLog)
+			hash := logInfo.Hash
 
-				appLogChan <- logInfo
-				break
+			// Get applicationlog from query result chan.
+			result, ok := appLogs.Load(hash)
+			if !ok {
+				retry += 5
+				time.Sleep(5 * time.Millisecond)
+				continue
 			}
-		}
 
-		// Clear query result array.
-		queryResults = []*appLogInfo{}
+			appLogs.Delete(hash)
+
+			logInfo.appLog = result.(*rpc.ApplicationLog)
+
+			select {
+			case appLogChan <- logInfo:
+			case <-unit.Aborted():
+				return
+			}
+
+			break
+		}
 	}
 }
 
@@ -190,9 +291,14 @@ func preAppLogPushTx(tx *models.Transaction) {
 }
 
 func pushToPreChan(blockIndex uint, blockTime uint64, hash string) {
-	preAppLogChan <- &preAppLog{
+	select {
+	case preAppLogChan <- &preAppLog{
 		BlockIndex: blockIndex,
 		Hash:       hash,
+		epoch:      epoch,
+	}:
+	case <-unit.Aborted():
+		return
 	}
 
 	queryResults = append(queryResults, &appLogInfo{
@@ -200,5 +306,6 @@ func pushToPreChan(blockIndex uint, blockTime uint64, hash string) {
 		BlockTime:  blockTime,
 		Hash:       hash,
 		appLog:     nil,
+		epoch:      epoch,
 	})
 }
@@ -0,0 +1,73 @@
+package applog
+
+import (
+	"neo3-squirrel/models"
+	"neo3-squirrel/rpc"
+	"neo3-squirrel/tasks/reorg"
+	"neo3-squirrel/tasks/supervisor"
+	"neo3-squirrel/tests/fullnode"
+	"testing"
+)
+
+func TestQueryApplicationLogs(t *testing.T) {
+	fullnode.Setup(t, rpc.UseFullnodes)
+
+	rawBlock := rpc.SyncBlock(1)
+	if rawBlock == nil {
+		t.Fatal("Failed to get block 1")
+	}
+	block := models.ParseBlocks([]*rpc.Block{rawBlock})[0]
+
+	unit = supervisor.NewUnit()
+	epoch = reorg.Epoch()
+	preAppLogChan = make(chan *preAppLog, chanSize)
+	appLogChan = make(chan *appLogInfo, chanSize)
+	queryResults = []*appLogInfo{}
+	clearAppLogs()
+
+	queryAppLog(2, preAppLogChan)
+	defer close(preAppLogChan)
+
+	preAppLogPushBlock(block)
+	for _, tx := range block.GetTxs() {
+		preAppLogPushTx(tx)
+	}
+	collectQueryResults()
+	close(appLogChan)
+
+	// Application logs are sent to persistence in block order.
+	hashes := []string{block.Hash}
+	for _, tx := range block.GetTxs() {
+		hashes = append(hashes, tx.Hash)
+	}
+
+	results := []*appLogInfo{}
+	for result := range appLogChan {
+		results = append(results, result)
+	}
+
+	if len(results) != len(hashes) {
+		t.Fatalf("Expected %d application logs, got %d", len(hashes), len(results))
+	}
+
+	transfers := 0
+	for i, result := range results {
+		if result.Hash != hashes[i] || result.appLog == nil {
+			t.Fatalf("Incorrect application log %d, expected %s, got %s", i, hashes[i], result.Hash)
+		}
+
+		for _, noti := range models.ParseApplicationLog(result.BlockIndex, result.BlockTime, result.appLog) {
+			if noti.BlockIndex != 1 || noti.Hash != result.Hash {
+				t.Fatalf("Incorrect notification source: %+v", noti)
+			}
+
+			if noti.EventName == "Transfer" {
+				transfers++
+			}
+		}
+	}
+
+	if transfers == 0 {
+		t.Fatal("Transfer notifications of block 1 must be parsed")
+	}
+}
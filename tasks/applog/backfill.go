@@ -0,0 +1,71 @@
+package applog
+
+import (
+	"neo3-squirrel/config"
+	"neo3-squirrel/db"
+	"neo3-squirrel/models"
+	"neo3-squirrel/rpc"
+	"neo3-squirrel/tasks/reorg"
+)
+
+// FindMissingNotifications queries application logs of the given sources
+// and returns notifications of each source which has any.
+// Returns nil once cancelled returns true.
+func FindMissingNotifications(sources []*db.AppLogSource, cancelled func() bool) [][]*models.Notification {
+	batchSize := config.GetBatchSize()
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	missing := [][]*models.Notification{}
+
+	for start := 0; start < len(sources); start += batchSize {
+		end := start + batchSize
+		if end > len(sources) {
+			end = len(sources)
+		}
+
+		batch := sources[start:end]
+		minBlockIndex := uint(0)
+		hashes := make([]string, len(batch))
+		for i, src := range batch {
+			hashes[i] = src.Hash
+			if src.BlockIndex > minBlockIndex {
+				minBlockIndex = src.BlockIndex
+			}
+		}
+
+		appLogs := rpc.GetApplicationLogs(minBlockIndex, hashes, cancelled)
+		if appLogs == nil {
+			return nil
+		}
+
+		for i, appLog := range appLogs {
+			notis := models.ParseApplicationLog(batch[i].BlockIndex, batch[i].BlockTime, appLog)
+			if len(notis) > 0 {
+				missing = append(missing, notis)
+			}
+		}
+	}
+
+	return missing
+}
+
+// BackfillNotifications persists notifications missing from db
+// without touching the sync progress. Returns false if the chain
+// has been rolled back since the given epoch.
+func BackfillNotifications(epoch uint, notis []*models.Notification) bool {
+	if len(notis) == 0 {
+		return true
+	}
+
+	if !reorg.Hold(epoch) {
+		return false
+	}
+
+	defer reorg.Release()
+
+	insertNotifications(notis)
+
+	return true
+}
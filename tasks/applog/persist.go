@@ -1,31 +1,64 @@
 package applog
 
 import (
+	"neo3-squirrel/cache/native"
 	"neo3-squirrel/db"
 	"neo3-squirrel/models"
+	"neo3-squirrel/tasks/reorg"
+	"neo3-squirrel/util/color"
+	"neo3-squirrel/util/log"
+	"sync/atomic"
 )
 
 func persistApplicationLogs(appLogChan <-chan *appLogInfo) {
 	for result := range appLogChan {
-		logResult := result.appLog
-		blockIndex := result.BlockIndex
-		blockTime := result.BlockTime
-
-		notis := models.ParseApplicationLog(blockIndex, blockTime, logResult)
-		if len(notis) == 0 {
-			continue
+		if unit.IsAborted() {
+			return
 		}
 
-		// Persist contract management notificatoins.
-		csNotis := []*models.Notification{}
-		for _, noti := range notis {
-			if noti.Contract == models.ContractManagement {
-				csNotis = append(csNotis, noti)
-			}
-		}
+		unit.Track("persist", result.Hash)
+		persistApplicationLog(result)
+	}
 
-		db.InsertAppLogNotifications(notis, csNotis)
+	if atomic.LoadInt32(&reachedEnd) == 1 {
+		atomic.StoreInt32(&finished, 1)
+		log.Info(color.BGreen("Application log sync reached end height"))
+		return
+	}
 
-		LastAppLogBlockIndex = blockIndex
+	log.Info(color.Green("Application log sync task stopped"))
+}
+
+func persistApplicationLog(result *appLogInfo) {
+	// Skip results queried before the chain was rolled back.
+	if !reorg.Hold(result.epoch) {
+		return
 	}
+
+	defer reorg.Release()
+
+	logResult := result.appLog
+	blockIndex := result.BlockIndex
+	blockTime := result.BlockTime
+
+	notis := models.ParseApplicationLog(blockIndex, blockTime, logResult)
+	if len(notis) == 0 {
+		return
+	}
+
+	insertNotifications(notis)
+
+	LastAppLogBlockIndex = blockIndex
+}
+
+func insertNotifications(notis []*models.Notification) {
+	// Persist contract management notificatoins.
+	csNotis := []*models.Notification{}
+	for _, noti := range notis {
+		if noti.Contract == native.Management() {
+			csNotis = append(csNotis, noti)
+		}
+	}
+
+	db.InsertAppLogNotifications(notis, csNotis)
 }
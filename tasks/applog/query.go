@@ -1,16 +1,69 @@
 package applog
 
 import (
+	"neo3-squirrel/config"
 	"neo3-squirrel/rpc"
+	"neo3-squirrel/tasks/reorg"
 )
 
 func queryAppLog(workers int, preAppLogChan <-chan *preAppLog) {
 	for i := 0; i < workers; i++ {
-		go func(ch <-chan *preAppLog) {
-			for pre := range ch {
-				appLogQueryResult := rpc.GetApplicationLog(pre.BlockIndex, pre.Hash)
-				appLogs.Store(pre.Hash, appLogQueryResult)
+		unit.Go("query", func() {
+			for pre := range preAppLogChan {
+				batch := collectBatch(pre, preAppLogChan, config.GetBatchSize())
+				if len(batch) == 0 {
+					continue
+				}
+
+				epoch := batch[0].epoch
+				stale := func() bool {
+					return epoch != reorg.Epoch() || unit.IsAborted()
+				}
+
+				minBlockIndex := uint(0)
+				txIDs := make([]string, len(batch))
+				for j, p := range batch {
+					txIDs[j] = p.Hash
+					if p.BlockIndex > minBlockIndex {
+						minBlockIndex = p.BlockIndex
+					}
+				}
+
+				appLogQueryResults := rpc.GetApplicationLogs(minBlockIndex, txIDs, stale)
+				if appLogQueryResults == nil {
+					continue
+				}
+
+				for j, appLogQueryResult := range appLogQueryResults {
+					appLogs.Store(txIDs[j], appLogQueryResult)
+				}
 			}
-		}(preAppLogChan)
+		})
 	}
 }
+
+// collectBatch collects at most size queued queries without blocking,
+// queries of rolled back blocks are dropped.
+func collectBatch(first *preAppLog, ch <-chan *preAppLog, size int) []*preAppLog {
+	batch := []*preAppLog{}
+	currEpoch := reorg.Epoch()
+
+	pre, ok := first, true
+	for ok {
+		if pre.epoch == currEpoch {
+			batch = append(batch, pre)
+		}
+
+		if len(batch) >= size {
+			return batch
+		}
+
+		select {
+		case pre, ok = <-ch:
+		default:
+			return batch
+		}
+	}
+
+	return batch
+}
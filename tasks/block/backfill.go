@@ -0,0 +1,137 @@
+package block
+
+import (
+	"context"
+	"fmt"
+	"neo3-squirrel/config"
+	"neo3-squirrel/db"
+	"neo3-squirrel/models"
+	"neo3-squirrel/tasks/multisig"
+	"neo3-squirrel/tasks/reorg"
+	"neo3-squirrel/tasks/supervisor"
+	"neo3-squirrel/util/log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Backfill fetches blocks from start to end with parallel workers and
+// persists them without touching the live sync progress. If replaceTxs
+// is set, the blocks already exist and only their transactions are replaced.
+// Returns false if stopped since the chain was rolled back.
+func Backfill(ctx context.Context, u *supervisor.Unit, start, end uint, replaceTxs bool) bool {
+	batchSize := uint(config.GetBatchSize())
+	if batchSize == 0 {
+		batchSize = 1
+	}
+
+	// Blocks are saved like the live sync does, only within the epoch.
+	epoch := reorg.Epoch()
+	rolledBack := make(chan struct{})
+	stop := sync.Once{}
+
+	batches := make(chan uint)
+	workers := sync.WaitGroup{}
+
+	for i := 0; i < config.GetWorkers(); i++ {
+		workers.Add(1)
+		u.Go("backfill", func() {
+			defer workers.Done()
+
+			for from := range batches {
+				to := from + batchSize - 1
+				if to > end {
+					to = end
+				}
+
+				u.Track("backfill", fmt.Sprintf("blocks %d-%d", from, to))
+				if !backfillBlocks(u, epoch, from, to, replaceTxs) {
+					stop.Do(func() {
+						close(rolledBack)
+					})
+				}
+			}
+		})
+	}
+
+	defer workers.Wait()
+	defer close(batches)
+
+	for from := start; from <= end; from += batchSize {
+		select {
+		case batches <- from:
+		case <-rolledBack:
+			return false
+		case <-ctx.Done():
+			return true
+		case <-u.Aborted():
+			return true
+		}
+	}
+
+	return true
+}
+
+// backfillBlocks returns false if the chain was rolled back since the epoch.
+func backfillBlocks(u *supervisor.Unit, epoch, from, to uint, replaceTxs bool) bool {
+	blocks := syncBlocks(int(from), int(to-from+1))
+
+	// Blocks failed in the batch are retried one by one.
+	fetched := map[uint]bool{}
+	for _, b := range blocks {
+		fetched[b.Index] = true
+	}
+
+	for index := from; index <= to; index++ {
+		for !fetched[index] {
+			if u.IsAborted() {
+				return true
+			}
+
+			if b := syncBlock(index); b != nil {
+				blocks = append(blocks, b)
+				fetched[index] = true
+				break
+			}
+
+			time.Sleep(1 * time.Second)
+		}
+	}
+
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].Index < blocks[j].Index
+	})
+
+	parsed := models.ParseBlocks(blocks)
+	txBulk := parseTxs(blocks)
+	txBulk.MultiSigAccounts = multisig.Collect(parsed, txBulk)
+
+	// Blocks fetched before the rollback may be orphaned.
+	if !reorg.Hold(epoch) {
+		return false
+	}
+
+	defer reorg.Release()
+
+	var err error
+	if replaceTxs {
+		indexes := make([]uint, len(blocks))
+		for i, b := range blocks {
+			indexes[i] = b.Index
+		}
+
+		err = db.ReplaceBlockTxs(indexes, txBulk)
+	} else {
+		err = db.InsertMissingBlocks(parsed, txBulk)
+	}
+
+	if err != nil {
+		log.Panic(err)
+	}
+
+	multisig.MarkRegistered(txBulk.MultiSigAccounts)
+
+	log.Infof("Backfilled blocks %d-%d", from, to)
+
+	return true
+}
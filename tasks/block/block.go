@@ -1,21 +1,25 @@
 package block
 
 import (
+	"context"
 	"fmt"
 	"neo3-squirrel/cache/block"
 	"neo3-squirrel/config"
 	"neo3-squirrel/db"
 	"neo3-squirrel/models"
 	"neo3-squirrel/rpc"
+	"neo3-squirrel/tasks/multisig"
+	"neo3-squirrel/tasks/supervisor"
 	"neo3-squirrel/util/color"
 	"neo3-squirrel/util/log"
 	"neo3-squirrel/util/progress"
 	"neo3-squirrel/util/timeutil"
+	"sync/atomic"
 	"time"
 )
 
-// bufferSize is the capacity of pending blocks waiting to be persisted to db.
-const bufferSize = 20000
+// queueSize is the capacity of ordered blocks waiting to be persisted to db.
+const queueSize = 1000
 
 var (
 	// bestRPCHeight util.SafeCounter.
@@ -24,15 +28,29 @@ var (
 	worker       Worker
 	blockChannel chan *rpc.Block
 
+	// queuedBytes is the estimated memory held by ordered blocks
+	// waiting to be persisted.
+	queuedBytes int64
+
+	// unit is the current run of the block sync task.
+	unit *supervisor.Unit
+
 	// bestBlockIndex traces the highest node height.
 	// The value won't decrease even if node resync.
 	bestBlockIndex int
 )
 
-// StartBlockSyncTask starts block sync tasks.
-func StartBlockSyncTask() {
-	lastBlockHeight := db.GetLastBlockHeight()
-	bestBlockIndex := rpc.GetBestHeight()
+// StartBlockSyncTask starts block sync tasks in the unit.
+// Pending blocks are persisted before the unit returns once ctx is cancelled.
+func StartBlockSyncTask(ctx context.Context, u *supervisor.Unit) {
+	unit = u
+
+	lastBlockHeight := resumeHeight()
+
+	// Fetchers size their first batches by the best block index.
+	if rpcBestHeight := rpc.GetBestHeight(); rpcBestHeight > bestBlockIndex {
+		bestBlockIndex = rpcBestHeight
+	}
 
 	if lastBlockHeight == bestBlockIndex {
 		prog.Finished = true
@@ -43,21 +61,56 @@ func StartBlockSyncTask() {
 		color.Green(" blocks behind"))
 
 	buffer = NewBuffer(lastBlockHeight)
+	worker.reset(config.GetWorkers())
+
+	fetchers := &fetcherPool{}
+	fetchers.spawn(ctx, config.GetWorkers())
+	unit.Go("resize", func() {
+		resizePool(ctx, fetchers)
+	})
+
+	atomic.StoreInt64(&queuedBytes, 0)
+	blockChannel = make(chan *rpc.Block, queueSize)
+	queue := blockChannel
+	unit.Go("arrange", func() {
+		arrangeBlock(ctx, fetchers, queue)
+	})
+	unit.Go("store", func() {
+		storeBlock(lastBlockHeight, queue)
+	})
+}
 
-	for i := 0; i < config.GetWorkers(); i++ {
-		go fetchBlock()
+// resumeHeight returns the height of the last persisted block,
+// or the block before the configured start height on empty db.
+func resumeHeight() int {
+	lastBlockHeight := db.GetLastBlockHeight()
+	if lastBlockHeight == -1 {
+		return int(config.GetStartHeight()) - 1
 	}
 
-	blockChannel = make(chan *rpc.Block, bufferSize)
-	go arrangeBlock(lastBlockHeight, blockChannel)
-	go storeBlock(blockChannel)
+	return lastBlockHeight
 }
 
-func fetchBlock() {
+// syncTarget returns the highest block height to fetch for now.
+func syncTarget() int {
+	if endHeight, ok := config.GetEndHeight(); ok && int(endHeight) < bestBlockIndex {
+		return int(endHeight)
+	}
+
+	return bestBlockIndex
+}
+
+// beyondEnd tells if the height is beyond the configured end height.
+func beyondEnd(height int) bool {
+	endHeight, ok := config.GetEndHeight()
+	return ok && height > int(endHeight)
+}
+
+func fetchBlock(ctx context.Context) {
 	worker.add()
 	log.Infof("Create new worker to fetch blocks\n")
 
-	nextHeight := buffer.GetNextPending()
+	nextHeight, count := buffer.GetNextPendings(config.GetBatchSize(), syncTarget())
 	waited := 0
 
 	defer func() {
@@ -66,8 +119,14 @@ func fetchBlock() {
 	}()
 
 	for {
-		// Control size of the buffer.
-		if buffer.Size() > bufferSize {
+		// Stop fetching new blocks on exit or beyond the end height.
+		if ctx.Err() != nil || unit.IsAborted() || beyondEnd(nextHeight) {
+			worker.remove()
+			return
+		}
+
+		// Control memory of blocks waiting to be persisted.
+		if pendingBytes() > config.GetBlockBufferBytes() {
 			time.Sleep(time.Millisecond * 20)
 			continue
 		}
@@ -79,45 +138,105 @@ func fetchBlock() {
 		}
 
 		// Wait till any upstream fullnode is alive.
-		for rpc.AllFullnodesDown() {
+		for rpc.AllFullnodesDown() && ctx.Err() == nil {
 			time.Sleep(100 * time.Millisecond)
 		}
 
 		// Waiting for the next block.
 		if nextHeight >= bestBlockIndex+1 &&
 			(config.GetWorkers() == 1 || worker.num() == 1) {
-			waiting(&waited, nextHeight)
+			if b := waiting(&waited, nextHeight); b != nil {
+				waited = 0
+				buffer.Put(b)
+				nextHeight = buffer.GetHighest() + 1
+				count = pendingCount(nextHeight, config.GetBatchSize(), syncTarget())
+			}
 			continue
 		}
 
-		// Get new block from upstream fullnodes.
-		b := rpc.SyncBlock(uint(nextHeight))
-		if b == nil {
+		// Get new blocks from upstream fullnodes.
+		blocks := syncBlocks(nextHeight, count)
+		if len(blocks) == 0 {
 			// Quit extra goroutines if beyond the latest block.
 			if nextHeight >= bestBlockIndex &&
 				!rpc.AllFullnodesDown() &&
-				worker.shouldQuit() {
+				worker.quitAbove(1) {
 				return
 			}
 
-			nextHeight = buffer.GetHighest() + 1
+			nextHeight, count = buffer.GetHighest()+1, 1
 			time.Sleep(1 * time.Second)
 			continue
 		}
 
 		waited = 0
-		buffer.Put(b)
+		for _, b := range blocks {
+			buffer.Put(b)
+		}
+
+		atomic.AddInt64(&fetchedBlocks, int64(len(blocks)))
+
+		// Leave the pool if the controller shrank it.
+		if worker.quitAbove(worker.getTarget()) {
+			return
+		}
 
 		if worker.num() == 1 {
 			nextHeight = buffer.GetHighest() + 1
+			count = pendingCount(nextHeight, config.GetBatchSize(), syncTarget())
 		} else {
-			nextHeight = buffer.GetNextPending()
+			nextHeight, count = buffer.GetNextPendings(config.GetBatchSize(), syncTarget())
 		}
 	}
 }
 
-func waiting(waited *int, nextHeight int) {
-	time.Sleep(time.Second)
+// syncBlocks fetches count blocks from start in one batch request,
+// blocks failed in the batch are retried one by one. Blocks failed
+// verification are re-fetched from other fullnodes.
+func syncBlocks(start, count int) []*rpc.Block {
+	if count == 1 {
+		if b := syncBlock(uint(start)); b != nil {
+			return []*rpc.Block{b}
+		}
+
+		return nil
+	}
+
+	indexes := make([]uint, count)
+	for i := range indexes {
+		indexes[i] = uint(start + i)
+	}
+
+	blocks := []*rpc.Block{}
+	for i, b := range rpc.SyncBlocks(indexes) {
+		if b != nil {
+			b = refetch(b)
+		}
+
+		if b == nil && int(indexes[i]) <= bestBlockIndex {
+			b = syncBlock(indexes[i])
+		}
+
+		if b != nil {
+			blocks = append(blocks, b)
+		}
+	}
+
+	return blocks
+}
+
+// waiting waits for a second or the next block pushed by fullnodes.
+// Pushed blocks lack verbose fields, the next block is fetched once pushed.
+func waiting(waited *int, nextHeight int) *rpc.Block {
+	select {
+	case b := <-rpc.NewBlocks():
+		if int(b.Index) >= nextHeight {
+			return syncBlock(uint(nextHeight))
+		}
+
+		return nil
+	case <-time.After(time.Second):
+	}
 
 	if prog.Finished {
 		*waited++
@@ -135,21 +254,41 @@ func waiting(waited *int, nextHeight int) {
 	if (prog.Finished || rpcBestHeight < nextHeight-1) && !rpc.AllFullnodesDown() {
 		log.Infof(msg)
 	}
+
+	return nil
 }
 
-func arrangeBlock(dbHeight int, queue chan<- *rpc.Block) {
+func arrangeBlock(ctx context.Context, fetchers *fetcherPool, queue chan<- *rpc.Block) {
 	const sleepTime = 20
-	height := uint(dbHeight + 1)
 	delay := 0
 
+	defer close(queue)
+
 	for {
-		for rpc.AllFullnodesDown() {
+		if unit.IsAborted() {
+			return
+		}
+
+		if ctx.Err() != nil {
+			drainBuffer(fetchers, queue)
+			return
+		}
+
+		if beyondEnd(buffer.Next()) {
+			endHeight, _ := config.GetEndHeight()
+			log.Info(color.BGreenf("Block sync reached end height %d", endHeight))
+			return
+		}
+
+		for rpc.AllFullnodesDown() && ctx.Err() == nil {
 			time.Sleep(100 * time.Millisecond)
 		}
 
-		if b, ok := buffer.Pop(int(height)); ok {
-			queue <- b
-			height++
+		if b, ok := buffer.PopNext(); ok {
+			if !enqueue(queue, b) {
+				return
+			}
+
 			delay = 0
 			continue
 		}
@@ -159,6 +298,7 @@ func arrangeBlock(dbHeight int, queue chan<- *rpc.Block) {
 			continue
 		}
 		delay += sleepTime
+		height := uint(buffer.Next())
 
 		if delay >= 3000 && delay%1000 == 0 {
 			log.Infof("Waited for %d seconds for block height [%d] in [arrangeBlock]\n", delay/1000, height)
@@ -171,6 +311,41 @@ func arrangeBlock(dbHeight int, queue chan<- *rpc.Block) {
 	}
 }
 
+// drainBuffer waits for in-flight fetches and queues all
+// continuous blocks in the buffer for persistence.
+func drainBuffer(fetchers *fetcherPool, queue chan<- *rpc.Block) {
+	fetchers.wait()
+
+	for {
+		b, ok := buffer.PopNext()
+		if !ok {
+			return
+		}
+
+		if !enqueue(queue, b) {
+			return
+		}
+	}
+}
+
+// enqueue sends the block to be persisted, returns false if the unit aborted.
+func enqueue(queue chan<- *rpc.Block, b *rpc.Block) bool {
+	atomic.AddInt64(&queuedBytes, b.MemSize())
+
+	select {
+	case queue <- b:
+		return true
+	case <-unit.Aborted():
+		return false
+	}
+}
+
+// pendingBytes returns the estimated memory held by blocks
+// fetched but not persisted yet.
+func pendingBytes() int64 {
+	return buffer.Bytes() + atomic.LoadInt64(&queuedBytes)
+}
+
 func getMissingBlock(height uint) {
 	log.Infof("Try fetching given block of height: %d\n", height)
 
@@ -180,20 +355,127 @@ func getMissingBlock(height uint) {
 	}
 }
 
-func storeBlock(ch <-chan *rpc.Block) {
-	var pendingBlockSize = 0
-	rawBlocks := []*rpc.Block{}
+func storeBlock(dbHeight int, ch <-chan *rpc.Block) {
+	policy := config.GetFlushPolicy()
+	pending := pendingBlocks{}
+
+	flush := func() {
+		if len(pending.blocks) > 0 {
+			chain.save(pending.blocks)
+			pending.reset()
+		}
+	}
+
+	// Pending blocks waited too long are flushed without new blocks.
+	var tick <-chan time.Time
+	if policy.MaxLatencyMS > 0 {
+		ticker := time.NewTicker(flushCheckInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	nextIndex := uint(dbHeight + 1)
+	prevHash, nextConsensus := getBlockHeader(dbHeight)
+	network, _ := rpc.GetNetwork()
+
+	for {
+		var block *rpc.Block
+		var ok bool
+
+		select {
+		case block, ok = <-ch:
+		case now := <-tick:
+			if unit.IsAborted() {
+				return
+			}
+
+			if pending.due(policy, false, now) {
+				flush()
+			}
+			continue
+		}
+
+		if !ok {
+			break
+		}
+
+		// Blocks not persisted will be fetched again after restart.
+		if unit.IsAborted() {
+			return
+		}
+
+		atomic.AddInt64(&queuedBytes, -block.MemSize())
+
+		unit.Track("store", fmt.Sprintf("block %d(%s)", block.Index, block.Hash))
+
+		// Drop blocks queued before the last rollback.
+		if block.Index != nextIndex {
+			continue
+		}
+
+		if block.Index > 0 && block.PreviousBlockHash != prevHash {
+			ancestor := handleFork(block)
+			pending.reset()
+			nextIndex = ancestor.Index + 1
+			prevHash = ancestor.Hash
+			nextConsensus = ancestor.NextConsensus
+			continue
+		}
+
+		// Blocks not signed by the expected consensus nodes are fetched again.
+		if err := verifyRawConsensus(network.Network, block, nextConsensus); err != nil {
+			reject(block, err)
+
+			block = refetchSigned(network.Network, block.Index, prevHash, nextConsensus)
+			if block == nil {
+				return
+			}
+		}
+
+		nextIndex++
+		prevHash = block.Hash
+		nextConsensus = block.NextConsensus
+
+		now := time.Now()
+		pending.add(block, now)
+
+		// The last block before the end height is flushed at once.
+		tip := int(block.Index) >= rpc.GetBestHeight() || beyondEnd(int(block.Index)+1)
+		if pending.due(policy, tip, now) {
+			flush()
+		}
+	}
+
+	// Flush blocks left by the last batch on exit.
+	flush()
+
+	log.Info(color.Green("Block sync task stopped"))
+}
+
+// getBlockHeader returns the hash and next consensus of the block.
+func getBlockHeader(index int) (string, string) {
+	if index < 0 {
+		return "", ""
+	}
+
+	b := chain.getBlock(uint(index))
+	if b != nil {
+		return b.Hash, b.NextConsensus
+	}
+
+	// Blocks before the start height are not persisted.
+	if index == int(config.GetStartHeight())-1 {
+		for {
+			if b := syncBlock(uint(index)); b != nil {
+				return b.Hash, b.NextConsensus
+			}
 
-	for block := range ch {
-		rawBlocks = append(rawBlocks, block)
-		pendingBlockSize += block.Size
-		if pendingBlockSize >= 2*1024*1024 ||
-			int(block.Index) == buffer.GetHighest() {
-			store(rawBlocks)
-			rawBlocks = nil
-			pendingBlockSize = 0
+			time.Sleep(1 * time.Second)
 		}
 	}
+
+	log.Panicf("Failed to get block at index %d", index)
+	return "", ""
 }
 
 var bestHeight int
@@ -201,11 +483,16 @@ var bestHeight int
 func store(rawBlocks []*rpc.Block) {
 	maxIndex := int(rawBlocks[len(rawBlocks)-1].Index)
 	blocks := models.ParseBlocks(rawBlocks)
-	txBulk := models.ParseTxs(rawBlocks)
+	txBulk := parseTxs(rawBlocks)
+	txBulk.MultiSigAccounts = multisig.Collect(blocks, txBulk)
 
 	// Cache blocks to help other modules getting blocks quicker if cache hit.
 	block.CacheBlocks(blocks)
-	db.InsertBlock(blocks, txBulk)
+	if err := db.InsertBlock(blocks, txBulk); err != nil {
+		log.Panic(err)
+	}
+
+	multisig.MarkRegistered(txBulk.MultiSigAccounts)
 
 	rpcBestHeight := rpc.GetBestHeight()
 	if rpcBestHeight > bestHeight {
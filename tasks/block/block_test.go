@@ -0,0 +1,343 @@
+package block
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"neo3-squirrel/config"
+	"neo3-squirrel/models"
+	"neo3-squirrel/rpc"
+	"neo3-squirrel/tasks/supervisor"
+	"neo3-squirrel/tasks/util"
+	"neo3-squirrel/tests/fullnode"
+	"neo3-squirrel/util/witness"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncBlocks(t *testing.T) {
+	node := fullnode.Setup(t, rpc.UseFullnodes)
+
+	// Fail block 1 in the first batch request.
+	getBlock := node.DefaultHandler("getblock")
+	failed := false
+	node.Handle("getblock", func(params []json.RawMessage) (interface{}, *fullnode.Error) {
+		if !failed && string(params[0]) == "1" {
+			failed = true
+			return nil, &fullnode.Error{Code: fullnode.ErrCodeUnknownItem, Message: "Unknown block"}
+		}
+
+		return getBlock(params)
+	})
+
+	bestBlockIndex = rpc.GetBestHeight()
+
+	blocks := syncBlocks(0, 3)
+	if len(blocks) != 3 {
+		t.Fatalf("Incorrect 'syncBlocks' result size, expected 3, got %d", len(blocks))
+	}
+
+	for i, b := range blocks {
+		if b.Index != uint(i) {
+			t.Fatalf("Incorrect 'syncBlocks' result, expected index %d, got %d", i, b.Index)
+		}
+	}
+
+	if calls := node.Calls("getblock"); calls != 4 {
+		t.Fatalf("Failed block must be retried once, expected 4 'getblock' calls, got %d", calls)
+	}
+}
+
+func TestGetNextPendings(t *testing.T) {
+	buf := NewBuffer(-1)
+
+	start, count := buf.GetNextPendings(10, 24)
+	if start != 0 || count != 10 {
+		t.Fatalf("Incorrect 'GetNextPendings' result, expected (0, 10), got (%d, %d)", start, count)
+	}
+
+	start, count = buf.GetNextPendings(10, 14)
+	if start != 10 || count != 5 {
+		t.Fatalf("Incorrect 'GetNextPendings' result, expected (10, 5), got (%d, %d)", start, count)
+	}
+
+	// Reserve at least one height beyond the best height.
+	start, count = buf.GetNextPendings(10, 14)
+	if start != 15 || count != 1 {
+		t.Fatalf("Incorrect 'GetNextPendings' result, expected (15, 1), got (%d, %d)", start, count)
+	}
+}
+
+func TestArrangeBlockOnExit(t *testing.T) {
+	buffer = NewBuffer(-1)
+	for _, index := range []uint{0, 1, 3} {
+		buffer.Put(&rpc.Block{Index: index})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	unit = supervisor.NewUnit()
+
+	queue := make(chan *rpc.Block, 10)
+	arrangeBlock(ctx, &fetcherPool{}, queue)
+
+	indexes := []uint{}
+	for b := range queue {
+		indexes = append(indexes, b.Index)
+	}
+
+	if len(indexes) != 2 || indexes[0] != 0 || indexes[1] != 1 {
+		t.Fatalf("Only continuous blocks must be queued on exit, got %v", indexes)
+	}
+}
+
+func TestControllerNext(t *testing.T) {
+	c := controller{maxWorkers: 8, batchSize: 10, bufferBytes: 1 << 20}
+	healthy := rpc.NodeHealth{Total: 2, Healthy: 2, Latency: 50}
+
+	// Grow while behind and the throughput keeps rising.
+	if n := c.next(poolStats{workers: 4, lag: 1000, rate: 100, health: healthy}); n != 6 {
+		t.Fatalf("Pool must grow while behind, expected 6 workers, got %d", n)
+	}
+
+	if n := c.next(poolStats{workers: 6, lag: 1000, rate: 150, health: healthy}); n != 8 {
+		t.Fatalf("Pool must grow while throughput rises, expected 8 workers, got %d", n)
+	}
+
+	// Revert the growth not raising the throughput.
+	if n := c.next(poolStats{workers: 8, lag: 1000, rate: 151, health: healthy}); n != 6 {
+		t.Fatalf("Useless growth must be reverted, expected 6 workers, got %d", n)
+	}
+
+	if n := c.next(poolStats{workers: 6, lag: 1000, rate: 150, health: healthy}); n != 6 {
+		t.Fatalf("Pool size must be held after reverted, expected 6 workers, got %d", n)
+	}
+
+	// Shrink with fewer healthy nodes.
+	halfDown := rpc.NodeHealth{Total: 2, Healthy: 1, Latency: 50}
+	if n := c.next(poolStats{workers: 6, lag: 1000, rate: 150, health: halfDown}); n != 4 {
+		t.Fatalf("Pool must shrink with fewer healthy nodes, expected 4 workers, got %d", n)
+	}
+
+	// Hold during outages.
+	if n := c.next(poolStats{workers: 4, lag: 1000, health: rpc.NodeHealth{Total: 2}}); n != 4 {
+		t.Fatalf("Pool size must be held during outages, expected 4 workers, got %d", n)
+	}
+
+	// Shrink under buffer pressure.
+	if n := c.next(poolStats{workers: 4, lag: 1000, pendingBytes: 1 << 20, health: healthy}); n != 3 {
+		t.Fatalf("Pool must shrink under buffer pressure, expected 3 workers, got %d", n)
+	}
+
+	// Single fetcher near the tip, re-expand once behind again.
+	if n := c.next(poolStats{workers: 3, lag: 2, health: healthy}); n != 1 {
+		t.Fatalf("Single fetcher must be left near the tip, got %d", n)
+	}
+
+	c.hold = 0
+	if n := c.next(poolStats{workers: 1, lag: 1000, health: healthy}); n != 2 {
+		t.Fatalf("Pool must re-expand once behind again, expected 2 workers, got %d", n)
+	}
+}
+
+func TestBufferBytes(t *testing.T) {
+	buf := NewBuffer(-1)
+	b0 := &rpc.Block{Index: 0, Size: 1000}
+	b1 := &rpc.Block{Index: 1, Size: 2000, Tx: make([]rpc.Tx, 2)}
+
+	buf.Put(b0)
+	buf.Put(b1)
+	buf.Put(b1)
+
+	if bytes := buf.Bytes(); bytes != b0.MemSize()+b1.MemSize() {
+		t.Fatalf("Incorrect buffer bytes, expected %d, got %d", b0.MemSize()+b1.MemSize(), bytes)
+	}
+
+	buf.PopNext()
+	if bytes := buf.Bytes(); bytes != b1.MemSize() {
+		t.Fatalf("Popped blocks must be released, expected %d bytes, got %d", b1.MemSize(), bytes)
+	}
+
+	buf.Reset(0)
+	if bytes := buf.Bytes(); bytes != 0 {
+		t.Fatalf("Reset buffer must be empty, got %d bytes", bytes)
+	}
+}
+
+func TestPendingBlocksDue(t *testing.T) {
+	policy := config.FlushPolicy{
+		MaxKB:        1,
+		MaxBlocks:    3,
+		MaxLatencyMS: 1000,
+		TipMode:      true,
+	}
+
+	start := time.Now()
+	pending := pendingBlocks{}
+
+	if pending.due(policy, true, start) {
+		t.Fatalf("Empty pending blocks must not be flushed")
+	}
+
+	pending.add(&rpc.Block{Index: 0, Size: 100}, start)
+	if pending.due(policy, false, start) {
+		t.Fatalf("Pending blocks within limits must not be flushed")
+	}
+
+	if !pending.due(policy, true, start) {
+		t.Fatalf("Blocks at the tip must be flushed in tip mode")
+	}
+
+	if !pending.due(policy, false, start.Add(time.Second)) {
+		t.Fatalf("Pending blocks must be flushed after the max latency")
+	}
+
+	pending.add(&rpc.Block{Index: 1, Size: 100}, start)
+	pending.add(&rpc.Block{Index: 2, Size: 100}, start)
+	if !pending.due(policy, false, start) {
+		t.Fatalf("Pending blocks must be flushed once max blocks reached")
+	}
+
+	pending.reset()
+	pending.add(&rpc.Block{Index: 3, Size: 1024}, start)
+	if !pending.due(policy, false, start) {
+		t.Fatalf("Pending blocks must be flushed once max size reached")
+	}
+
+	policy.TipMode = false
+	pending.reset()
+	pending.add(&rpc.Block{Index: 4, Size: 100}, start)
+	if pending.due(policy, true, start) {
+		t.Fatalf("Blocks at the tip must wait for limits without tip mode")
+	}
+}
+
+func TestVerifyConsensus(t *testing.T) {
+	blocks := []*rpc.Block{}
+	for i := 0; i < 3; i++ {
+		data, err := ioutil.ReadFile(filepath.Join(fullnode.Testdata(), "blocks", fmt.Sprintf("%d.json", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b := rpc.Block{}
+		if err := json.Unmarshal(data, &b); err != nil {
+			t.Fatal(err)
+		}
+
+		blocks = append(blocks, &b)
+	}
+
+	const magic = 860833102
+	for i := 1; i < len(blocks); i++ {
+		if err := verifyRawConsensus(magic, blocks[i], blocks[i-1].NextConsensus); err != nil {
+			t.Fatalf("Block %d must be signed by the next consensus of block %d, got %v", i, i-1, err)
+		}
+	}
+
+	if err := verifyRawConsensus(magic+1, blocks[1], blocks[0].NextConsensus); err == nil {
+		t.Fatal("Witness signed for another network must be rejected")
+	}
+
+	if err := verifyRawConsensus(magic, blocks[1], "NdKTKwjJs7sxAspjTzn7a1CCahTva7cy6s"); err == nil {
+		t.Fatal("Witness not from the next consensus must be rejected")
+	}
+
+	forged := *blocks[2]
+	forged.Witnesses = blocks[1].Witnesses
+	if err := verifyRawConsensus(magic, &forged, blocks[1].NextConsensus); err == nil {
+		t.Fatal("Witness signed for another block must be rejected")
+	}
+}
+
+func TestParsePubKeys(t *testing.T) {
+	pubKey := "02562e7ff2f939d160a7db692fb6edd5a2e6f85d6c92027f88b6604b867713a159"
+	address := "NfGnDMchMSJfMqbHa4H6txnuC981Vagccs"
+	account := "0x" + util.GetAddrScriptHash(address)
+
+	txBulk := &models.TxBulk{
+		Txs: []*models.Transaction{
+			{Hash: "0x01", BlockIndex: 20},
+			{Hash: "0x02", BlockIndex: 10},
+			{Hash: "0x03", BlockIndex: 5},
+		},
+		TxSignerWitnesses: []*models.TransactionSignerWitness{
+			{TransactionHash: "0x01", Account: account, Type: witness.Standard, PubKeys: []string{pubKey}},
+			{TransactionHash: "0x02", Account: account, Type: witness.Standard, PubKeys: []string{pubKey}},
+			// Witnesses of other accounts and invalid ones are skipped.
+			{TransactionHash: "0x03", Account: "0x00", Type: witness.Standard, PubKeys: []string{pubKey}},
+			{TransactionHash: "0x03", Account: account, Type: witness.Standard, PubKeys: []string{pubKey}, Signature: models.SignatureInvalid},
+		},
+	}
+
+	pubKeys := parsePubKeys(txBulk)
+	if len(pubKeys) != 1 {
+		t.Fatalf("Expected 1 public key, got %d", len(pubKeys))
+	}
+
+	if pubKeys[0].Address != address || pubKeys[0].PubKey != pubKey || pubKeys[0].BlockIndex != 10 {
+		t.Fatalf("Incorrect public key: %+v", pubKeys[0])
+	}
+}
+
+func TestWaitingPushedBlock(t *testing.T) {
+	node := fullnode.Setup(t, rpc.UseFullnodes)
+
+	// Refresh the node height after it is lowered.
+	node.SetHeight(0)
+	rpc.UseFullnodes(node.URL)
+	rpc.SubscribeBlocks(node.URL, node.WSURL)
+
+	for i := 0; node.Subscribers() == 0; i++ {
+		if i == 50 {
+			t.Fatal("Block subscription not established")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	node.SetHeight(1)
+	if err := node.PushBlock(1); err != nil {
+		t.Fatal(err)
+	}
+
+	waited := 0
+	var b *rpc.Block
+	for i := 0; i < 5 && b == nil; i++ {
+		b = waiting(&waited, 1)
+	}
+
+	if b == nil {
+		t.Fatal("Pushed block must be fetched")
+	}
+
+	// The pushed payload has no size, the block must be fetched in full.
+	if saved := models.ParseBlocks([]*rpc.Block{b})[0]; saved.Size != 362 {
+		t.Fatalf("Incorrect size of the pushed block, expected 362, got %d", saved.Size)
+	}
+}
+
+func TestTaskStateIndicator(t *testing.T) {
+	states := map[string]supervisor.TaskState{
+		"block": {State: supervisor.Running},
+	}
+
+	if indicator := taskStateIndicator(states); indicator != "" {
+		t.Fatalf("Healthy tasks must not be shown, got %q", indicator)
+	}
+
+	states["nep17"] = supervisor.TaskState{
+		State:       supervisor.Restarting,
+		Restarts:    2,
+		LastFailure: &supervisor.Failure{Stage: "persist", Err: "bad item"},
+	}
+	states["applog"] = supervisor.TaskState{State: supervisor.Stopped}
+
+	expected := "applog stopped, 0 restarts; nep17 restarting, 2 restarts, last failure in persist: bad item"
+	if indicator := taskStateIndicator(states); !strings.Contains(indicator, expected) {
+		t.Fatalf("Task state indicator must contain %q, got %q", expected, indicator)
+	}
+}
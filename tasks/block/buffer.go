@@ -17,6 +17,8 @@ type Buffer struct {
 	// used before blockchain fully synchronized.
 	nextHeight int
 	buffer     map[int]*rpc.Block
+	// bytes is the estimated memory held by buffered blocks.
+	bytes int64
 }
 
 // NewBuffer inits a new block buffer.
@@ -29,23 +31,42 @@ func NewBuffer(height int) Buffer {
 	}
 }
 
-// Pop pops the specific block by id.
-func (b *Buffer) Pop(index int) (*rpc.Block, bool) {
+// PopNext pops the block next to the last popped one.
+func (b *Buffer) PopNext() (*rpc.Block, bool) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	index := b.minHeight + 1
 	if block, ok := b.buffer[index]; ok {
 		delete(b.buffer, index)
-
-		if b.minHeight < int(block.Index) {
-			b.minHeight = int(block.Index)
-		}
+		b.minHeight = index
+		b.bytes -= block.MemSize()
 
 		return block, true
 	}
 	return nil, false
 }
 
+// Next returns the block index PopNext waits for.
+func (b *Buffer) Next() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.minHeight + 1
+}
+
+// Reset drops all buffered blocks and restarts from the given height.
+func (b *Buffer) Reset(height int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.minHeight = height
+	b.maxHeight = height
+	b.nextHeight = height
+	b.buffer = make(map[int]*rpc.Block)
+	b.bytes = 0
+}
+
 // GetHighest returns the highest existing block height.
 func (b *Buffer) GetHighest() int {
 	b.mu.Lock()
@@ -63,6 +84,39 @@ func (b *Buffer) GetNextPending() int {
 	return b.nextHeight
 }
 
+// GetNextPendings reserves at most maxCount heights to fetch, limited to
+// maxHeight unless no height can be reserved. Returns the first reserved
+// height and the reserved count.
+func (b *Buffer) GetNextPendings(maxCount, maxHeight int) (int, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Skip heights fetched by a single fetcher following the highest block.
+	if b.nextHeight < b.maxHeight {
+		b.nextHeight = b.maxHeight
+	}
+
+	start := b.nextHeight + 1
+	count := pendingCount(start, maxCount, maxHeight)
+	b.nextHeight += count
+
+	return start, count
+}
+
+// pendingCount returns the number of heights to fetch from start.
+func pendingCount(start, maxCount, maxHeight int) int {
+	count := maxHeight - start + 1
+	if count > maxCount {
+		count = maxCount
+	}
+
+	if count < 1 {
+		count = 1
+	}
+
+	return count
+}
+
 // Put adds the given block into buffer and update maxHeight.
 func (b *Buffer) Put(block *rpc.Block) {
 	b.mu.Lock()
@@ -72,7 +126,12 @@ func (b *Buffer) Put(block *rpc.Block) {
 		return
 	}
 
+	if prev, ok := b.buffer[int(block.Index)]; ok {
+		b.bytes -= prev.MemSize()
+	}
+
 	b.buffer[int(block.Index)] = block
+	b.bytes += block.MemSize()
 	if b.maxHeight < int(block.Index) {
 		b.maxHeight = int(block.Index)
 	}
@@ -85,3 +144,11 @@ func (b *Buffer) Size() int {
 
 	return len(b.buffer)
 }
+
+// Bytes returns the estimated memory held by buffered blocks.
+func (b *Buffer) Bytes() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.bytes
+}
@@ -0,0 +1,56 @@
+package block
+
+import (
+	"neo3-squirrel/config"
+	"neo3-squirrel/rpc"
+	"time"
+)
+
+// flushCheckInterval is the interval to check the latency of pending blocks.
+const flushCheckInterval = 100 * time.Millisecond
+
+// pendingBlocks holds ordered blocks waiting to be persisted.
+type pendingBlocks struct {
+	blocks []*rpc.Block
+	// size is the serialized size of pending blocks.
+	size int
+	// since is the time the first pending block arrived.
+	since time.Time
+}
+
+func (p *pendingBlocks) add(b *rpc.Block, now time.Time) {
+	if len(p.blocks) == 0 {
+		p.since = now
+	}
+
+	p.blocks = append(p.blocks, b)
+	p.size += b.Size
+}
+
+func (p *pendingBlocks) reset() {
+	p.blocks = nil
+	p.size = 0
+}
+
+// due tells if pending blocks should be flushed by the policy,
+// tip tells if the last pending block is at the chain tip.
+func (p *pendingBlocks) due(policy config.FlushPolicy, tip bool, now time.Time) bool {
+	if len(p.blocks) == 0 {
+		return false
+	}
+
+	maxLatency := time.Duration(policy.MaxLatencyMS) * time.Millisecond
+
+	switch {
+	case tip && policy.TipMode:
+		return true
+	case policy.MaxKB > 0 && p.size >= policy.MaxKB<<10:
+		return true
+	case policy.MaxBlocks > 0 && len(p.blocks) >= policy.MaxBlocks:
+		return true
+	case maxLatency > 0 && now.Sub(p.since) >= maxLatency:
+		return true
+	}
+
+	return false
+}
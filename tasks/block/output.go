@@ -3,13 +3,17 @@ package block
 import (
 	"fmt"
 	"math/big"
+	"neo3-squirrel/cache/block"
+	"neo3-squirrel/config"
 	"neo3-squirrel/db"
 	"neo3-squirrel/rpc"
 	"neo3-squirrel/tasks/applog"
 	"neo3-squirrel/tasks/nep17"
+	"neo3-squirrel/tasks/supervisor"
 	"neo3-squirrel/util/color"
 	"neo3-squirrel/util/log"
 	"neo3-squirrel/util/progress"
+	"sort"
 	"strings"
 	"time"
 )
@@ -48,15 +52,35 @@ func showBlockStorageProgress(maxIndex int64, highestIndex int64) {
 	}
 
 	msgs := []string{msg}
+	if !prog.Finished {
+		msgs = append(msgs, memoryUsageIndicator())
+	}
+
 	if prog.Finished && !rpc.AllFullnodesDown() {
 		msgs = append(msgs, appLogSyncProgressIndicator(uint(maxIndex)))
 		msgs = append(msgs, nep17SyncProgressIndicator(uint(maxIndex)))
 	}
 
+	msgs = append(msgs, taskStateIndicator(supervisor.GetStates()))
+
 	log.Infof(strings.Join(msgs, " "))
 	prog.LastOutputTime = now
 }
 
+// memoryUsageIndicator shows the estimated memory held by
+// blocks waiting to be persisted and cached blocks.
+func memoryUsageIndicator() string {
+	cachedBytes, cachedBlocks := block.Usage()
+
+	return fmt.Sprintf("[buffer %s/%s, cache %s/%s of %d blocks]",
+		formatMB(pendingBytes()), formatMB(config.GetBlockBufferBytes()),
+		formatMB(cachedBytes), formatMB(config.GetBlockCacheBytes()), cachedBlocks)
+}
+
+func formatMB(bytes int64) string {
+	return fmt.Sprintf("%.1fMB", float64(bytes)/(1<<20))
+}
+
 func appLogSyncProgressIndicator(currBlockIndex uint) string {
 	lastBlockIndex := applog.LastAppLogBlockIndex
 	lastNoti := db.GetLastNotification()
@@ -98,3 +122,33 @@ func nep17SyncProgressIndicator(currBlockIndex uint) string {
 	offset := lastNoti.BlockIndex - lastBlockIndex
 	return fmt.Sprintf("[nep17 tx left %d blocks]", offset)
 }
+
+// taskStateIndicator shows supervised tasks which are not running
+// or have been restarted, together with their last failures.
+func taskStateIndicator(states map[string]supervisor.TaskState) string {
+	names := []string{}
+	for name, state := range states {
+		if state.State != supervisor.Running || state.Restarts > 0 {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return ""
+	}
+
+	sort.Strings(names)
+
+	indicators := make([]string, len(names))
+	for i, name := range names {
+		state := states[name]
+		indicator := fmt.Sprintf("%s %s, %d restarts", name, state.State, state.Restarts)
+		if failure := state.LastFailure; failure != nil {
+			indicator += fmt.Sprintf(", last failure in %s: %s", failure.Stage, failure.Err)
+		}
+
+		indicators[i] = indicator
+	}
+
+	return color.Yellowf("[%s]", strings.Join(indicators, "; "))
+}
@@ -0,0 +1,199 @@
+package block
+
+import (
+	"context"
+	"neo3-squirrel/config"
+	"neo3-squirrel/rpc"
+	"neo3-squirrel/util/color"
+	"neo3-squirrel/util/log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// resizeInterval is the interval between fetcher pool resizing.
+	resizeInterval = 5 * time.Second
+	// resizeCooldown is the number of intervals to hold the pool size
+	// after growing it failed to raise the throughput.
+	resizeCooldown = 6
+)
+
+// fetchedBlocks counts blocks fetched by all fetchers.
+var fetchedBlocks int64
+
+// fetcherPool runs fetchBlock goroutines in the unit.
+type fetcherPool struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	stopped bool
+}
+
+// spawn starts n more fetchers unless the pool stopped.
+func (pool *fetcherPool) spawn(ctx context.Context, n int) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if pool.stopped {
+		return
+	}
+
+	for i := 0; i < n; i++ {
+		pool.wg.Add(1)
+		unit.Go("fetch", func() {
+			defer pool.wg.Done()
+			fetchBlock(ctx)
+		})
+	}
+}
+
+// wait stops spawning fetchers and waits for running ones to return.
+func (pool *fetcherPool) wait() {
+	pool.mu.Lock()
+	pool.stopped = true
+	pool.mu.Unlock()
+
+	pool.wg.Wait()
+}
+
+// poolStats is sampled by the controller every interval.
+type poolStats struct {
+	workers int
+	// lag is the number of blocks behind the sync target.
+	lag int
+	// pendingBytes is the memory held by blocks waiting to be persisted.
+	pendingBytes int64
+	// rate is the number of blocks fetched per second.
+	rate   float64
+	health rpc.NodeHealth
+}
+
+// controller sizes the fetcher pool. It grows the pool while the
+// indexer falls behind and the throughput keeps rising, and shrinks
+// it near the tip, under buffer pressure, or when nodes get slow or down.
+type controller struct {
+	maxWorkers  int
+	batchSize   int
+	bufferBytes int64
+
+	// grown tells if the last decision grew the pool from prevWorkers,
+	// rate and latency are sampled before that.
+	grown       bool
+	prevWorkers int
+	rate        float64
+	latency     float64
+
+	// hold is the number of intervals left to hold the pool size.
+	hold int
+}
+
+// next returns the pool size for the sampled stats.
+func (c *controller) next(s poolStats) int {
+	grown := c.grown
+	c.grown = false
+
+	// Hold the pool during outages, fetchers wait for nodes themselves.
+	if s.health.Healthy == 0 {
+		return s.workers
+	}
+
+	// Fewer healthy nodes serve fewer fetchers.
+	ceiling := c.maxWorkers
+	if s.health.Total > 0 {
+		ceiling = c.maxWorkers * s.health.Healthy / s.health.Total
+	}
+	if ceiling < 1 {
+		ceiling = 1
+	}
+
+	switch {
+	case s.lag <= c.batchSize:
+		// A single fetcher follows new blocks near the tip.
+		return 1
+	case s.pendingBytes >= c.bufferBytes*3/4:
+		// Persistence cannot keep up with fetchers.
+		return limit(s.workers-1, ceiling)
+	case s.workers > ceiling:
+		return ceiling
+	case grown && (s.rate < c.rate*1.05 ||
+		(c.latency > 0 && s.health.Latency > c.latency*1.5)):
+		// The last growth saturated nodes without raising the throughput.
+		c.hold = resizeCooldown
+		return limit(c.prevWorkers, ceiling)
+	case c.hold > 0:
+		c.hold--
+		return s.workers
+	case s.workers < ceiling:
+		c.grown = true
+		c.prevWorkers = s.workers
+		c.rate = s.rate
+		c.latency = s.health.Latency
+		return limit(s.workers+s.workers/4+1, ceiling)
+	}
+
+	return s.workers
+}
+
+func limit(workers, ceiling int) int {
+	if workers > ceiling {
+		workers = ceiling
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	return workers
+}
+
+// resizePool resizes the fetcher pool every interval till the unit stops.
+func resizePool(ctx context.Context, pool *fetcherPool) {
+	c := controller{
+		maxWorkers:  config.GetWorkers(),
+		batchSize:   config.GetBatchSize(),
+		bufferBytes: config.GetBlockBufferBytes(),
+	}
+
+	ticker := time.NewTicker(resizeInterval)
+	defer ticker.Stop()
+
+	lastFetched := atomic.LoadInt64(&fetchedBlocks)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-unit.Aborted():
+			return
+		case <-ticker.C:
+		}
+
+		// Fetchers have stopped beyond the end height.
+		if beyondEnd(buffer.Next()) {
+			return
+		}
+
+		fetched := atomic.LoadInt64(&fetchedBlocks)
+		stats := poolStats{
+			workers:      int(worker.num()),
+			lag:          syncTarget() - buffer.GetHighest(),
+			pendingBytes: pendingBytes(),
+			rate:         float64(fetched-lastFetched) / resizeInterval.Seconds(),
+			health:       rpc.GetNodeHealth(),
+		}
+		lastFetched = fetched
+
+		target := c.next(stats)
+		worker.setTarget(target)
+
+		if target == stats.workers {
+			continue
+		}
+
+		log.Info(color.Greenf("Resize block fetchers %d -> %d (%.1f blocks/s, %d blocks behind, %d/%d nodes healthy)",
+			stats.workers, target, stats.rate, stats.lag, stats.health.Healthy, stats.health.Total))
+
+		if target > stats.workers {
+			pool.spawn(ctx, target-stats.workers)
+		}
+	}
+}
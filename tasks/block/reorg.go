@@ -0,0 +1,111 @@
+package block
+
+import (
+	"neo3-squirrel/cache/address"
+	"neo3-squirrel/cache/asset"
+	"neo3-squirrel/cache/block"
+	"neo3-squirrel/config"
+	"neo3-squirrel/db"
+	"neo3-squirrel/models"
+	"neo3-squirrel/rpc"
+	"neo3-squirrel/tasks/contract"
+	"neo3-squirrel/tasks/nep17"
+	"neo3-squirrel/tasks/reorg"
+	"neo3-squirrel/util/color"
+	"neo3-squirrel/util/log"
+	"time"
+)
+
+// maxReorgDepth limits how far back the common ancestor will be searched.
+const maxReorgDepth = 1000
+
+// chain reads and writes persisted blocks, replaced in tests.
+var chain = struct {
+	lastHeight func() int
+	getBlock   func(index uint) *models.Block
+	rollback   func(height uint)
+	save       func(rawBlocks []*rpc.Block)
+}{
+	lastHeight: db.GetLastBlockHeight,
+	getBlock:   db.GetBlock,
+	rollback:   Rollback,
+	save:       store,
+}
+
+// handleFork rolls back persisted blocks to the common ancestor
+// of the local chain and the upstream chain, then restarts block
+// fetching from there. Returns the common ancestor block.
+func handleFork(b *rpc.Block) *models.Block {
+	log.Warn(color.BYellowf("Chain fork detected at block %d: previous block hash %s not persisted",
+		b.Index, b.PreviousBlockHash))
+
+	ancestor := findCommonAncestor()
+	if int(ancestor.Index) < chain.lastHeight() {
+		log.Warn(color.BYellowf("Roll back persisted blocks to common ancestor %d(%s)",
+			ancestor.Index, ancestor.Hash))
+		chain.rollback(ancestor.Index)
+	}
+
+	buffer.Reset(int(ancestor.Index))
+
+	return ancestor
+}
+
+// findCommonAncestor returns the highest persisted block
+// which has the same hash from upstream fullnodes.
+func findCommonAncestor() *models.Block {
+	lastHeight := chain.lastHeight()
+	startHeight := int(config.GetStartHeight())
+	height := lastHeight
+
+	for height >= 0 {
+		if lastHeight-height > maxReorgDepth {
+			log.Panicf("Chain fork deeper than %d blocks from height %d", maxReorgDepth, lastHeight)
+		}
+
+		if height < startHeight {
+			log.Panicf("Chain fork below the start height %d", startHeight)
+		}
+
+		local := chain.getBlock(uint(height))
+		if local == nil {
+			log.Panicf("Failed to get block at index %d", height)
+		}
+
+		upstream := rpc.GetBlockHash(uint(height))
+		if upstream == "" {
+			time.Sleep(1 * time.Second)
+			continue
+		}
+
+		if local.Hash == upstream {
+			return local
+		}
+
+		height--
+	}
+
+	log.Panic("Genesis block mismatch, upstream fullnodes are running on another chain")
+	return nil
+}
+
+// Rollback removes all blocks and their derived data above the given
+// height, other tasks will restart from their db checkpoints.
+func Rollback(height uint) {
+	deployedContracts, revertedContracts := contract.RollbackStates(height)
+
+	reorg.Apply(func() {
+		result := db.Rollback(height, deployedContracts, revertedContracts)
+		if result == nil {
+			log.Panicf("Failed to roll back to block %d", height)
+		}
+
+		block.Truncate(height)
+		address.Remove(result.Addresses...)
+		for _, assetHash := range result.Assets {
+			asset.Remove(assetHash)
+		}
+
+		nep17.ResyncBalances(height, result.Balances)
+	})
+}
@@ -0,0 +1,194 @@
+package block
+
+import (
+	"neo3-squirrel/models"
+	"neo3-squirrel/rpc"
+	"neo3-squirrel/tasks/reorg"
+	"neo3-squirrel/tasks/supervisor"
+	"neo3-squirrel/tests/fullnode"
+	"strings"
+	"testing"
+)
+
+// fakeChain keeps persisted blocks in memory.
+type fakeChain struct {
+	blocks     []*models.Block
+	rolledBack []uint
+	saved      []uint
+}
+
+func (c *fakeChain) use() func() {
+	origin := chain
+
+	chain.lastHeight = func() int {
+		return len(c.blocks) - 1
+	}
+	chain.getBlock = func(index uint) *models.Block {
+		if int(index) >= len(c.blocks) {
+			return nil
+		}
+		return c.blocks[index]
+	}
+	chain.rollback = func(height uint) {
+		c.rolledBack = append(c.rolledBack, height)
+		c.blocks = c.blocks[:height+1]
+	}
+	chain.save = func(rawBlocks []*rpc.Block) {
+		for _, b := range models.ParseBlocks(rawBlocks) {
+			c.saved = append(c.saved, b.Index)
+			c.blocks = append(c.blocks, b)
+		}
+	}
+
+	return func() {
+		chain = origin
+	}
+}
+
+// useUpstream serves fixture blocks from a fake fullnode,
+// and returns them as raw blocks.
+func useUpstream(t *testing.T) []*rpc.Block {
+	fullnode.Setup(t, rpc.UseFullnodes)
+	bestBlockIndex = rpc.GetBestHeight()
+
+	blocks := []*rpc.Block{}
+	for i := uint(0); i < 3; i++ {
+		b := rpc.SyncBlock(i)
+		if b == nil {
+			t.Fatalf("Failed to get upstream block %d", i)
+		}
+		blocks = append(blocks, b)
+	}
+
+	return blocks
+}
+
+// persisted converts raw blocks to persisted blocks,
+// blocks with forked set have their hashes replaced.
+func persisted(raw []*rpc.Block, forked ...bool) []*models.Block {
+	blocks := models.ParseBlocks(raw)
+	for i, fork := range forked {
+		if fork {
+			b := *blocks[i]
+			b.Hash = "0x" + strings.Repeat("f", 64)
+			blocks[i] = &b
+		}
+	}
+
+	return blocks
+}
+
+func TestHandleFork(t *testing.T) {
+	upstream := useUpstream(t)
+
+	cases := []struct {
+		name         string
+		local        []*models.Block
+		ancestor     uint
+		rolledBack   []uint
+		genesisForks bool
+	}{
+		{
+			name:       "forked tip",
+			local:      persisted(upstream, false, false, true),
+			ancestor:   1,
+			rolledBack: []uint{1},
+		},
+		{
+			name:       "forked below tip",
+			local:      persisted(upstream, false, true, true),
+			ancestor:   0,
+			rolledBack: []uint{0},
+		},
+		{
+			name:     "tip not forked",
+			local:    persisted(upstream[:2]),
+			ancestor: 1,
+		},
+		{
+			name:         "genesis mismatch",
+			local:        persisted(upstream[:1], true),
+			genesisForks: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			local := &fakeChain{blocks: c.local}
+			defer local.use()()
+
+			buffer = NewBuffer(len(c.local) - 1)
+
+			defer func() {
+				r := recover()
+				if c.genesisForks != (r != nil) {
+					t.Fatalf("Genesis mismatch must panic only, got %v", r)
+				}
+			}()
+
+			ancestor := handleFork(upstream[len(c.local)-1])
+
+			if ancestor.Index != c.ancestor || ancestor.Hash != upstream[c.ancestor].Hash {
+				t.Fatalf("Incorrect common ancestor, expected %d, got %d(%s)", c.ancestor, ancestor.Index, ancestor.Hash)
+			}
+
+			if len(local.rolledBack) != len(c.rolledBack) ||
+				(len(c.rolledBack) > 0 && local.rolledBack[0] != c.rolledBack[0]) {
+				t.Fatalf("Incorrect rollbacks, expected %v, got %v", c.rolledBack, local.rolledBack)
+			}
+
+			// Fetching restarts from the block next to the ancestor.
+			if next := buffer.Next(); next != int(c.ancestor)+1 {
+				t.Fatalf("Incorrect next block to fetch, expected %d, got %d", c.ancestor+1, next)
+			}
+		})
+	}
+}
+
+func TestStoreBlockResync(t *testing.T) {
+	upstream := useUpstream(t)
+
+	// Block 1 persisted locally was orphaned upstream.
+	local := &fakeChain{blocks: persisted(upstream[:2], false, true)}
+	defer local.use()()
+
+	buffer = NewBuffer(1)
+	unit = supervisor.NewUnit()
+
+	// Block 2 arrives first, blocks 1 and 2 are fetched again after the rollback.
+	ch := make(chan *rpc.Block, 3)
+	for _, b := range []*rpc.Block{upstream[2], upstream[1], upstream[2]} {
+		ch <- b
+	}
+	close(ch)
+
+	storeBlock(1, ch)
+
+	if len(local.rolledBack) != 1 || local.rolledBack[0] != 0 {
+		t.Fatalf("Chain must be rolled back to block 0, got %v", local.rolledBack)
+	}
+
+	if len(local.saved) != 2 || local.saved[0] != 1 || local.saved[1] != 2 {
+		t.Fatalf("Blocks 1 and 2 must be saved after the rollback, got %v", local.saved)
+	}
+
+	for i, b := range local.blocks {
+		if b.Hash != upstream[i].Hash {
+			t.Fatalf("Block %d must be resynced from upstream, got %s", i, b.Hash)
+		}
+	}
+}
+
+func TestBackfillAfterRollback(t *testing.T) {
+	useUpstream(t)
+
+	u := supervisor.NewUnit()
+
+	// Blocks fetched before the rollback are not saved.
+	epoch := reorg.Epoch()
+	reorg.Apply(func() {})
+
+	if backfillBlocks(u, epoch, 0, 2, false) {
+		t.Fatalf("Blocks fetched before the rollback must not be saved")
+	}
+}
@@ -0,0 +1,49 @@
+package block
+
+import (
+	"errors"
+	"neo3-squirrel/rpc"
+	"neo3-squirrel/util/color"
+	"neo3-squirrel/util/log"
+)
+
+// verified tells if hashes of the block match its content.
+// Fullnodes serving invalid blocks are quarantined.
+func verified(b *rpc.Block) bool {
+	err := b.Verify()
+	if err == nil {
+		return true
+	}
+
+	// Blocks with content unknown to the verification are trusted.
+	if errors.Is(err, rpc.ErrUnsupported) {
+		log.Warn(color.BYellowf("Block %d not verified: %v", b.Index, err))
+		return true
+	}
+
+	reject(b, err)
+	return false
+}
+
+// reject quarantines the fullnode serving the invalid block.
+func reject(b *rpc.Block, err error) {
+	log.Error(color.Redf("Block %d from %s rejected: %v", b.Index, b.Source, err))
+	rpc.ReportInvalidBlock(b, err)
+}
+
+// refetch fetches the block again from fullnodes not serving
+// invalid ones, till a valid one is got or no fullnode left.
+func refetch(b *rpc.Block) *rpc.Block {
+	excluded := map[string]bool{}
+	for b != nil && !verified(b) {
+		excluded[b.Source] = true
+		b = rpc.SyncBlockExcept(b.Index, excluded)
+	}
+
+	return b
+}
+
+// syncBlock fetches a verified block of the index.
+func syncBlock(index uint) *rpc.Block {
+	return refetch(rpc.SyncBlock(index))
+}
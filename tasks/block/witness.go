@@ -0,0 +1,230 @@
+package block
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"neo3-squirrel/config"
+	"neo3-squirrel/db"
+	"neo3-squirrel/models"
+	"neo3-squirrel/rpc"
+	"neo3-squirrel/tasks/util"
+	"neo3-squirrel/util/base58"
+	"neo3-squirrel/util/byteutil"
+	"neo3-squirrel/util/color"
+	"neo3-squirrel/util/hashutil"
+	"neo3-squirrel/util/log"
+	"neo3-squirrel/util/witness"
+	"sort"
+	"strings"
+	"time"
+)
+
+// witnessScanStep is the number of blocks verified by each query.
+const witnessScanStep = 1000
+
+// verifyConsensus verifies the block is signed by the consensus nodes
+// of the next consensus address of its previous block.
+func verifyConsensus(magic uint32, b *models.Block, nextConsensus string) error {
+	// The genesis block is not signed.
+	if b.Index == 0 {
+		return nil
+	}
+
+	if len(b.Witnesses) != 1 {
+		return fmt.Errorf("expected 1 witness, got %d", len(b.Witnesses))
+	}
+
+	invocation, err := base64.StdEncoding.DecodeString(b.Witnesses[0].Invocation)
+	if err != nil {
+		return err
+	}
+
+	verification, err := base64.StdEncoding.DecodeString(b.Witnesses[0].Verification)
+	if err != nil {
+		return err
+	}
+
+	consensus, err := base58.CheckDecode(nextConsensus)
+	if err != nil || len(consensus) != 21 {
+		return fmt.Errorf("invalid next consensus %q", nextConsensus)
+	}
+
+	if !bytes.Equal(hashutil.Hash160(verification), consensus[1:]) {
+		return fmt.Errorf("witness not from next consensus %s", nextConsensus)
+	}
+
+	hash, err := hex.DecodeString(strings.TrimPrefix(b.Hash, "0x"))
+	if err != nil {
+		return err
+	}
+
+	signData := witness.GetSignData(magic, byteutil.ReverseBytes(hash))
+	return witness.Verify(signData, invocation, verification)
+}
+
+// verifyRawConsensus works like verifyConsensus for blocks from fullnodes.
+func verifyRawConsensus(magic uint32, b *rpc.Block, nextConsensus string) error {
+	block := models.Block{Index: b.Index, Hash: b.Hash}
+	for _, w := range b.Witnesses {
+		block.Witnesses = append(block.Witnesses, models.Witness{
+			Invocation:   w.Invocation,
+			Verification: w.Verification,
+		})
+	}
+
+	return verifyConsensus(magic, &block, nextConsensus)
+}
+
+// refetchSigned fetches the block of the index from fullnodes till one
+// extends the previous block and is signed by the expected consensus nodes.
+// Returns nil if the unit aborted.
+func refetchSigned(magic uint32, index uint, prevHash, nextConsensus string) *rpc.Block {
+	excluded := map[string]bool{}
+
+	for !unit.IsAborted() {
+		b := rpc.SyncBlockExcept(index, excluded)
+		if b == nil {
+			// Fullnodes rejected are retried once released from quarantine.
+			excluded = map[string]bool{}
+			time.Sleep(1 * time.Second)
+			continue
+		}
+
+		if !verified(b) || b.PreviousBlockHash != prevHash {
+			excluded[b.Source] = true
+			continue
+		}
+
+		if err := verifyRawConsensus(magic, b, nextConsensus); err != nil {
+			reject(b, err)
+			excluded[b.Source] = true
+			continue
+		}
+
+		return b
+	}
+
+	return nil
+}
+
+// VerifyWitnesses verifies consensus witnesses of all persisted blocks
+// offline, and returns indexes of blocks with invalid witnesses.
+func VerifyWitnesses(magic uint32) []uint {
+	lastHeight := db.GetLastBlockHeight()
+	invalid := []uint{}
+
+	// The previous block of the start height is not persisted.
+	from := config.GetStartHeight()
+	if from > 0 {
+		from++
+	}
+
+	for ; int(from) <= lastHeight; from += witnessScanStep {
+		to := from + witnessScanStep - 1
+		if int(to) > lastHeight {
+			to = uint(lastHeight)
+		}
+
+		// Blocks are verified against the previous ones.
+		lo := from
+		if lo > 0 {
+			lo--
+		}
+
+		var prev *models.Block
+		for _, b := range db.GetBlocksWithWitnesses(lo, to) {
+			if b.Index < from {
+				prev = b
+				continue
+			}
+
+			switch {
+			case b.Index == 0:
+				// The genesis block is not signed.
+			case prev == nil || prev.Index+1 != b.Index:
+				log.Warn(color.BYellowf("Block %d skipped: previous block not persisted", b.Index))
+			default:
+				if err := verifyConsensus(magic, b, prev.NextConsensus); err != nil {
+					log.Error(color.Redf("Block %d(%s) has invalid witness: %v", b.Index, b.Hash, err))
+					invalid = append(invalid, b.Index)
+				}
+			}
+
+			prev = b
+		}
+
+		log.Infof("Verified witnesses of blocks %d-%d", from, to)
+	}
+
+	return invalid
+}
+
+// parseTxs parses transactions of the blocks, signatures
+// of tx witnesses are verified if enabled.
+func parseTxs(blocks []*rpc.Block) *models.TxBulk {
+	txBulk := models.ParseTxs(blocks)
+	if config.VerifyTxWitnesses() {
+		network, _ := rpc.GetNetwork()
+		for _, w := range txBulk.TxSignerWitnesses {
+			w.Verify(network.Network)
+			if w.Signature == models.SignatureInvalid {
+				log.Warn(color.BYellowf("Invalid witness of signer %s in tx %s", w.Account, w.TransactionHash))
+			}
+		}
+	}
+
+	txBulk.PubKeys = parsePubKeys(txBulk)
+	return txBulk
+}
+
+// parsePubKeys collects public keys of standard accounts from signer
+// witnesses, with the first block each account signed in.
+func parsePubKeys(txBulk *models.TxBulk) []*models.PubKey {
+	blockIndexes := map[string]uint{}
+	for _, tx := range txBulk.Txs {
+		blockIndexes[tx.Hash] = tx.BlockIndex
+	}
+
+	pubKeys := map[string]*models.PubKey{}
+	for _, w := range txBulk.TxSignerWitnesses {
+		if w.Type != witness.Standard || w.Signature == models.SignatureInvalid {
+			continue
+		}
+
+		pubKey, err := hex.DecodeString(w.PubKeys[0])
+		if err != nil {
+			continue
+		}
+
+		// The witness must belong to the signer account.
+		address := util.GetAddressFromPublicKeyBytes(pubKey)
+		if util.GetAddrScriptHash(address) != strings.TrimPrefix(w.Account, "0x") {
+			continue
+		}
+
+		blockIndex := blockIndexes[w.TransactionHash]
+		if known, ok := pubKeys[address]; ok && known.BlockIndex <= blockIndex {
+			continue
+		}
+
+		pubKeys[address] = &models.PubKey{
+			Address:    address,
+			PubKey:     w.PubKeys[0],
+			BlockIndex: blockIndex,
+		}
+	}
+
+	result := []*models.PubKey{}
+	for _, pubKey := range pubKeys {
+		result = append(result, pubKey)
+	}
+
+	// Keep the insertion order stable.
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Address < result[j].Address
+	})
+
+	return result
+}
@@ -8,13 +8,17 @@ import (
 type Worker struct {
 	mu           sync.Mutex
 	goroutineCnt uint8
+	// target is the pool size set by the controller.
+	target uint8
 }
 
-func (manager *Worker) shouldQuit() bool {
+// quitAbove decrements the goroutine count and returns true
+// if more than limit goroutines are running.
+func (manager *Worker) quitAbove(limit uint8) bool {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
 
-	if manager.goroutineCnt > 1 {
+	if manager.goroutineCnt > limit {
 		manager.goroutineCnt--
 		return true
 	}
@@ -36,3 +40,34 @@ func (manager *Worker) add() uint8 {
 
 	return manager.goroutineCnt
 }
+
+func (manager *Worker) reset(target int) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	manager.goroutineCnt = 0
+	manager.target = uint8(target)
+}
+
+func (manager *Worker) remove() uint8 {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	manager.goroutineCnt--
+
+	return manager.goroutineCnt
+}
+
+func (manager *Worker) getTarget() uint8 {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	return manager.target
+}
+
+func (manager *Worker) setTarget(target int) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	manager.target = uint8(target)
+}
@@ -1,42 +1,93 @@
 package contract
 
 import (
+	"context"
+	"fmt"
 	"neo3-squirrel/db"
 	"neo3-squirrel/models"
 	"neo3-squirrel/rpc"
+	"neo3-squirrel/tasks/applog"
+	"neo3-squirrel/tasks/reorg"
+	"neo3-squirrel/tasks/supervisor"
 	"neo3-squirrel/tasks/util"
 	"neo3-squirrel/util/color"
 	"neo3-squirrel/util/log"
 	"time"
 )
 
-// StartContractTask starts contract related tasks.
-func StartContractTask() {
-	log.Info(color.Green("Contract state sync task started"))
-	lastContract := db.GetLastContract()
+// unit is the current run of the contract state sync task.
+var unit *supervisor.Unit
+
+// contracts reads and writes persisted contract states, replaced in tests.
+var contracts = struct {
+	get                func(hash string) *models.ContractState
+	getTransaction     func(txID string) *models.Transaction
+	insert             func(contract *models.ContractState, notiPK uint, contractHash string, newAsset *models.Asset)
+	update             func(contract *models.ContractState, notiPK uint, contractHash string)
+	delete             func(contractHash string, notiPK uint)
+	notificationsAbove func(blockIndex uint) []*models.Notification
+	notificationsTill  func(blockIndex uint) []*models.Notification
+}{
+	get:                db.GetContract,
+	getTransaction:     db.GetTransaction,
+	insert:             db.InsertContract,
+	update:             db.UpdateContract,
+	delete:             db.DeleteContract,
+	notificationsAbove: db.GetContractNotificationsAbove,
+	notificationsTill:  db.GetContractNotificationsTill,
+}
 
-	// Insert native contracts if zero contracts.
-	if lastContract == nil {
-		persistNativeContracts()
-	}
+// StartContractTask starts contract related tasks in the unit.
+// The current notification is persisted before the unit returns once ctx is cancelled.
+func StartContractTask(ctx context.Context, u *supervisor.Unit) {
+	unit = u
 
-	go handleContractNotification()
+	log.Info(color.Green("Contract state sync task started"))
+
+	unit.Go("persist", func() {
+		handleContractNotification(ctx)
+	})
 }
 
-func handleContractNotification() {
+func handleContractNotification(ctx context.Context) {
+	epoch := reorg.Epoch()
 	nextCSNotiPK := db.GetContractNotiPK() + 1
 
 	for {
+		if unit.IsAborted() {
+			return
+		}
+
+		if ctx.Err() != nil {
+			log.Info(color.Green("Contract state sync task stopped"))
+			return
+		}
+
+		// Restart from the contract task checkpoint
+		// if the chain has been rolled back.
+		if e := reorg.Epoch(); e != epoch {
+			epoch = e
+			nextCSNotiPK = db.GetContractNotiPK() + 1
+		}
+
+		// Check before querying so that no notification is missed.
+		finished := applog.Finished()
+
 		csNotis := db.GetContractNotifications(nextCSNotiPK, 100)
 		if len(csNotis) == 0 {
+			if finished {
+				log.Info(color.BGreen("Contract state sync reached end height"))
+				return
+			}
+
 			time.Sleep(1 * time.Second)
 			continue
 		}
 
 		for _, csNoti := range csNotis {
-			notiApplied := handleCsNoti(csNoti)
-			if !notiApplied {
-				db.UpdateContractNotiPK(csNoti.ID)
+			unit.Track("persist", fmt.Sprintf("notification %d(%s)", csNoti.ID, csNoti.Hash))
+			if ctx.Err() != nil || !applyCsNoti(epoch, csNoti) {
+				break
 			}
 		}
 
@@ -44,6 +95,23 @@ func handleContractNotification() {
 	}
 }
 
+// applyCsNoti handles the contract notification,
+// returns false if the chain has been rolled back.
+func applyCsNoti(epoch uint, csNoti *models.Notification) bool {
+	if !reorg.Hold(epoch) {
+		return false
+	}
+
+	defer reorg.Release()
+
+	notiApplied := handleCsNoti(csNoti)
+	if !notiApplied {
+		db.UpdateContractNotiPK(csNoti.ID)
+	}
+
+	return true
+}
+
 func handleCsNoti(csNoti *models.Notification) bool {
 	if util.VMStateFault(csNoti.VMState) {
 		return false
@@ -54,6 +122,12 @@ func handleCsNoti(csNoti *models.Notification) bool {
 		return false
 	}
 
+	if staleCsNoti(csNoti, contracts.get(contractHash)) {
+		log.Warnf("Skip %s notification of contract %s in block %d older than the persisted state",
+			csNoti.EventName, contractHash, csNoti.BlockIndex)
+		return false
+	}
+
 	// Attention:
 	// `rawContractState` can be nil if it was deleted already,
 	// or won't be nil if contract A destroyed and
@@ -61,7 +135,7 @@ func handleCsNoti(csNoti *models.Notification) bool {
 	rawContractState := rpc.GetContractState(csNoti.BlockIndex, contractHash)
 
 	// Get sender from transaction detail.
-	tx := db.GetTransaction(csNoti.Hash)
+	tx := contracts.getTransaction(csNoti.Hash)
 	if tx == nil {
 		log.Panicf("Failed to get transaction detail of txid=%s", csNoti.Hash)
 	}
@@ -90,6 +164,13 @@ func handleCsNoti(csNoti *models.Notification) bool {
 	return true
 }
 
+// staleCsNoti tells if the notification is older than the last deployment
+// or update of the persisted contract. Notifications backfilled from gaps
+// are persisted with higher ids, so they may arrive after newer ones.
+func staleCsNoti(csNoti *models.Notification, persisted *models.ContractState) bool {
+	return persisted != nil && csNoti.BlockIndex < persisted.BlockIndex
+}
+
 func insertContract(contractState *models.ContractState, csNoti *models.Notification, contractHash string) {
 	if contractState == nil {
 		return
@@ -104,7 +185,7 @@ func insertContract(contractState *models.ContractState, csNoti *models.Notifica
 		nep17 = util.QueryNEP17AssetInfo(csNoti, contractHash)
 	}
 
-	db.InsertContract(contractState, csNoti.ID, contractHash, nep17)
+	contracts.insert(contractState, csNoti.ID, contractHash, nep17)
 }
 
 func updateContract(contractState *models.ContractState, csNotiID uint, contractHash string) {
@@ -113,11 +194,11 @@ func updateContract(contractState *models.ContractState, csNotiID uint, contract
 	}
 
 	contractState.State = string(models.ContractUpdateEvent)
-	db.UpdateContract(contractState, csNotiID, contractHash)
+	contracts.update(contractState, csNotiID, contractHash)
 }
 
 func deleteContract(contractHash string, csNotiID uint) {
-	db.DeleteContract(contractHash, csNotiID)
+	contracts.delete(contractHash, csNotiID)
 }
 
 func supportNEP17(contractState *models.ContractState) bool {
@@ -0,0 +1,213 @@
+package contract
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"neo3-squirrel/models"
+	"neo3-squirrel/rpc"
+	"neo3-squirrel/tests/fullnode"
+	"neo3-squirrel/util/byteutil"
+	"strings"
+	"testing"
+)
+
+func TestSupportNEP17(t *testing.T) {
+	fullnode.Setup(t, rpc.UseFullnodes)
+
+	const gasHash = "0xd2a4cff31913016155e38e474a2c06d08be276cf"
+
+	rawCS := rpc.GetContractState(0, gasHash)
+	if rawCS == nil {
+		t.Fatalf("Failed to get contract state of %s", gasHash)
+	}
+
+	cs := models.ParseContractState(0, 0, "", "", rawCS)
+	if cs.Hash != gasHash || cs.Manifest.Name != "GasToken" {
+		t.Fatalf("Incorrect contract state: %s(%s)", cs.Hash, cs.Manifest.Name)
+	}
+
+	if !supportNEP17(cs) {
+		t.Fatal("GasToken must support NEP17")
+	}
+
+	// Contracts without any NEP17 method are not NEP17 assets.
+	methods := cs.Manifest.ABI.Methods
+	for i, method := range methods {
+		if method.Name == "transfer" {
+			cs.Manifest.ABI.Methods = append(methods[:i:i], methods[i+1:]...)
+			break
+		}
+	}
+
+	if supportNEP17(cs) {
+		t.Fatal("Contracts without 'transfer' must not support NEP17")
+	}
+}
+
+func TestParseNativeContracts(t *testing.T) {
+	fullnode.Setup(t, rpc.UseFullnodes)
+
+	rawStates := rpc.GetNativeContracts()
+	if len(rawStates) == 0 {
+		t.Fatal("Failed to get native contracts")
+	}
+
+	// The native contract activated by a hardfork at block 1 and updated at block 2.
+	rawStates[0].UpdateHistory = []uint{1, 2}
+	block := rpc.SyncBlock(1)
+
+	states := parseNativeContracts(rawStates)
+	if len(states) != len(rawStates) {
+		t.Fatalf("Incorrect native contracts count, expected %d, got %d", len(rawStates), len(states))
+	}
+
+	if cs := states[0]; cs.BlockIndex != 1 || cs.BlockTime != block.Time {
+		t.Fatalf("Native contract must be created at block 1, got block %d(%d)", cs.BlockIndex, cs.BlockTime)
+	}
+
+	for _, cs := range states[1:] {
+		if cs.BlockIndex != 0 {
+			t.Fatalf("Native contract %s must be created at genesis, got block %d", cs.Manifest.Name, cs.BlockIndex)
+		}
+	}
+
+	prev := *states[0]
+	prev.UpdateHistory = []uint{1}
+	if !nativeContractChanged(&prev, states[0]) {
+		t.Fatalf("Native contract with new update history must be updated")
+	}
+
+	if nativeContractChanged(states[0], states[0]) {
+		t.Fatalf("Unchanged native contract must not be updated")
+	}
+}
+
+func TestBackfilledCsNoti(t *testing.T) {
+	fullnode.Setup(t, rpc.UseFullnodes)
+
+	const hash = "0xd2a4cff31913016155e38e474a2c06d08be276cf"
+
+	// Contract states are kept in memory.
+	persisted := map[string]*models.ContractState{}
+	updated := []uint{}
+
+	origin := contracts
+	defer func() {
+		contracts = origin
+	}()
+
+	contracts.get = func(hash string) *models.ContractState {
+		return persisted[hash]
+	}
+	contracts.getTransaction = func(txID string) *models.Transaction {
+		return &models.Transaction{Hash: txID}
+	}
+	contracts.update = func(cs *models.ContractState, notiPK uint, contractHash string) {
+		updated = append(updated, cs.BlockIndex)
+		persisted[contractHash] = cs
+	}
+
+	persisted[hash] = &models.ContractState{Hash: hash, BlockIndex: 1}
+
+	hashBytes, _ := hex.DecodeString(strings.TrimPrefix(hash, "0x"))
+	csNoti := func(id, blockIndex uint) *models.Notification {
+		return &models.Notification{
+			ID:         id,
+			BlockIndex: blockIndex,
+			Hash:       fmt.Sprintf("0x%064x", id),
+			VMState:    "HALT",
+			EventName:  string(models.ContractUpdateEvent),
+			State: &models.State{
+				Type: "Array",
+				Value: []models.StackItem{{
+					Type:  "ByteString",
+					Value: base64.StdEncoding.EncodeToString(byteutil.ReverseBytes(hashBytes)),
+				}},
+			},
+		}
+	}
+
+	// The update at block 2 is applied, then the update at block 1
+	// backfilled from a gap arrives with a higher id.
+	if !handleCsNoti(csNoti(10, 2)) {
+		t.Fatalf("The newer update must be applied")
+	}
+
+	if handleCsNoti(csNoti(11, 1)) {
+		t.Fatalf("The backfilled older update must be skipped")
+	}
+
+	if len(updated) != 1 || updated[0] != 2 || persisted[hash].BlockIndex != 2 {
+		t.Fatalf("Contract must keep the state of block 2, updated at %v", updated)
+	}
+}
+
+func TestRollbackStates(t *testing.T) {
+	fullnode.Setup(t, rpc.UseFullnodes)
+
+	const (
+		updatedHash  = "0xd2a4cff31913016155e38e474a2c06d08be276cf"
+		deployedHash = "0xef4073a0f2b305a38ec4050e4d3d28bc40ea63f5"
+	)
+
+	csNoti := func(id, blockIndex uint, event models.EventName, hash string) *models.Notification {
+		hashBytes, _ := hex.DecodeString(strings.TrimPrefix(hash, "0x"))
+		return &models.Notification{
+			ID:         id,
+			BlockIndex: blockIndex,
+			Hash:       fmt.Sprintf("0x%064x", id),
+			VMState:    "HALT",
+			EventName:  string(event),
+			State: &models.State{
+				Type: "Array",
+				Value: []models.StackItem{{
+					Type:  "ByteString",
+					Value: base64.StdEncoding.EncodeToString(byteutil.ReverseBytes(hashBytes)),
+				}},
+			},
+		}
+	}
+
+	origin := contracts
+	defer func() {
+		contracts = origin
+	}()
+
+	// Deployed at block 1 and updated at block 2, then
+	// updated again at block 3 and orphaned by the rollback to block 2.
+	contracts.notificationsAbove = func(uint) []*models.Notification {
+		return []*models.Notification{
+			csNoti(3, 3, models.ContractUpdateEvent, updatedHash),
+			csNoti(4, 3, models.ContractDeployEvent, deployedHash),
+		}
+	}
+	contracts.notificationsTill = func(uint) []*models.Notification {
+		return []*models.Notification{
+			csNoti(2, 2, models.ContractUpdateEvent, updatedHash),
+			csNoti(1, 1, models.ContractDeployEvent, updatedHash),
+		}
+	}
+	contracts.getTransaction = func(txID string) *models.Transaction {
+		return &models.Transaction{Hash: txID, Sender: "sender" + txID}
+	}
+
+	deployed, reverted := RollbackStates(2)
+	if len(deployed) != 1 || deployed[0] != deployedHash {
+		t.Fatalf("Contracts deployed above the height must be deleted, got %v", deployed)
+	}
+
+	if len(reverted) != 1 {
+		t.Fatalf("Expected 1 reverted contract, got %d", len(reverted))
+	}
+
+	cs := reverted[0]
+	if cs.Hash != updatedHash || cs.BlockIndex != 2 ||
+		cs.State != string(models.ContractUpdateEvent) || cs.TxID != fmt.Sprintf("0x%064x", 2) {
+		t.Fatalf("Contract must be reverted to the update at block 2, got %+v", cs)
+	}
+
+	if cs.Creator != fmt.Sprintf("sender0x%064x", 1) {
+		t.Fatalf("Creator must be the sender of the deployment, got %s", cs.Creator)
+	}
+}
@@ -1,37 +1,83 @@
 package contract
 
 import (
+	"neo3-squirrel/cache/native"
 	"neo3-squirrel/db"
 	"neo3-squirrel/models"
 	"neo3-squirrel/rpc"
 	"neo3-squirrel/util/log"
+	"reflect"
 )
 
-func persistNativeContracts() {
-	nativeContractHashes := models.NativeContractHashes()
+// SyncNativeContracts discovers native contracts from fullnode,
+// persists the new ones, updates the changed ones and registers all of them.
+func SyncNativeContracts() {
+	persisted := map[string]*models.ContractState{}
+	for _, cs := range db.GetAllNativeContracts() {
+		persisted[cs.Hash] = cs
+		native.Register(cs.Manifest.Name, cs.Hash)
+	}
+
+	nativeContracts := rpc.GetNativeContracts()
+	if len(nativeContracts) == 0 {
+		log.Panicf("Failed to get native contracts from Fullnode RPC")
+	}
+
+	for _, cs := range parseNativeContracts(nativeContracts) {
+		prev, ok := persisted[cs.Hash]
+		switch {
+		case !ok:
+			db.InsertNativeContract(cs)
+			showContractDBState(cs.BlockIndex, cs.BlockTime, cs.Hash, string(models.ContractDeployEvent), cs)
+		case nativeContractChanged(prev, cs):
+			db.UpdateNativeContract(cs)
+			showContractDBState(cs.BlockIndex, cs.BlockTime, cs.Hash, string(models.ContractUpdateEvent), cs)
+		}
+
+		native.Register(cs.Manifest.Name, cs.Hash)
+	}
 
-	genesisBlock := rpc.SyncBlock(0)
-	if genesisBlock == nil {
-		log.Panicf("Failed to get genesis block from Fullnode RPC")
+	for _, name := range []string{native.ContractManagement, native.NeoToken, native.GasToken} {
+		if _, ok := native.Hash(name); !ok {
+			log.Panicf("Native contract %s not found from Fullnode RPC", name)
+		}
 	}
+}
 
-	blockIndex := genesisBlock.Index
-	blockTime := genesisBlock.Time
+// parseNativeContracts parses native contract states, each of them
+// is created at the first block of its update history.
+func parseNativeContracts(nativeContracts []*rpc.NativeContractState) []*models.ContractState {
+	blocks := map[uint]*rpc.Block{}
+	states := []*models.ContractState{}
 
-	for _, contractHash := range nativeContractHashes {
-		RawContractState := rpc.GetContractState(0, contractHash)
-		if RawContractState == nil {
-			log.Panicf("Failed to get contract state of hash %s", contractHash)
+	for _, rawCS := range nativeContracts {
+		// Contracts without update history are active since the genesis block.
+		blockIndex := uint(0)
+		if len(rawCS.UpdateHistory) > 0 {
+			blockIndex = rawCS.UpdateHistory[0]
 		}
 
-		cs := models.ParseContractState(blockIndex, blockTime, "", "", RawContractState)
-		db.InsertNativeContract(cs)
+		block, ok := blocks[blockIndex]
+		if !ok {
+			block = rpc.SyncBlock(blockIndex)
+			if block == nil {
+				log.Panicf("Failed to get block %d from Fullnode RPC", blockIndex)
+			}
+
+			blocks[blockIndex] = block
+		}
 
-		showContractDBState(
-			blockIndex,
-			blockTime,
-			contractHash,
-			string(models.ContractDeployEvent), cs,
-		)
+		states = append(states, models.ParseNativeContractState(block.Index, block.Time, rawCS))
 	}
+
+	return states
+}
+
+// nativeContractChanged tells if the persisted native contract
+// was updated by hardforks since persisted.
+func nativeContractChanged(prev, cs *models.ContractState) bool {
+	return prev.BlockIndex != cs.BlockIndex ||
+		prev.UpdateCounter != cs.UpdateCounter ||
+		prev.NEF.CheckSum != cs.NEF.CheckSum ||
+		!reflect.DeepEqual(prev.UpdateHistory, cs.UpdateHistory)
 }
@@ -0,0 +1,109 @@
+package contract
+
+import (
+	"neo3-squirrel/models"
+	"neo3-squirrel/rpc"
+	"neo3-squirrel/tasks/util"
+	"neo3-squirrel/util/log"
+)
+
+// RollbackStates returns hashes of contracts deployed above the given
+// height, and states as of the height of contracts deployed at or
+// below it but updated or destroyed above it.
+func RollbackStates(height uint) ([]string, []*models.ContractState) {
+	deployed := []string{}
+	changed := map[string]bool{}
+
+	for _, csNoti := range contracts.notificationsAbove(height) {
+		contractHash, ok := csNotiContractHash(csNoti)
+		if !ok {
+			continue
+		}
+
+		switch models.EventName(csNoti.EventName) {
+		case models.ContractDeployEvent:
+			deployed = append(deployed, contractHash)
+		case models.ContractUpdateEvent, models.ContractDestroyEvent:
+			changed[contractHash] = true
+		}
+	}
+
+	if len(changed) == 0 {
+		return deployed, nil
+	}
+
+	// The last notification and the deployment of
+	// changed contracts at or below the height.
+	lastNotis := map[string]*models.Notification{}
+	deployNotis := map[string]*models.Notification{}
+
+	for _, csNoti := range contracts.notificationsTill(height) {
+		contractHash, ok := csNotiContractHash(csNoti)
+		if !ok || !changed[contractHash] {
+			continue
+		}
+
+		if _, ok := lastNotis[contractHash]; !ok {
+			lastNotis[contractHash] = csNoti
+		}
+
+		if _, ok := deployNotis[contractHash]; !ok &&
+			models.EventName(csNoti.EventName) == models.ContractDeployEvent {
+			deployNotis[contractHash] = csNoti
+		}
+	}
+
+	reverted := []*models.ContractState{}
+	for contractHash, csNoti := range lastNotis {
+		// Contracts destroyed at or below the height are not restored.
+		if models.EventName(csNoti.EventName) == models.ContractDestroyEvent {
+			continue
+		}
+
+		if cs := revertedState(contractHash, csNoti, deployNotis[contractHash]); cs != nil {
+			reverted = append(reverted, cs)
+		}
+	}
+
+	return deployed, reverted
+}
+
+// revertedState returns the contract state of its last deployment or update
+// at or below the rollback height. Fullnodes are on the new chain already, so
+// the contract content is the latest one, which is replaced again when newer
+// updates of the new chain are handled.
+func revertedState(contractHash string, csNoti, deployNoti *models.Notification) *models.ContractState {
+	rawContractState := rpc.GetContractState(csNoti.BlockIndex, contractHash)
+	if rawContractState == nil {
+		log.Warnf("Failed to revert contract %s to block %d: contract not found", contractHash, csNoti.BlockIndex)
+		return nil
+	}
+
+	creator := ""
+	if deployNoti != nil {
+		if tx := contracts.getTransaction(deployNoti.Hash); tx != nil {
+			creator = tx.Sender
+		}
+	} else if persisted := contracts.get(contractHash); persisted != nil {
+		creator = persisted.Creator
+	}
+
+	contractState := models.ParseContractState(
+		csNoti.BlockIndex,
+		csNoti.BlockTime,
+		creator,
+		csNoti.Hash,
+		rawContractState,
+	)
+	contractState.State = csNoti.EventName
+
+	return contractState
+}
+
+func csNotiContractHash(csNoti *models.Notification) (string, bool) {
+	if util.VMStateFault(csNoti.VMState) {
+		return "", false
+	}
+
+	return util.GetContractHash(csNoti)
+}
@@ -0,0 +1,219 @@
+package gap
+
+import (
+	"context"
+	"fmt"
+	"neo3-squirrel/config"
+	"neo3-squirrel/db"
+	"neo3-squirrel/tasks/applog"
+	"neo3-squirrel/tasks/block"
+	"neo3-squirrel/tasks/reorg"
+	"neo3-squirrel/tasks/supervisor"
+	"neo3-squirrel/util/color"
+	"neo3-squirrel/util/log"
+	"sort"
+)
+
+// scanStep is the number of blocks scanned by each query.
+const scanStep = 10000
+
+// Report lists missing block ranges of each table.
+type Report struct {
+	Blocks        []Range
+	Txs           []Range
+	Notifications []Range
+}
+
+// Scan scans persisted blocks, transactions and notifications for gaps.
+// Notification gaps are confirmed by querying application logs of
+// blocks and transactions which have no notification persisted.
+func Scan(ctx context.Context) *Report {
+	report := &Report{}
+
+	if from, to, ok := blockRange(); ok {
+		report.Blocks, report.Txs = scanBlocks(ctx, nil, from, to)
+	}
+
+	if from, to, ok := notiRange(); ok {
+		report.Notifications = scanNotifications(ctx, nil, from, to, false)
+	}
+
+	return report
+}
+
+// Print shows the report.
+func (report *Report) Print() {
+	tables := []struct {
+		name   string
+		ranges []Range
+	}{
+		{"block", report.Blocks},
+		{"transaction", report.Txs},
+		{"notification", report.Notifications},
+	}
+
+	for _, table := range tables {
+		if len(table.ranges) == 0 {
+			log.Info(color.Greenf("No gap found in table `%s`", table.name))
+			continue
+		}
+
+		blocks := uint(0)
+		for _, r := range table.ranges {
+			blocks += r.Len()
+		}
+
+		log.Warn(color.BYellowf("Found %d gaps of %d blocks in table `%s`:", len(table.ranges), blocks, table.name))
+		for _, r := range table.ranges {
+			log.Warnf("* %s", r)
+		}
+	}
+}
+
+// StartBackfillTask fills missing blocks, transactions and notifications
+// in the unit, alongside the live sync tasks. Gaps are only looked for
+// below the sync progress, so the live sync is not disturbed.
+func StartBackfillTask(ctx context.Context, u *supervisor.Unit) {
+	u.Go("scan", func() {
+		// Gaps are scanned again once the chain was rolled back.
+		for !backfill(ctx, u) {
+			log.Info(color.BYellow("Chain rolled back, scan gaps again"))
+		}
+	})
+}
+
+// backfill returns false if stopped since the chain was rolled back.
+func backfill(ctx context.Context, u *supervisor.Unit) bool {
+	epoch := reorg.Epoch()
+
+	if from, to, ok := blockRange(); ok {
+		blocks, txs := scanBlocks(ctx, u, from, to)
+
+		for _, r := range blocks {
+			log.Info(color.BYellowf("Backfill missing blocks %s", r))
+			if !block.Backfill(ctx, u, r.Start, r.End, false) {
+				return false
+			}
+		}
+
+		for _, r := range txs {
+			log.Info(color.BYellowf("Backfill missing transactions of blocks %s", r))
+			if !block.Backfill(ctx, u, r.Start, r.End, true) {
+				return false
+			}
+		}
+	}
+
+	// Notifications are scanned after blocks and transactions filled.
+	if from, to, ok := notiRange(); ok {
+		scanNotifications(ctx, u, from, to, true)
+	}
+
+	if reorg.Epoch() != epoch {
+		return false
+	}
+
+	if ctx.Err() == nil && !u.IsAborted() {
+		log.Info(color.BGreen("Backfill finished"))
+	}
+
+	return true
+}
+
+// blockRange returns the range of persisted blocks.
+func blockRange() (uint, uint, bool) {
+	from := config.GetStartHeight()
+	to := db.GetLastBlockHeight()
+
+	return from, uint(to), to >= int(from)
+}
+
+// notiRange returns the range of blocks whose notifications persisted,
+// excluding the last one which may be still in progress.
+func notiRange() (uint, uint, bool) {
+	from := config.GetStartHeight()
+
+	lastNoti := db.GetLastNotification()
+	if lastNoti == nil || lastNoti.BlockIndex <= from {
+		return 0, 0, false
+	}
+
+	return from, lastNoti.BlockIndex - 1, true
+}
+
+func scanBlocks(ctx context.Context, u *supervisor.Unit, from, to uint) ([]Range, []Range) {
+	blocks := []Range{}
+	txs := []Range{}
+
+	for start := from; start <= to; start += scanStep {
+		if stopped(ctx, u) {
+			break
+		}
+
+		end := start + scanStep - 1
+		if end > to {
+			end = to
+		}
+
+		if u != nil {
+			u.Track("scan", fmt.Sprintf("blocks %d-%d", start, end))
+		}
+
+		blocks = appendMissing(blocks, db.GetBlockIndexes(start, end), start, end)
+		txs = appendIndexes(txs, db.GetIncompleteTxBlocks(start, end))
+	}
+
+	return blocks, txs
+}
+
+// scanNotifications finds blocks with notifications missing,
+// and persists these notifications if required.
+func scanNotifications(ctx context.Context, u *supervisor.Unit, from, to uint, persist bool) []Range {
+	ranges := []Range{}
+	epoch := reorg.Epoch()
+	cancelled := func() bool {
+		return stopped(ctx, u) || (persist && reorg.Epoch() != epoch)
+	}
+
+	for start := from; start <= to; start += scanStep {
+		if cancelled() {
+			break
+		}
+
+		end := start + scanStep - 1
+		if end > to {
+			end = to
+		}
+
+		if u != nil {
+			u.Track("scan", fmt.Sprintf("notifications of blocks %d-%d", start, end))
+		}
+
+		sources := db.GetAppLogSourcesWithoutNotis(start, end)
+		missing := applog.FindMissingNotifications(sources, cancelled)
+
+		indexes := []uint{}
+		for _, notis := range missing {
+			if persist && !applog.BackfillNotifications(epoch, notis) {
+				return ranges
+			}
+
+			indexes = append(indexes, notis[0].BlockIndex)
+		}
+
+		if persist && len(missing) > 0 {
+			log.Infof("Backfilled notifications of %d blocks and transactions within %d-%d", len(missing), start, end)
+		}
+
+		sort.Slice(indexes, func(i, j int) bool {
+			return indexes[i] < indexes[j]
+		})
+		ranges = appendIndexes(ranges, indexes)
+	}
+
+	return ranges
+}
+
+func stopped(ctx context.Context, u *supervisor.Unit) bool {
+	return ctx.Err() != nil || (u != nil && u.IsAborted())
+}
@@ -0,0 +1,65 @@
+package gap
+
+import "fmt"
+
+// Range is an inclusive range of block indexes.
+type Range struct {
+	Start uint
+	End   uint
+}
+
+func (r Range) String() string {
+	if r.Start == r.End {
+		return fmt.Sprintf("%d", r.Start)
+	}
+
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// Len returns the number of blocks in the range.
+func (r Range) Len() uint {
+	return r.End - r.Start + 1
+}
+
+// appendRange appends r to ranges, merging it into the last
+// range if they overlap or are continuous. Ranges must be
+// appended in ascending order of their starts.
+func appendRange(ranges []Range, r Range) []Range {
+	if n := len(ranges); n > 0 && r.Start <= ranges[n-1].End+1 {
+		if r.End > ranges[n-1].End {
+			ranges[n-1].End = r.End
+		}
+
+		return ranges
+	}
+
+	return append(ranges, r)
+}
+
+// appendMissing appends ranges within [start, end]
+// absent from the ascending indexes.
+func appendMissing(ranges []Range, indexes []uint, start, end uint) []Range {
+	next := start
+	for _, index := range indexes {
+		if index > next {
+			ranges = appendRange(ranges, Range{Start: next, End: index - 1})
+		}
+
+		next = index + 1
+	}
+
+	if next <= end {
+		ranges = appendRange(ranges, Range{Start: next, End: end})
+	}
+
+	return ranges
+}
+
+// appendIndexes appends the ascending indexes as continuous ranges.
+func appendIndexes(ranges []Range, indexes []uint) []Range {
+	for _, index := range indexes {
+		ranges = appendRange(ranges, Range{Start: index, End: index})
+	}
+
+	return ranges
+}
@@ -0,0 +1,37 @@
+package gap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAppendMissing(t *testing.T) {
+	ranges := appendMissing(nil, []uint{2, 3, 7}, 0, 9)
+	ranges = appendMissing(ranges, []uint{12}, 10, 19)
+
+	expected := []Range{{0, 1}, {4, 6}, {8, 11}, {13, 19}}
+	if !reflect.DeepEqual(ranges, expected) {
+		t.Fatalf("Incorrect missing ranges, expected %v, got %v", expected, ranges)
+	}
+
+	if ranges := appendMissing(nil, []uint{5, 6}, 5, 6); len(ranges) != 0 {
+		t.Fatalf("No range should be missing, got %v", ranges)
+	}
+}
+
+func TestAppendIndexes(t *testing.T) {
+	ranges := appendIndexes(nil, []uint{1, 2, 2, 3, 5, 8, 9})
+
+	expected := []Range{{1, 3}, {5, 5}, {8, 9}}
+	if !reflect.DeepEqual(ranges, expected) {
+		t.Fatalf("Incorrect index ranges, expected %v, got %v", expected, ranges)
+	}
+
+	if s := ranges[0].String(); s != "1-3" {
+		t.Fatalf("Incorrect range string %s", s)
+	}
+
+	if s := ranges[1].String(); s != "5" {
+		t.Fatalf("Incorrect range string %s", s)
+	}
+}
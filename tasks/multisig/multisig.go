@@ -0,0 +1,130 @@
+package multisig
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"neo3-squirrel/db"
+	"neo3-squirrel/models"
+	"neo3-squirrel/tasks/util"
+	"neo3-squirrel/util/color"
+	"neo3-squirrel/util/hashutil"
+	"neo3-squirrel/util/log"
+	"neo3-squirrel/util/witness"
+	"sync"
+)
+
+// rebuildStep is the number of witnesses read by each query.
+const rebuildStep = 10000
+
+// registered caches addresses of registered accounts.
+// map[address(string)]bool
+var registered sync.Map
+
+// Collect returns multi-signature accounts of witnesses in the blocks
+// not registered yet, to be inserted together with the blocks.
+func Collect(blocks []*models.Block, txBulk *models.TxBulk) []*models.MultiSigAccount {
+	verifications := []string{}
+	for _, b := range blocks {
+		for _, w := range b.Witnesses {
+			verifications = append(verifications, w.Verification)
+		}
+	}
+
+	for _, w := range txBulk.TxWitnesses {
+		verifications = append(verifications, w.Verification)
+	}
+
+	return Parse(verifications)
+}
+
+// MarkRegistered caches the accounts once they are persisted.
+func MarkRegistered(accounts []*models.MultiSigAccount) {
+	for _, account := range accounts {
+		registered.Store(account.Address, true)
+	}
+}
+
+// Parse returns multi-signature accounts of the base64 encoded
+// verification scripts not registered yet.
+func Parse(verifications []string) []*models.MultiSigAccount {
+	accounts := []*models.MultiSigAccount{}
+	parsed := map[string]bool{}
+
+	for _, verification := range verifications {
+		script, err := base64.StdEncoding.DecodeString(verification)
+		if err != nil {
+			continue
+		}
+
+		m, pubKeys, ok := witness.ParseMultiSig(script)
+		if !ok {
+			continue
+		}
+
+		address := util.GetAddressFromScript(script)
+		if _, ok := registered.Load(address); ok || parsed[address] {
+			continue
+		}
+
+		parsed[address] = true
+		account := models.MultiSigAccount{
+			Address:    address,
+			ScriptHash: "0x" + hashutil.GetAssetIDFromScriptHash(hashutil.Hash160(script)),
+			Threshold:  m,
+		}
+
+		for _, pubKey := range pubKeys {
+			account.Members = append(account.Members, models.MultiSigMember{
+				PubKey:  hex.EncodeToString(pubKey),
+				Address: util.GetAddressFromPublicKeyBytes(pubKey),
+			})
+		}
+
+		accounts = append(accounts, &account)
+	}
+
+	return accounts
+}
+
+// Rebuild registers multi-signature accounts of all
+// persisted block and transaction witnesses.
+func Rebuild() {
+	for _, table := range []string{"block_witness", "transaction_witness"} {
+		lastID := uint(0)
+		total := 0
+
+		for {
+			verifications, id := db.GetWitnessVerifications(table, lastID, rebuildStep)
+			if len(verifications) == 0 {
+				break
+			}
+
+			accounts := Parse(verifications)
+			if err := db.InsertMultiSigAccounts(accounts); err != nil {
+				log.Panic(err)
+			}
+
+			MarkRegistered(accounts)
+
+			lastID = id
+			total += len(accounts)
+		}
+
+		log.Info(color.Greenf("Registered %d multi-signature accounts from table `%s`", total, table))
+	}
+}
+
+// Show prints the registered multi-signature account of the address.
+func Show(address string) {
+	account := db.GetMultiSigAccount(address)
+	if account == nil {
+		log.Warn(color.BYellowf("%s is not a registered multi-signature account", address))
+		return
+	}
+
+	log.Info(color.Greenf("%s (%s) is a %d-of-%d multi-signature account:",
+		account.Address, account.ScriptHash, account.Threshold, len(account.Members)))
+	for _, member := range account.Members {
+		log.Infof("* %s %s", member.Address, member.PubKey)
+	}
+}
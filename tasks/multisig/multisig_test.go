@@ -0,0 +1,38 @@
+package multisig
+
+import (
+	"encoding/base64"
+	"neo3-squirrel/tasks/util"
+	"neo3-squirrel/util/witness"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	// 1-of-1 multi-signature verification script.
+	multi := "EQwhA/AK3QbWbp5uvhZkldXoEPBDx5BfAre6To5ZwNYK3z5nEUGe0Nw6"
+	script, _ := base64.StdEncoding.DecodeString(multi)
+	_, pubKeys, _ := witness.ParseMultiSig(script)
+	single := base64.StdEncoding.EncodeToString(witness.StandardScript(pubKeys[0]))
+
+	accounts := Parse([]string{multi, single, multi, ""})
+	if len(accounts) != 1 {
+		t.Fatalf("Only distinct multi-signature accounts must be parsed, got %d", len(accounts))
+	}
+
+	account := accounts[0]
+	if account.Address != "NaSngawbyN5TQguLDuiQbo9gFFMUe2LNzw" || account.Threshold != 1 {
+		t.Fatalf("Incorrect multi-signature account: %+v", account)
+	}
+
+	if len(account.Members) != 1 ||
+		account.Members[0].Address != util.GetAddressFromPublicKeyBytes(pubKeys[0]) {
+		t.Fatalf("Incorrect multi-signature members: %+v", account.Members)
+	}
+
+	registered.Store(account.Address, true)
+	defer registered.Delete(account.Address)
+
+	if accounts := Parse([]string{multi}); len(accounts) != 0 {
+		t.Fatal("Registered accounts must not be parsed again")
+	}
+}
@@ -0,0 +1,149 @@
+package nep17
+
+import (
+	"neo3-squirrel/cache/asset"
+	"neo3-squirrel/db"
+	"neo3-squirrel/models"
+	"neo3-squirrel/rpc"
+	"neo3-squirrel/tasks/util"
+	"neo3-squirrel/util/color"
+	"neo3-squirrel/util/log"
+	"sync"
+)
+
+// Without historic invocations, balances of past blocks cannot be
+// queried, so balances changed during catch-up are deferred and
+// queried from the latest state once the sync reaches the best block.
+var (
+	deferredMu sync.Mutex
+	// map[address+contract]*models.AddrAsset, holding transfers counted meanwhile.
+	deferredBalances = map[string]*models.AddrAsset{}
+	deferWarning     sync.Once
+)
+
+// atBestBlock tells if the latest state is the state right after the block.
+func atBestBlock(blockIndex uint) bool {
+	return int(blockIndex) >= rpc.GetBestHeight()
+}
+
+// queryBalances queries balances right after the given block. Without
+// historic invocations, balances are taken from the latest state at the best
+// block, or deferred otherwise. Returns false if not queried.
+func queryBalances(blockIndex uint, addrs []string, contract string, decimals uint, transfers int) ([]*models.AddrAsset, bool) {
+	balances, ok := util.QueryNEP17Balances(blockIndex, addrs, contract, decimals)
+	if !ok && !rpc.HistoricInvocationAvailable() {
+		if !atBestBlock(blockIndex) {
+			deferBalances(addrs, contract, transfers)
+			return nil, false
+		}
+
+		balances, ok = util.QueryLatestNEP17Balances(blockIndex, addrs, contract, decimals)
+	}
+
+	if !ok {
+		return nil, false
+	}
+
+	addrAssets := make([]*models.AddrAsset, len(addrs))
+	for i, addr := range addrs {
+		addrAssets[i] = &models.AddrAsset{
+			Address:   addr,
+			Contract:  contract,
+			Balance:   balances[i],
+			Transfers: transfers,
+		}
+	}
+
+	return addrAssets, true
+}
+
+func deferBalances(addrs []string, contract string, transfers int) {
+	deferWarning.Do(func() {
+		log.Warn(color.BYellow("No fullnode supports historic invocations, balances are updated once the sync reaches the best block."))
+	})
+
+	deferredMu.Lock()
+	defer deferredMu.Unlock()
+
+	for _, addr := range addrs {
+		key := addr + contract
+		addrAsset, ok := deferredBalances[key]
+		if !ok {
+			addrAsset = &models.AddrAsset{Address: addr, Contract: contract}
+			deferredBalances[key] = addrAsset
+		}
+
+		addrAsset.Transfers += transfers
+	}
+}
+
+// persistDeferredBalances queries deferred balances from the latest state
+// and persists them once the sync reaches the best block.
+func persistDeferredBalances(blockIndex uint) {
+	if !atBestBlock(blockIndex) {
+		return
+	}
+
+	deferredMu.Lock()
+	defer deferredMu.Unlock()
+
+	if len(deferredBalances) == 0 {
+		return
+	}
+
+	addrsByContract := map[string][]string{}
+	for _, addrAsset := range deferredBalances {
+		addrsByContract[addrAsset.Contract] = append(addrsByContract[addrAsset.Contract], addrAsset.Address)
+	}
+
+	addrAssets := []*models.AddrAsset{}
+	for contract, addrs := range addrsByContract {
+		decimals, ok := asset.GetDecimals(contract)
+		if !ok {
+			continue
+		}
+
+		balances, ok := util.QueryLatestNEP17Balances(blockIndex, addrs, contract, decimals)
+		if !ok {
+			continue
+		}
+
+		for i, addr := range addrs {
+			addrAsset := deferredBalances[addr+contract]
+			addrAsset.Balance = balances[i]
+			bootstrapOpeningBalance(addrAsset, decimals)
+
+			addrAssets = append(addrAssets, addrAsset)
+			delete(deferredBalances, addr+contract)
+		}
+	}
+
+	if len(addrAssets) > 0 {
+		db.PersistNEP17Balances(addrAssets)
+		log.Infof("Persisted %d balances deferred during catch-up", len(addrAssets))
+	}
+}
+
+// ResyncBalances queries balances changed above the rolled back height
+// again, as fee burns and rewards are not reverted from transfers.
+// map[contract][]address
+func ResyncBalances(height uint, addrsByContract map[string][]string) {
+	addrAssets := []*models.AddrAsset{}
+	for contract, addrs := range addrsByContract {
+		decimals, ok := asset.GetDecimals(contract)
+		if !ok {
+			continue
+		}
+
+		queried, ok := queryBalances(height, addrs, contract, decimals, 0)
+		if !ok {
+			continue
+		}
+
+		addrAssets = append(addrAssets, queried...)
+	}
+
+	if len(addrAssets) > 0 {
+		db.PersistNEP17Balances(addrAssets)
+	}
+}
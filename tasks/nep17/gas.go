@@ -3,6 +3,7 @@ package nep17
 import (
 	"math/big"
 	"neo3-squirrel/cache/gas"
+	"neo3-squirrel/cache/native"
 	"neo3-squirrel/models"
 	"neo3-squirrel/rpc"
 	"neo3-squirrel/tasks/util"
@@ -19,12 +20,32 @@ func updateGASTotalSupply(transfers []*models.Transfer) *big.Float {
 		}
 	}
 
+	if !hasGASClaimTransfer {
+		return nil
+	}
+
+	blockIndex := transfers[0].BlockIndex
+	if gas.CachedTillBlockIndex() >= blockIndex {
+		return nil
+	}
+
+	gasTotalSupply, ok := util.QueryAssetTotalSupply(blockIndex, native.GAS(), 8)
+	if ok {
+		gas.CacheGASTotalSupply(blockIndex, gasTotalSupply)
+		return gasTotalSupply
+	}
+
+	if rpc.HistoricInvocationAvailable() {
+		return nil
+	}
+
+	// Without historic invocations, only the supply of the best block is taken.
 	bestBlock := rpc.GetBestHeight()
-	if !hasGASClaimTransfer || int(gas.CachedTillBlockIndex()) >= bestBlock || bestBlock < 0 {
+	if int(gas.CachedTillBlockIndex()) >= bestBlock || bestBlock < 0 {
 		return nil
 	}
 
-	gasTotalSupply, ok := util.QueryAssetTotalSupply(uint(bestBlock), models.GasToken, 8)
+	gasTotalSupply, ok = util.QueryLatestAssetTotalSupply(uint(bestBlock), native.GAS(), 8)
 	if !ok {
 		return nil
 	}
@@ -1,11 +1,15 @@
 package nep17
 
 import (
+	"context"
 	"fmt"
 	assetCache "neo3-squirrel/cache/asset"
 	"neo3-squirrel/config"
 	"neo3-squirrel/db"
 	"neo3-squirrel/models"
+	"neo3-squirrel/tasks/applog"
+	"neo3-squirrel/tasks/reorg"
+	"neo3-squirrel/tasks/supervisor"
 	"neo3-squirrel/tasks/util"
 	"neo3-squirrel/util/color"
 	"neo3-squirrel/util/convert"
@@ -20,6 +24,10 @@ var (
 
 	// LastTxBlockIndex is the block index of the last transfer.
 	LastTxBlockIndex uint
+	lastTxEpoch      uint
+
+	// unit is the current run of the NEP17 transfer sync task.
+	unit *supervisor.Unit
 )
 
 type notiTransfer struct {
@@ -27,10 +35,13 @@ type notiTransfer struct {
 	Hash       string
 	BlockIndex uint
 	transfers  []*models.Transfer
+	epoch      uint
 }
 
-// StartNEP17TransferSyncTask starts NEP17 transfer related tasks.
-func StartNEP17TransferSyncTask() {
+// StartNEP17TransferSyncTask starts NEP17 transfer related tasks in the unit.
+// Parsed transfers are persisted before the unit returns once ctx is cancelled.
+func StartNEP17TransferSyncTask(ctx context.Context, u *supervisor.Unit) {
+	unit = u
 	lastTransferNoti := db.GetLastNotiForNEP17Task()
 	upToBlockHeight := uint(0)
 	upToBlockTime := ""
@@ -61,14 +72,44 @@ func StartNEP17TransferSyncTask() {
 	// Starts tasks.
 	transferChan := make(chan *notiTransfer, chanSize)
 
-	go fetchNotifications(lastNotiPK+1, transferChan)
-	go persistNEP17Transfers(transferChan)
+	unit.Go("fetch", func() {
+		fetchNotifications(ctx, lastNotiPK+1, transferChan)
+	})
+	unit.Go("persist", func() {
+		persistNEP17Transfers(transferChan)
+	})
 }
 
-func fetchNotifications(nextNotiPK uint, transferChan chan<- *notiTransfer) {
+func fetchNotifications(ctx context.Context, nextNotiPK uint, transferChan chan<- *notiTransfer) {
+	defer close(transferChan)
+
+	epoch := reorg.Epoch()
+
 	for {
+		if ctx.Err() != nil || unit.IsAborted() {
+			return
+		}
+
+		// Restart from the last persisted transfer
+		// if the chain has been rolled back.
+		if e := reorg.Epoch(); e != epoch {
+			epoch = e
+			nextNotiPK = 1
+			if lastTransferNoti := db.GetLastNotiForNEP17Task(); lastTransferNoti != nil {
+				nextNotiPK = lastTransferNoti.ID + 1
+			}
+		}
+
+		// Check before querying so that no notification is missed.
+		finished := applog.Finished()
+
 		notis := db.GetNotificationsGroupedByHash(nextNotiPK, 200)
 		if len(notis) == 0 {
+			if finished {
+				log.Info(color.BGreen("NEP17 transfer sync reached end height"))
+				return
+			}
+
 			time.Sleep(1 * time.Second)
 			continue
 		}
@@ -78,43 +119,66 @@ func fetchNotifications(nextNotiPK uint, transferChan chan<- *notiTransfer) {
 
 		// Every notiArray has the same hash.
 		for _, notis := range notiArrays {
-			// hash and blockIndex are the same across these grouped notis,
-			// so get them from the first noti.
-			hash := notis[0].Hash
-			blockIndex := notis[0].BlockIndex
-
-			transferInfo := notiTransfer{
-				Hash:       hash,
-				BlockIndex: blockIndex,
+			unit.Track("fetch", notis[0].Hash)
+			transferInfo, ok := parseNotifications(epoch, notis)
+			if !ok {
+				break
 			}
 
-			for _, noti := range notis {
-				eventName := noti.EventName
-
-				switch strings.ToLower(eventName) {
-				case "transfer":
-					log.Debugf("New NEP17 transfer event detected: %s", hash)
-					transfer := parseNEP17Transfer(noti)
-					if transfer != nil {
-						transferInfo.transfers = append(transferInfo.transfers, transfer)
-					}
-				case strings.ToLower(string(models.ContractDestroyEvent)):
-					handleAssetDestroy(noti)
-				default:
-					// Detect if has address parameter, if true, check if has balance.
-					if !persistExtraAddrBalancesIfExists(noti) {
-						log.Infof("Notification in hash %s(%s) not parsed. EventName=%s", hash, noti.Src, eventName)
-					}
-				}
+			select {
+			case transferChan <- transferInfo:
+			case <-unit.Aborted():
+				return
 			}
-
-			transferChan <- &transferInfo
 		}
 
 		nextNotiPK = notis[len(notis)-1].ID + 1
 	}
 }
 
+// parseNotifications parses notifications of the same hash,
+// returns false if the chain has been rolled back.
+func parseNotifications(epoch uint, notis []*models.Notification) (*notiTransfer, bool) {
+	if !reorg.Hold(epoch) {
+		return nil, false
+	}
+
+	defer reorg.Release()
+
+	// hash and blockIndex are the same across these grouped notis,
+	// so get them from the first noti.
+	hash := notis[0].Hash
+	blockIndex := notis[0].BlockIndex
+
+	transferInfo := notiTransfer{
+		Hash:       hash,
+		BlockIndex: blockIndex,
+		epoch:      epoch,
+	}
+
+	for _, noti := range notis {
+		eventName := noti.EventName
+
+		switch strings.ToLower(eventName) {
+		case "transfer":
+			log.Debugf("New NEP17 transfer event detected: %s", hash)
+			transfer := parseNEP17Transfer(noti)
+			if transfer != nil {
+				transferInfo.transfers = append(transferInfo.transfers, transfer)
+			}
+		case strings.ToLower(string(models.ContractDestroyEvent)):
+			handleAssetDestroy(noti)
+		default:
+			// Detect if has address parameter, if true, check if has balance.
+			if !persistExtraAddrBalancesIfExists(noti) {
+				log.Infof("Notification in hash %s(%s) not parsed. EventName=%s", hash, noti.Src, eventName)
+			}
+		}
+	}
+
+	return &transferInfo, true
+}
+
 func groupNotiByHash(notis []*models.Notification) [][]*models.Notification {
 	notiArrays := [][]*models.Notification{}
 	arrIndex := 0
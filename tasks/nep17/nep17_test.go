@@ -0,0 +1,110 @@
+package nep17
+
+import (
+	"encoding/json"
+	"math/big"
+	assetCache "neo3-squirrel/cache/asset"
+	"neo3-squirrel/cache/native"
+	"neo3-squirrel/config"
+	"neo3-squirrel/models"
+	"neo3-squirrel/rpc"
+	"neo3-squirrel/tasks/reorg"
+	"neo3-squirrel/tasks/util"
+	"neo3-squirrel/tests/fullnode"
+	"testing"
+)
+
+func TestParseNotifications(t *testing.T) {
+	fullnode.Setup(t, rpc.UseFullnodes)
+
+	const gasHash = "0xd2a4cff31913016155e38e474a2c06d08be276cf"
+	const txID = "0x7cadb1d0280fad08576c52811ad49ceca2a39f66a0b4b2e7dc8f22a9f69d3bfb"
+	native.Register(native.GasToken, gasHash)
+	config.MaxVal = big.NewFloat(1e35)
+
+	appLog := rpc.GetApplicationLog(1, txID, nil)
+	if appLog == nil {
+		t.Fatalf("Failed to get application log of %s", txID)
+	}
+
+	notis := models.ParseApplicationLog(1, 0, appLog)
+	if len(notis) != 1 {
+		t.Fatalf("Expected 1 notification, got %d", len(notis))
+	}
+
+	// Asset info is queried from the fullnode once.
+	gas := util.QueryNEP17AssetInfo(notis[0], gasHash)
+	if gas == nil || gas.Symbol != "GAS" || gas.Decimals != 8 {
+		t.Fatalf("Incorrect GAS asset info: %+v", gas)
+	}
+	assetCache.Update(gas)
+	defer assetCache.Remove(gasHash)
+
+	transferInfo, ok := parseNotifications(reorg.Epoch(), notis)
+	if !ok {
+		t.Fatal("Notifications must be parsed without rollbacks")
+	}
+
+	if transferInfo.Hash != txID || len(transferInfo.transfers) != 1 {
+		t.Fatalf("Incorrect transfers of %s: %+v", txID, transferInfo)
+	}
+
+	from, _ := util.ExtractAddressFromByteString("rYw5KeAIoKmB3LXjw6CSi+zcKkE=")
+	to, _ := util.ExtractAddressFromByteString("06XCpaaLa04vLksKDmyMei88Gy4=")
+
+	transfer := transferInfo.transfers[0]
+	if transfer.Contract != gasHash || transfer.From != from || transfer.To != to {
+		t.Fatalf("Incorrect transfer: %+v", transfer)
+	}
+
+	if amount := transfer.Amount.Text('f', 8); amount != "2.00000000" {
+		t.Fatalf("Incorrect transfer amount, expected 2 GAS, got %s", amount)
+	}
+
+	// Notifications parsed before a rollback are abandoned.
+	if _, ok := parseNotifications(reorg.Epoch()+1, notis); ok {
+		t.Fatal("Notifications of a stale epoch must not be parsed")
+	}
+}
+
+func TestQueryBalancesWithoutHistoric(t *testing.T) {
+	node := fullnode.Setup(t, rpc.UseFullnodes)
+
+	node.Handle("invokescripthistoric", fullnode.MethodNotFound)
+	node.Handle("invokescript", func([]json.RawMessage) (interface{}, *fullnode.Error) {
+		return map[string]interface{}{
+			"state": "HALT",
+			"stack": []map[string]interface{}{{"type": "Integer", "value": "100000000"}},
+		}, nil
+	})
+
+	defer func() {
+		deferredBalances = map[string]*models.AddrAsset{}
+	}()
+
+	const gasHash = "0xd2a4cff31913016155e38e474a2c06d08be276cf"
+	addr, _ := util.ExtractAddressFromByteString("rYw5KeAIoKmB3LXjw6CSi+zcKkE=")
+
+	best := uint(rpc.GetBestHeight())
+	if best == 0 {
+		t.Fatal("Failed to get the best height")
+	}
+
+	// Balances of past blocks are deferred with transfers counted.
+	for i := 0; i < 2; i++ {
+		if _, ok := queryBalances(best-1, []string{addr}, gasHash, 8, 1); ok {
+			t.Fatalf("Balances of past blocks must not be taken from the latest state")
+		}
+	}
+
+	deferred, ok := deferredBalances[addr+gasHash]
+	if !ok || deferred.Transfers != 2 || deferred.Balance != nil {
+		t.Fatalf("Balance of %s must be deferred with 2 transfers, got %+v", addr, deferred)
+	}
+
+	// The latest state is taken at the best block.
+	addrAssets, ok := queryBalances(best, []string{addr}, gasHash, 8, 1)
+	if !ok || len(addrAssets) != 1 || addrAssets[0].Balance.Text('f', 8) != "1.00000000" {
+		t.Fatalf("Balance at the best block must be taken from the latest state, got %+v", addrAssets)
+	}
+}
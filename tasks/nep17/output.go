@@ -3,6 +3,7 @@ package nep17
 import (
 	"fmt"
 	"neo3-squirrel/cache/asset"
+	"neo3-squirrel/cache/native"
 	"neo3-squirrel/models"
 	"neo3-squirrel/util/color"
 	"neo3-squirrel/util/convert"
@@ -29,7 +30,7 @@ func showTransfers(transfers []*models.Transfer) {
 
 		if len(from) == 0 {
 			// Claim GAS.
-			if contractHash == models.GasToken {
+			if contractHash == native.GAS() {
 				content := fmt.Sprintf("%s System Reward: %s + %s", blockInfo, to, amountWithUnit)
 				msg = color.Greenf(content)
 			} else {
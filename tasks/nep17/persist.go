@@ -5,18 +5,47 @@ import (
 	"fmt"
 	"neo3-squirrel/cache/asset"
 	"neo3-squirrel/cache/block"
+	"neo3-squirrel/cache/native"
+	"neo3-squirrel/config"
 	"neo3-squirrel/db"
 	"neo3-squirrel/models"
 	"neo3-squirrel/rpc"
+	"neo3-squirrel/tasks/reorg"
 	"neo3-squirrel/tasks/util"
+	"neo3-squirrel/util/color"
 	"neo3-squirrel/util/log"
 	"time"
 )
 
 func persistNEP17Transfers(transferChan <-chan *notiTransfer) {
 	for txTransfers := range transferChan {
-		processNEP17Transfers(txTransfers)
+		if unit.IsAborted() {
+			return
+		}
+
+		unit.Track("persist", txTransfers.Hash)
+		persistNEP17Transfer(txTransfers)
+	}
+
+	log.Info(color.Green("NEP17 transfer sync task stopped"))
+}
+
+func persistNEP17Transfer(txTransfers *notiTransfer) {
+	// Skip transfers parsed before the chain was rolled back.
+	if !reorg.Hold(txTransfers.epoch) {
+		return
+	}
+
+	defer reorg.Release()
+
+	processNEP17Transfers(txTransfers)
+	persistDeferredBalances(txTransfers.BlockIndex)
+
+	// Progress never goes back for backfilled transfers
+	// unless the chain has been rolled back.
+	if txTransfers.BlockIndex > LastTxBlockIndex || txTransfers.epoch != lastTxEpoch {
 		LastTxBlockIndex = txTransfers.BlockIndex
+		lastTxEpoch = txTransfers.epoch
 	}
 }
 
@@ -32,6 +61,7 @@ func processNEP17Transfers(txTransfers *notiTransfer) {
 	// GAS balance updates and transaction gas fee deduction,
 	// If transaction sender's GAS changed, task should wait
 	// for some time to make sure GAS balance changing was finalized.
+	// Only needed when balances cannot be queried at exact heights.
 	slept := false
 
 	txAddrInfo := getTxAddrInfo(txTransfers.transfers)
@@ -48,31 +78,34 @@ func processNEP17Transfers(txTransfers *notiTransfer) {
 			continue
 		}
 
-		minBlockIndex := transfer.BlockIndex
-		readableBalances, ok := util.QueryNEP17Balances(minBlockIndex, addrs, assetHash, decimals)
+		// Balances of backfilled transfers are queried at the synced
+		// height, not to overwrite newer balances with stale ones.
+		blockIndex := transfer.BlockIndex
+		if blockIndex < LastTxBlockIndex {
+			blockIndex = LastTxBlockIndex
+		}
+
+		// Number of transfers added is 1.
+		queried, ok := queryBalances(blockIndex, addrs, assetHash, decimals, 1)
 		if !ok {
 			continue
 		}
 
 		// Get asset balance of these addresses.
-		for idx, addr := range addrs {
+		for _, addrAsset := range queried {
+			addr := addrAsset.Address
+
 			// Filter this query if already queried.
-			if addrAsset, ok := addrAssetBalanceCache[addr+assetHash]; ok {
-				addrAsset.Transfers++
+			if cached, ok := addrAssetBalanceCache[addr+assetHash]; ok {
+				cached.Transfers++
 				continue
 			}
 
-			sleepIfGasConsumed(&slept, minBlockIndex, transfer, assetHash, addr)
-
-			addrAsset := models.AddrAsset{
-				Address:   addr,
-				Contract:  assetHash,
-				Balance:   readableBalances[idx],
-				Transfers: 1, // Number of transfers added.
-			}
+			sleepIfGasConsumed(&slept, blockIndex, transfer, assetHash, addr)
+			bootstrapOpeningBalance(addrAsset, decimals)
 
-			addrAssets = append(addrAssets, &addrAsset)
-			addrAssetBalanceCache[addr+assetHash] = &addrAsset
+			addrAssets = append(addrAssets, addrAsset)
+			addrAssetBalanceCache[addr+assetHash] = addrAsset
 		}
 	}
 
@@ -105,8 +138,9 @@ func getTransferAddrs(transfer *models.Transfer) []string {
 func sleepIfGasConsumed(slept *bool, minBlockIndex uint, transfer *models.Transfer, contract, addr string) {
 	txID := transfer.Hash
 
-	if *slept || contract != models.GasToken ||
-		int(minBlockIndex) != rpc.GetBestHeight() {
+	if *slept || contract != native.GAS() ||
+		int(minBlockIndex) != rpc.GetBestHeight() ||
+		rpc.HistoricInvocationAvailable() {
 		return
 	}
 
@@ -132,6 +166,40 @@ func sleepIfGasConsumed(slept *bool, minBlockIndex uint, transfer *models.Transf
 	}
 }
 
+// bootstrapOpeningBalance queries the balance before the start height
+// for addresses first seen by a sync started in the middle of the chain.
+// The balance must be the one at the exact height, so it panics
+// if no fullnode supports historic invocations.
+func bootstrapOpeningBalance(addrAsset *models.AddrAsset, decimals uint) {
+	startHeight := config.GetStartHeight()
+	if startHeight == 0 ||
+		db.AddrAssetExists(addrAsset.Address, addrAsset.Contract) {
+		return
+	}
+
+	params := []rpc.ContractParameter{
+		rpc.NewHash160Param(util.GetAddrScriptHash(addrAsset.Address)),
+	}
+
+	result, ok, err := rpc.InvokeFunctionHistoric(startHeight-1, addrAsset.Contract, "balanceOf", params)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if !ok {
+		log.Panicf("Failed to get opening balance of %s at block %d: no fullnode supports historic invocations",
+			addrAsset.Address, startHeight-1)
+	}
+
+	// Assets not deployed yet at the start height have no balance.
+	balance, ok := util.ParseNEP17Balance(result, decimals)
+	if !ok {
+		return
+	}
+
+	addrAsset.OpeningBalance = balance
+}
+
 func persistExtraAddrBalancesIfExists(noti *models.Notification) bool {
 	if util.VMStateFault(noti.VMState) {
 		log.Debugf("VM execution status FAULT: %s", noti.Hash)
@@ -172,17 +240,14 @@ func persistExtraAddrBalancesIfExists(noti *models.Notification) bool {
 			continue
 		}
 
-		readableBalance, ok := util.QueryNEP17Balance(noti.BlockIndex, addr, contract, decimals)
+		queried, ok := queryBalances(noti.BlockIndex, []string{addr}, contract, decimals, 0)
 		if !ok {
 			continue
 		}
 
-		addrAssets = append(addrAssets, &models.AddrAsset{
-			Address:   addr,
-			Contract:  contract,
-			Balance:   readableBalance,
-			Transfers: 0,
-		})
+		addrAsset := queried[0]
+		bootstrapOpeningBalance(addrAsset, decimals)
+		addrAssets = append(addrAssets, addrAsset)
 	}
 
 	if len(addrAssets) > 0 {
@@ -0,0 +1,47 @@
+package reorg
+
+import "sync"
+
+var (
+	// mu guards db writes of sync tasks against chain rollbacks.
+	mu sync.RWMutex
+
+	// epoch increases every time the persisted chain was rolled back.
+	epoch uint
+)
+
+// Epoch returns the current rollback epoch.
+// Tasks should restart from their db checkpoint once it changed.
+func Epoch() uint {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return epoch
+}
+
+// Hold prevents rollbacks until Release is called.
+// It returns false and holds nothing if the chain
+// has been rolled back since the given epoch.
+func Hold(e uint) bool {
+	mu.RLock()
+	if e != epoch {
+		mu.RUnlock()
+		return false
+	}
+
+	return true
+}
+
+// Release releases the hold acquired by Hold.
+func Release() {
+	mu.RUnlock()
+}
+
+// Apply runs the rollback exclusively and starts a new epoch.
+func Apply(rollback func()) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	rollback()
+	epoch++
+}
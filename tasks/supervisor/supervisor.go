@@ -0,0 +1,145 @@
+package supervisor
+
+import (
+	"context"
+	"neo3-squirrel/util/color"
+	"neo3-squirrel/util/log"
+	"sync"
+	"time"
+)
+
+const (
+	minBackoff = 1 * time.Second
+	maxBackoff = 1 * time.Minute
+)
+
+// Task states.
+const (
+	Running    = "running"
+	Restarting = "restarting"
+	Stopped    = "stopped"
+)
+
+// Task is a supervised sync task. Start launches goroutines of
+// the task in the unit, resuming from the task's db checkpoint.
+type Task struct {
+	Name  string
+	Start func(ctx context.Context, unit *Unit)
+}
+
+// TaskState shows the state of a supervised task.
+type TaskState struct {
+	State       string
+	Restarts    uint
+	LastFailure *Failure
+}
+
+var (
+	states = map[string]*TaskState{}
+	mu     sync.RWMutex
+)
+
+// GetStates returns states of all supervised tasks.
+func GetStates() map[string]TaskState {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make(map[string]TaskState, len(states))
+	for name, state := range states {
+		result[name] = *state
+	}
+
+	return result
+}
+
+// Run runs the tasks and restarts crashed ones with backoff.
+// It returns after all tasks stopped once ctx is cancelled.
+func Run(ctx context.Context, tasks ...Task) {
+	wg := sync.WaitGroup{}
+
+	for _, task := range tasks {
+		wg.Add(1)
+
+		go func(task Task) {
+			defer wg.Done()
+			supervise(ctx, task)
+		}(task)
+	}
+
+	wg.Wait()
+}
+
+func supervise(ctx context.Context, task Task) {
+	backoff := minBackoff
+
+	for {
+		setState(task.Name, Running, nil)
+
+		unit := NewUnit()
+		started := time.Now()
+
+		func() {
+			defer unit.recover("start")
+			task.Start(ctx, unit)
+		}()
+
+		unit.Wait()
+
+		failure := unit.Failure()
+		if failure == nil {
+			setState(task.Name, Stopped, nil)
+			return
+		}
+
+		log.Error(color.BRedf("Task %s crashed in stage %s: %s", task.Name, failure.Stage, failure.Err))
+		if failure.Item != "" {
+			log.Error(color.BRedf("Failing item: %s", failure.Item))
+		}
+		log.Error(failure.Stack)
+
+		if ctx.Err() != nil {
+			setState(task.Name, Stopped, failure)
+			return
+		}
+
+		// Tasks running long enough are considered recovered.
+		if time.Since(started) > maxBackoff {
+			backoff = minBackoff
+		}
+
+		setState(task.Name, Restarting, failure)
+		log.Warn(color.BYellowf("Restart task %s in %s", task.Name, backoff))
+
+		select {
+		case <-ctx.Done():
+			setState(task.Name, Stopped, nil)
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func setState(name, state string, failure *Failure) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	s, ok := states[name]
+	if !ok {
+		s = &TaskState{}
+		states[name] = s
+	}
+
+	s.State = state
+	if failure != nil {
+		s.LastFailure = failure
+	}
+
+	if state == Restarting {
+		s.Restarts++
+	}
+}
@@ -0,0 +1,83 @@
+package supervisor
+
+import (
+	"context"
+	"neo3-squirrel/util/log"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// resetStates clears states of tasks supervised by previous runs.
+func resetStates() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	states = map[string]*TaskState{}
+}
+
+func TestRun(t *testing.T) {
+	log.Init(true)
+	resetStates()
+	defer func() {
+		resetStates()
+		os.RemoveAll("./logs")
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := int32(0)
+
+	task := Task{
+		Name: "test",
+		Start: func(ctx context.Context, unit *Unit) {
+			run := atomic.AddInt32(&runs, 1)
+
+			// The first run crashes while the other stage is waiting.
+			unit.Go("wait", func() {
+				select {
+				case <-ctx.Done():
+				case <-unit.Aborted():
+				}
+			})
+
+			unit.Go("work", func() {
+				if run == 1 {
+					unit.Track("work", "item 1")
+					panic("bad item")
+				}
+
+				cancel()
+			})
+		},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		Run(ctx, task)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Supervisor must return after the task stopped")
+	}
+
+	if runs != 2 {
+		t.Fatalf("Crashed task must be restarted once, got %d runs", runs)
+	}
+
+	state := GetStates()["test"]
+	if state.State != Stopped || state.Restarts != 1 {
+		t.Fatalf("Incorrect task state: %+v", state)
+	}
+
+	failure := state.LastFailure
+	if failure == nil ||
+		failure.Stage != "work" ||
+		failure.Item != "item 1" ||
+		failure.Err != "bad item" {
+		t.Fatalf("Incorrect task failure: %+v", failure)
+	}
+}
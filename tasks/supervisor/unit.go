@@ -0,0 +1,111 @@
+package supervisor
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Failure records the panic which aborted a unit.
+type Failure struct {
+	// Stage is the name of the panicked goroutine.
+	Stage string
+	// Item is the last item tracked by the stage.
+	Item  string
+	Err   string
+	Stack string
+	Time  time.Time
+}
+
+// Unit is a single run of a supervised task. The first panic in
+// any goroutine of the unit aborts all the others.
+type Unit struct {
+	wg    sync.WaitGroup
+	abort chan struct{}
+
+	mu      sync.Mutex
+	items   map[string]string
+	failure *Failure
+}
+
+// NewUnit creates a new unit.
+func NewUnit() *Unit {
+	return &Unit{
+		abort: make(chan struct{}),
+		items: map[string]string{},
+	}
+}
+
+// Go runs f of the given stage in a new goroutine of the unit.
+func (u *Unit) Go(stage string, f func()) {
+	u.wg.Add(1)
+
+	go func() {
+		defer u.wg.Done()
+		defer u.recover(stage)
+
+		f()
+	}()
+}
+
+// Track records the item being processed by the stage,
+// which is reported if the stage panics.
+func (u *Unit) Track(stage, item string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.items[stage] = item
+}
+
+// Aborted returns a channel which is closed once the unit aborted.
+func (u *Unit) Aborted() <-chan struct{} {
+	return u.abort
+}
+
+// IsAborted tells if the unit has been aborted.
+func (u *Unit) IsAborted() bool {
+	select {
+	case <-u.abort:
+		return true
+	default:
+		return false
+	}
+}
+
+// Failure returns the panic which aborted the unit, or nil.
+func (u *Unit) Failure() *Failure {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.failure
+}
+
+// Wait waits for all goroutines of the unit to return.
+func (u *Unit) Wait() {
+	u.wg.Wait()
+}
+
+func (u *Unit) recover(stage string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.failure != nil {
+		return
+	}
+
+	u.failure = &Failure{
+		Stage: stage,
+		Item:  u.items[stage],
+		Err:   fmt.Sprint(r),
+		Stack: string(debug.Stack()),
+		Time:  time.Now(),
+	}
+
+	close(u.abort)
+}
@@ -1,6 +1,7 @@
 package tasks
 
 import (
+	"context"
 	"neo3-squirrel/cache/address"
 	"neo3-squirrel/cache/asset"
 	"neo3-squirrel/db"
@@ -8,12 +9,20 @@ import (
 	"neo3-squirrel/tasks/applog"
 	"neo3-squirrel/tasks/block"
 	"neo3-squirrel/tasks/contract"
+	"neo3-squirrel/tasks/gap"
+	"neo3-squirrel/tasks/multisig"
 	"neo3-squirrel/tasks/nep17"
+	"neo3-squirrel/tasks/supervisor"
+	"neo3-squirrel/tasks/util"
+	"neo3-squirrel/util/color"
 	"neo3-squirrel/util/log"
 )
 
-// Run manages all sync tasks.
-func Run() {
+// Run manages all sync tasks as supervised units, crashed tasks are
+// restarted from their db checkpoints. It returns after all tasks
+// flushed their pending data once ctx is cancelled.
+// Gaps in persisted data are filled alongside if backfill is set.
+func Run(ctx context.Context, backfill bool) {
 	log.Info("Start Neo3 blockchain data parser.")
 
 	// Cache all known addresses from DB.
@@ -23,10 +32,90 @@ func Run() {
 	assets := db.GetAllAssets()
 	asset.UpdateMulti(assets)
 
+	checkNetwork()
+	contract.SyncNativeContracts()
+
+	syncTasks := []supervisor.Task{
+		{Name: "block", Start: block.StartBlockSyncTask},
+		{Name: "contract", Start: contract.StartContractTask},
+		{Name: "applog", Start: applog.StartApplicationLogSyncTask},
+		{Name: "nep17", Start: nep17.StartNEP17TransferSyncTask},
+	}
+
+	if backfill {
+		syncTasks = append(syncTasks, supervisor.Task{Name: "backfill", Start: gap.StartBackfillTask})
+	}
+
+	supervisor.Run(ctx, syncTasks...)
+}
+
+// ScanGaps lists missing ranges of persisted
+// blocks, transactions and notifications.
+func ScanGaps() {
+	checkNetwork()
+
+	log.Info("Scanning gaps of persisted data.")
+	gap.Scan(context.Background()).Print()
+}
+
+// VerifyWitnesses verifies consensus witnesses of persisted blocks
+// offline, blocks with invalid witnesses are listed.
+func VerifyWitnesses() {
+	magic := db.GetNetwork()
+	if magic == 0 {
+		log.Panic("Network of persisted data unknown, sync some blocks first")
+	}
+
+	log.Info("Verifying witnesses of persisted blocks.")
+
+	invalid := block.VerifyWitnesses(magic)
+	if len(invalid) == 0 {
+		log.Info(color.Green("All persisted block witnesses are valid"))
+		return
+	}
+
+	log.Warn(color.BYellowf("Found %d blocks with invalid witnesses: %v", len(invalid), invalid))
+}
+
+// RebuildMultiSigRegistry registers multi-signature
+// accounts of all persisted witnesses.
+func RebuildMultiSigRegistry() {
+	log.Info("Rebuilding multi-signature account registry.")
+	multisig.Rebuild()
+}
+
+// ShowMultiSigAccount prints members of the multi-signature account.
+func ShowMultiSigAccount(address string) {
+	multisig.Show(address)
+}
+
+// checkNetwork starts tracing fullnodes on the network of persisted data.
+func checkNetwork() {
+	magic := db.GetNetwork()
+	rpc.SetNetwork(magic)
 	rpc.TraceBestHeight()
 
-	block.StartBlockSyncTask()
-	contract.StartContractTask()
-	applog.StartApplicationLogSyncTask()
-	nep17.StartNEP17TransferSyncTask()
+	network, _ := rpc.GetNetwork()
+	if network.AddressVersion != util.AddressVersion {
+		log.Panicf("Unsupported address version %d of network %d", network.AddressVersion, network.Network)
+	}
+
+	if magic == 0 {
+		db.SetNetwork(network.Network)
+		log.Infof("Persisted data bound to network %d", network.Network)
+	}
+}
+
+// Rollback unwinds all tables to the given block height.
+// Must not be called while sync tasks are running.
+func Rollback(height uint) {
+	lastBlockHeight := db.GetLastBlockHeight()
+	if int(height) >= lastBlockHeight {
+		log.Infof("Nothing to roll back, last block height is %d", lastBlockHeight)
+		return
+	}
+
+	log.Info(color.BYellowf("Roll back from block %d to block %d", lastBlockHeight, height))
+	block.Rollback(height)
+	log.Info(color.Greenf("Rolled back to block %d", height))
 }
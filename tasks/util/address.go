@@ -6,8 +6,12 @@ import (
 	"neo3-squirrel/util/base58"
 	"neo3-squirrel/util/byteutil"
 	"neo3-squirrel/util/hashutil"
+	"neo3-squirrel/util/witness"
 )
 
+// AddressVersion is the address version byte of Neo3 addresses.
+const AddressVersion = 0x35
+
 // GetAddrScriptHash returns script hash of an address.
 // E.g., NTdkuNTx38tQk3a5rnV9HPT96zqFHCb97h -> b3f1f587042a20dd0eef2e47f137504f1419b054
 func GetAddrScriptHash(address string) string {
@@ -27,7 +31,7 @@ func ExtractAddressFromByteString(byteString string) (string, bool) {
 		return "", false
 	}
 
-	bytes = append([]byte{0x35}, bytes...)
+	bytes = append([]byte{AddressVersion}, bytes...)
 	return base58.CheckEncode(bytes), true
 }
 
@@ -41,11 +45,23 @@ func GetAddressFromPublicKeyBase64(base64Str string) (string, bool) {
 	return GetAddressFromPublicKeyBytes(bytes), true
 }
 
-// GetAddressFromPublicKeyBytes calculates address from public key hex string.
+// GetAddressFromPublicKeyBytes calculates the standard account address from public key bytes.
 func GetAddressFromPublicKeyBytes(bytes []byte) string {
-	bytes = append([]byte{0x0C, 0x21}, bytes...)
-	bytes = append(bytes, 0x0b, 0x41, 0x95, 0x44, 0x0d, 0x78)
-	bytes = append([]byte{0x35}, hashutil.Hash160(bytes)...)
+	return GetAddressFromScript(witness.StandardScript(bytes))
+}
+
+// GetMultiSigAddress calculates the address of the m-of-n multi-signature account.
+func GetMultiSigAddress(m int, pubKeys [][]byte) (string, error) {
+	script, err := witness.MultiSigScript(m, pubKeys)
+	if err != nil {
+		return "", err
+	}
+
+	return GetAddressFromScript(script), nil
+}
 
+// GetAddressFromScript calculates address from verification script bytes.
+func GetAddressFromScript(script []byte) string {
+	bytes := append([]byte{AddressVersion}, hashutil.Hash160(script)...)
 	return base58.CheckEncode(bytes)
 }
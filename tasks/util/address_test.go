@@ -7,8 +7,8 @@ import (
 
 func TestGetAddressFromPublicKeyBytes(t *testing.T) {
 	testCases := map[string]string{
-		"02562e7ff2f939d160a7db692fb6edd5a2e6f85d6c92027f88b6604b867713a159": "NdhRqndwajjqzrdRTgTMiXdZzLBqSeRKR5",
-		"02208aea0068c429a03316e37be0e3e8e21e6cda5442df4c5914a19b3a9b6de375": "NUnLWXALK2G6gYa7RadPLRiQYunZHnncxg",
+		"02562e7ff2f939d160a7db692fb6edd5a2e6f85d6c92027f88b6604b867713a159": "NfGnDMchMSJfMqbHa4H6txnuC981Vagccs",
+		"02208aea0068c429a03316e37be0e3e8e21e6cda5442df4c5914a19b3a9b6de375": "Nari61wgSjpn2b8958ticxYqMWJ3k1EB6o",
 	}
 
 	for pubKey, addr := range testCases {
@@ -23,3 +23,36 @@ func TestGetAddressFromPublicKeyBytes(t *testing.T) {
 		}
 	}
 }
+
+func TestGetMultiSigAddress(t *testing.T) {
+	// Standby validators of N3 MainNet.
+	validators := []string{
+		"03b209fd4f53a7170ea4444e0cb0a6bb6a53c2bd016926989cf85f9b0fba17a70c",
+		"02df48f60e8f3e01c48ff40b9b7f1310d7a8b2a193188befe1c2e3df740e895093",
+		"03b8d9d5771d8f513aa0869b9cc8d50986403b78c6da36890638c3d46a5adce04a",
+		"02ca0e27697b9c248f6f16e085fd0061e26f44da85b58ee835c110caa5ec3ba554",
+		"024c7b7fb6c310fccf1ba33b082519d82964ea93868d676662d4a59ad548df0e7d",
+		"02aaec38470f6aad0042c6e877cfd8087d2676b0f516fddd362801b9bd3936399e",
+		"02486fd15702c4490a26703112a5cc1d0923fd697a33406bd5a1c00e0013b09a70",
+	}
+
+	pubKeys := [][]byte{}
+	for _, validator := range validators {
+		bytes, err := hex.DecodeString(validator)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pubKeys = append(pubKeys, bytes)
+	}
+
+	want := "NVg7LjGcUSrgxgjX3zEgqaksfMaiS8Z6e1"
+	get, err := GetMultiSigAddress(5, pubKeys)
+	if err != nil || get != want {
+		t.Fatalf("Failed to get multi-signature address, get=%s, want=%s, err=%v", get, want, err)
+	}
+
+	if _, err := GetMultiSigAddress(8, pubKeys); err == nil {
+		t.Fatal("Threshold greater than the number of keys must be rejected")
+	}
+}
@@ -13,8 +13,9 @@ import (
 	"strings"
 )
 
-// QueryNEP17Balance queries address contract balance from fullnode.
-func QueryNEP17Balance(minBlockIndex uint, address, contract string, decimals uint) (*big.Float, bool) {
+// QueryNEP17Balance queries address contract balance right after the given block.
+// Returns false if no fullnode supports historic invocations.
+func QueryNEP17Balance(blockIndex uint, address, contract string, decimals uint) (*big.Float, bool) {
 	if len(address) == 0 {
 		err := fmt.Errorf("address cannot be empty")
 		log.Panic(err)
@@ -22,13 +23,25 @@ func QueryNEP17Balance(minBlockIndex uint, address, contract string, decimals ui
 
 	const method = "balanceOf"
 	addrScriptHash := GetAddrScriptHash(address)
-	params := []interface{}{
-		[]rpc.StackItem{
-			{Type: "Hash160", Value: addrScriptHash},
-		},
+	params := []rpc.ContractParameter{
+		rpc.NewHash160Param(addrScriptHash),
 	}
 
-	result := rpc.InvokeFunction(minBlockIndex, contract, method, params)
+	result, ok, err := rpc.InvokeFunctionHistoric(blockIndex, contract, method, params)
+	if err != nil {
+		log.Error(err)
+		return nil, false
+	}
+
+	if !ok {
+		return nil, false
+	}
+
+	return ParseNEP17Balance(result, decimals)
+}
+
+// ParseNEP17Balance parses the balance from the result of 'balanceOf'.
+func ParseNEP17Balance(result *rpc.InvokeFunctionResult, decimals uint) (*big.Float, bool) {
 	if result == nil ||
 		VMStateFault(result.State) ||
 		len(result.Stack) == 0 {
@@ -48,8 +61,47 @@ func QueryNEP17Balance(minBlockIndex uint, address, contract string, decimals ui
 	return convert.AmountReadable(rawBalance, decimals), true
 }
 
-// QueryNEP17Balances queries addresses balances from fullnode.
-func QueryNEP17Balances(minBlockIndex uint, addresses []string, contract string, decimals uint) ([]*big.Float, bool) {
+// QueryNEP17Balances queries addresses balances right after the given block.
+// Returns false if no fullnode supports historic invocations.
+func QueryNEP17Balances(blockIndex uint, addresses []string, contract string, decimals uint) ([]*big.Float, bool) {
+	script, ok := generateNEP17BalancesScript(addresses, contract)
+	if !ok {
+		return nil, false
+	}
+
+	result, ok, err := rpc.InvokeScriptHistoric(blockIndex, script)
+	if err != nil {
+		log.Error(err)
+		return nil, false
+	}
+
+	if !ok {
+		return nil, false
+	}
+
+	return parseNEP17Balances(result, len(addresses), decimals)
+}
+
+// QueryLatestNEP17Balances queries addresses balances from the latest state
+// of fullnodes which have reached the given block.
+func QueryLatestNEP17Balances(minBlockIndex uint, addresses []string, contract string, decimals uint) ([]*big.Float, bool) {
+	script, ok := generateNEP17BalancesScript(addresses, contract)
+	if !ok {
+		return nil, false
+	}
+
+	result, err := rpc.InvokeScript(minBlockIndex, script)
+	if err != nil {
+		log.Error(err)
+		return nil, false
+	}
+
+	return parseNEP17Balances(result, len(addresses), decimals)
+}
+
+// generateNEP17BalancesScript generates the base64 encoded script
+// querying balances of all the addresses.
+func generateNEP17BalancesScript(addresses []string, contract string) (string, bool) {
 	if len(addresses) == 0 {
 		err := fmt.Errorf("addresses cannot be empty")
 		log.Panic(err)
@@ -59,7 +111,7 @@ func QueryNEP17Balances(minBlockIndex uint, addresses []string, contract string,
 	for _, addr := range addresses {
 		sc, err := generateNEP17BalanceOfScript(addr, contract)
 		if err != nil {
-			return nil, false
+			return "", false
 		}
 
 		script += sc
@@ -71,10 +123,13 @@ func QueryNEP17Balances(minBlockIndex uint, addresses []string, contract string,
 		log.Panic(err)
 	}
 
-	result := rpc.InvokeScript(minBlockIndex, base64.StdEncoding.EncodeToString(scriptBytes))
+	return base64.StdEncoding.EncodeToString(scriptBytes), true
+}
+
+func parseNEP17Balances(result *rpc.InvokeFunctionResult, count int, decimals uint) ([]*big.Float, bool) {
 	if result == nil ||
 		VMStateFault(result.State) ||
-		len(result.Stack) < len(addresses) {
+		len(result.Stack) < count {
 		return nil, false
 	}
 
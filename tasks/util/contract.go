@@ -60,7 +60,13 @@ func queryAssetBasicInfo(minBlockIndex uint, asset *models.Asset) bool {
 	}
 	asset.Decimals = uint(dec)
 
+	// Total supply of assets is the current one, so it can
+	// be taken from the latest state without historic invocations.
 	asset.TotalSupply, ok = QueryAssetTotalSupply(minBlockIndex, contract, asset.Decimals)
+	if !ok && !rpc.HistoricInvocationAvailable() {
+		asset.TotalSupply, ok = QueryLatestAssetTotalSupply(minBlockIndex, contract, asset.Decimals)
+	}
+
 	if !ok {
 		log.Warnf("Failed to get 'totalSupply' from contract %s", contract)
 	}
@@ -68,9 +74,11 @@ func queryAssetBasicInfo(minBlockIndex uint, asset *models.Asset) bool {
 	return ok
 }
 
-// QueryAssetTotalSupply queries total supply of the given contract and returns as decimals-formatted value.
-func QueryAssetTotalSupply(minBlockIndex uint, contract string, decimals uint) (*big.Float, bool) {
-	totalSupply, ok := queryContractTotalSupply(minBlockIndex, contract)
+// QueryAssetTotalSupply queries total supply of the given contract right after
+// the given block and returns as decimals-formatted value.
+// Returns false if no fullnode supports historic invocations.
+func QueryAssetTotalSupply(blockIndex uint, contract string, decimals uint) (*big.Float, bool) {
+	totalSupply, ok := queryContractTotalSupply(blockIndex, contract)
 	if !ok {
 		return nil, false
 	}
@@ -79,6 +87,22 @@ func QueryAssetTotalSupply(minBlockIndex uint, contract string, decimals uint) (
 	return totalSupply, true
 }
 
+// QueryLatestAssetTotalSupply queries total supply of the given contract from
+// the latest state of fullnodes which have reached the given block.
+func QueryLatestAssetTotalSupply(minBlockIndex uint, contract string, decimals uint) (*big.Float, bool) {
+	stack, ok := queryContractProperty(minBlockIndex, contract, "totalSupply")
+	if !ok {
+		return nil, false
+	}
+
+	totalSupply, ok := extractValue(models.ParseStackItem(stack))
+	if !ok {
+		return nil, false
+	}
+
+	return convert.AmountReadable(totalSupply, decimals), true
+}
+
 func queryContractSymbol(minBlockIndex uint, contract string) (string, bool) {
 	stack, ok := queryContractProperty(minBlockIndex, contract, "symbol")
 	if !ok {
@@ -97,18 +121,33 @@ func queryContractDecimals(minBlockIndex uint, contract string) (*big.Float, boo
 	return extractValue(models.ParseStackItem(stack))
 }
 
-func queryContractTotalSupply(minBlockIndex uint, contract string) (*big.Float, bool) {
-	stack, ok := queryContractProperty(minBlockIndex, contract, "totalSupply")
-	if !ok {
+func queryContractTotalSupply(blockIndex uint, contract string) (*big.Float, bool) {
+	result, ok, err := rpc.InvokeFunctionHistoric(blockIndex, contract, "totalSupply", nil)
+	if err != nil {
+		log.Error(err)
 		return nil, false
 	}
 
+	if !ok ||
+		result == nil ||
+		VMStateFault(result.State) ||
+		len(result.Stack) == 0 {
+		return nil, false
+	}
+
+	stack := &result.Stack[0]
+
 	return extractValue(models.ParseStackItem(stack))
 }
 
 // query symbol and decimals
 func queryContractProperty(minBlockIndex uint, contract, property string) (*rpc.StackItem, bool) {
-	result := rpc.InvokeFunction(minBlockIndex, contract, property, nil)
+	result, err := rpc.InvokeFunction(minBlockIndex, contract, property, nil)
+	if err != nil {
+		log.Error(err)
+		return nil, false
+	}
+
 	if result == nil ||
 		VMStateFault(result.State) ||
 		len(result.Stack) == 0 {
@@ -2,6 +2,7 @@ package util
 
 import (
 	"neo3-squirrel/cache/gas"
+	"neo3-squirrel/cache/native"
 	"neo3-squirrel/config"
 	"neo3-squirrel/models"
 	"neo3-squirrel/rpc"
@@ -26,7 +27,6 @@ func QueryNEP17AssetInfo(noti *models.Notification, contractHash string) *models
 		Name:       contractState.Manifest.Name,
 	}
 
-	bestBlockIndex := rpc.GetBestHeight()
 	ok := queryAssetBasicInfo(blockIndex, &asset)
 	if !ok {
 		log.Warnf("Failed to get NEP17 contract info. Hash=%s(%s), Contract=%s, BlockIndex=%d, BlockTime=%s",
@@ -34,8 +34,8 @@ func QueryNEP17AssetInfo(noti *models.Notification, contractHash string) *models
 		return nil
 	}
 
-	if contractHash == models.GasToken && bestBlockIndex > 0 {
-		gas.CacheGASTotalSupply(uint(bestBlockIndex), asset.TotalSupply)
+	if contractHash == native.GAS() {
+		gas.CacheGASTotalSupply(blockIndex, asset.TotalSupply)
 	}
 
 	if asset.TotalSupply != nil && asset.TotalSupply.Cmp(config.MaxVal) > 0 {
@@ -0,0 +1,685 @@
+// Package fullnode implements a scriptable fake fullnode which serves
+// JSON-RPC requests from fixture files, for offline tests.
+package fullnode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"neo3-squirrel/util/log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// Error codes returned by the fake fullnode.
+const (
+	ErrCodeInvalidRequest = -32600
+	ErrCodeMethodNotFound = -32601
+	ErrCodeInvalidParams  = -32602
+	ErrCodeUnknownItem    = -100
+	// ErrCodeUnknownStateRoot is returned by historic invocations
+	// of blocks whose states are not kept.
+	ErrCodeUnknownStateRoot = -106
+)
+
+// Error is the JSON-RPC error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Handler handles a JSON-RPC method call with its raw params.
+type Handler func(params []json.RawMessage) (interface{}, *Error)
+
+// Fullnode is a fake fullnode. It serves blocks, applicationlogs,
+// contract states and invoke results loaded from fixture files,
+// and any method can be overridden with a custom handler.
+type Fullnode struct {
+	// URL is the base url of the underlying httptest server.
+	URL string
+	// WSURL is the WebSocket endpoint pushing 'block_added' events.
+	WSURL string
+
+	server *httptest.Server
+
+	mu sync.Mutex
+	// height is the highest block index visible to clients.
+	height      int
+	blocks      map[uint]json.RawMessage
+	blockHashes map[string]uint
+	appLogs     map[string]json.RawMessage
+	contracts   map[string]json.RawMessage
+	invokes     map[string]json.RawMessage
+	handlers    map[string]Handler
+	calls       map[string]int
+	protocol    Protocol
+	subscribers map[*websocket.Conn]bool
+}
+
+// Protocol is the protocol settings returned by 'getversion'.
+type Protocol struct {
+	AddressVersion  byte   `json:"addressversion"`
+	Network         uint32 `json:"network"`
+	ValidatorsCount uint   `json:"validatorscount"`
+	MSPerBlock      uint   `json:"msperblock"`
+}
+
+type request struct {
+	JSONRPC string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// invoke is the fixture format of invoke results.
+type invoke struct {
+	Contract string          `json:"contract"`
+	Method   string          `json:"method"`
+	Script   string          `json:"script"`
+	Result   json.RawMessage `json:"result"`
+}
+
+// New starts a new fake fullnode without any data.
+func New() *Fullnode {
+	f := &Fullnode{
+		height:      -1,
+		blocks:      map[uint]json.RawMessage{},
+		blockHashes: map[string]uint{},
+		appLogs:     map[string]json.RawMessage{},
+		contracts:   map[string]json.RawMessage{},
+		invokes:     map[string]json.RawMessage{},
+		handlers:    map[string]Handler{},
+		calls:       map[string]int{},
+		subscribers: map[*websocket.Conn]bool{},
+		protocol: Protocol{
+			AddressVersion:  53,
+			Network:         860833102,
+			ValidatorsCount: 7,
+			MSPerBlock:      15000,
+		},
+	}
+
+	f.server = httptest.NewServer(f)
+	f.URL = f.server.URL
+	f.WSURL = "ws" + strings.TrimPrefix(f.server.URL, "http")
+
+	return f
+}
+
+// Close shuts down the fake fullnode.
+func (f *Fullnode) Close() {
+	f.server.Close()
+}
+
+// LoadFixtures loads fixture files from the sub directories of dir:
+// `blocks`, `applogs`, `contracts` and `invokes`. Each file contains
+// a single rpc result, invoke fixtures are wrapped with the invoked
+// contract and method, or the invoked script.
+func (f *Fullnode) LoadFixtures(dir string) error {
+	loaders := map[string]func(json.RawMessage) error{
+		"blocks":    f.AddBlock,
+		"applogs":   f.AddAppLog,
+		"contracts": f.AddContractState,
+		"invokes":   f.addInvoke,
+	}
+
+	for sub, load := range loaders {
+		files, err := filepath.Glob(filepath.Join(dir, sub, "*.json"))
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+
+			if err := load(data); err != nil {
+				return fmt.Errorf("%s: %v", file, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// AddBlock adds a block of the 'getblock' verbose format,
+// and raises the visible height to its index.
+func (f *Fullnode) AddBlock(data json.RawMessage) error {
+	block := struct {
+		Hash  string `json:"hash"`
+		Index uint   `json:"index"`
+	}{}
+	if err := json.Unmarshal(data, &block); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.blocks[block.Index] = data
+	f.blockHashes[block.Hash] = block.Index
+	if int(block.Index) > f.height {
+		f.height = int(block.Index)
+	}
+
+	return nil
+}
+
+// AddAppLog adds an applicationlog of a transaction or a block.
+func (f *Fullnode) AddAppLog(data json.RawMessage) error {
+	appLog := struct {
+		TxID      string `json:"txid"`
+		BlockHash string `json:"blockhash"`
+	}{}
+	if err := json.Unmarshal(data, &appLog); err != nil {
+		return err
+	}
+
+	key := appLog.TxID
+	if key == "" {
+		key = appLog.BlockHash
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.appLogs[key] = data
+
+	return nil
+}
+
+// AddContractState adds a contract state.
+func (f *Fullnode) AddContractState(data json.RawMessage) error {
+	contract := struct {
+		Hash string `json:"hash"`
+	}{}
+	if err := json.Unmarshal(data, &contract); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.contracts[contract.Hash] = data
+
+	return nil
+}
+
+// AddInvokeResult adds the result of 'invokefunction' of the given contract and method.
+func (f *Fullnode) AddInvokeResult(contract, method string, result json.RawMessage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.invokes[contract+"."+method] = result
+}
+
+// AddScriptResult adds the result of 'invokescript' of the given script.
+func (f *Fullnode) AddScriptResult(script string, result json.RawMessage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.invokes[script] = result
+}
+
+func (f *Fullnode) addInvoke(data json.RawMessage) error {
+	inv := invoke{}
+	if err := json.Unmarshal(data, &inv); err != nil {
+		return err
+	}
+
+	if inv.Script != "" {
+		f.AddScriptResult(inv.Script, inv.Result)
+	} else {
+		f.AddInvokeResult(inv.Contract, inv.Method, inv.Result)
+	}
+
+	return nil
+}
+
+// SetHeight sets the highest block index visible to clients,
+// blocks above it are treated as unknown.
+func (f *Fullnode) SetHeight(height int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.height = height
+}
+
+// SetNetwork sets the network magic returned by 'getversion'.
+func (f *Fullnode) SetNetwork(magic uint32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.protocol.Network = magic
+}
+
+// Handle overrides the handler of the given method.
+func (f *Fullnode) Handle(method string, handler Handler) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.handlers[method] = handler
+}
+
+// Calls returns how many times the given method has been called.
+func (f *Fullnode) Calls(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls[method]
+}
+
+// ServeHTTP implements http.Handler.
+func (f *Fullnode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		f.serveWS(w, r)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(f.serve(body))
+}
+
+// serveWS accepts a 'block_added' subscription and
+// keeps the connection until the client quits.
+func (f *Fullnode) serveWS(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	req := request{}
+	if err := conn.ReadJSON(&req); err != nil {
+		return
+	}
+
+	f.mu.Lock()
+	err = conn.WriteJSON(response{JSONRPC: "2.0", ID: req.ID, Result: "1"})
+	f.subscribers[conn] = true
+	f.mu.Unlock()
+
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		f.mu.Lock()
+		delete(f.subscribers, conn)
+		f.mu.Unlock()
+	}()
+
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// Subscribers returns the number of WebSocket subscribers.
+func (f *Fullnode) Subscribers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.subscribers)
+}
+
+// PushBlock pushes the block of the given index to WebSocket subscribers.
+// Like real fullnodes, the pushed block has no verbose fields.
+func (f *Fullnode) PushBlock(index uint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, ok := f.blocks[index]
+	if !ok {
+		return fmt.Errorf("unknown block %d", index)
+	}
+
+	block := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &block); err != nil {
+		return err
+	}
+
+	for _, field := range []string{"size", "confirmations", "nextblockhash"} {
+		delete(block, field)
+	}
+
+	event := struct {
+		JSONRPC string        `json:"jsonrpc"`
+		Method  string        `json:"method"`
+		Params  []interface{} `json:"params"`
+	}{"2.0", "block_added", []interface{}{block}}
+
+	for conn := range f.subscribers {
+		if err := conn.WriteJSON(event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Post implements rpc.Transport, requests are served in process.
+func (f *Fullnode) Post(url string, body []byte) ([]byte, error) {
+	return f.serve(body), nil
+}
+
+func (f *Fullnode) serve(body []byte) []byte {
+	body = bytes.TrimSpace(body)
+
+	var resp interface{}
+	if bytes.HasPrefix(body, []byte("[")) {
+		reqs := []request{}
+		if err := json.Unmarshal(body, &reqs); err != nil {
+			resp = invalidRequest()
+		} else {
+			resps := make([]response, len(reqs))
+			for i, req := range reqs {
+				resps[i] = f.call(req)
+			}
+			resp = resps
+		}
+	} else {
+		req := request{}
+		if err := json.Unmarshal(body, &req); err != nil {
+			resp = invalidRequest()
+		} else {
+			resp = f.call(req)
+		}
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		panic(err)
+	}
+
+	return data
+}
+
+func invalidRequest() response {
+	return response{
+		JSONRPC: "2.0",
+		ID:      json.RawMessage("null"),
+		Error:   &Error{Code: ErrCodeInvalidRequest, Message: "Invalid request"},
+	}
+}
+
+func (f *Fullnode) call(req request) response {
+	f.mu.Lock()
+	f.calls[req.Method]++
+	handler, ok := f.handlers[req.Method]
+	f.mu.Unlock()
+
+	if !ok {
+		handler = f.DefaultHandler(req.Method)
+	}
+
+	result, rpcErr := handler(req.Params)
+
+	return response{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Result:  result,
+		Error:   rpcErr,
+	}
+}
+
+// DefaultHandler returns the builtin handler of the given method,
+// which can be wrapped by custom handlers.
+func (f *Fullnode) DefaultHandler(method string) Handler {
+	switch method {
+	case "getversion":
+		return f.getVersion
+	case "getblockcount":
+		return f.getBlockCount
+	case "getblock":
+		return f.getBlock
+	case "getblockhash":
+		return f.getBlockHash
+	case "getapplicationlog":
+		return f.getAppLog
+	case "getcontractstate":
+		return f.getContractState
+	case "getnativecontracts":
+		return f.getNativeContracts
+	case "invokefunction":
+		return f.invokeFunction
+	case "invokescript":
+		return f.invokeScript
+	case "invokefunctionhistoric":
+		return f.historic(f.invokeFunction)
+	case "invokescripthistoric":
+		return f.historic(f.invokeScript)
+	default:
+		return MethodNotFound
+	}
+}
+
+// MethodNotFound is the handler of unsupported methods, it can be used
+// to simulate fullnodes without some of the builtin methods.
+func MethodNotFound([]json.RawMessage) (interface{}, *Error) {
+	return nil, &Error{Code: ErrCodeMethodNotFound, Message: "Method not found"}
+}
+
+func (f *Fullnode) getVersion([]json.RawMessage) (interface{}, *Error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return map[string]interface{}{
+		"tcpport":   10333,
+		"wsport":    10334,
+		"nonce":     1,
+		"useragent": "/Neo:3.0.0/",
+		"protocol":  f.protocol,
+	}, nil
+}
+
+func (f *Fullnode) getBlockCount([]json.RawMessage) (interface{}, *Error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.height + 1, nil
+}
+
+// visibleBlock returns the block of the given index or hash param.
+func (f *Fullnode) visibleBlock(param json.RawMessage) (json.RawMessage, uint, *Error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var index uint
+	if err := json.Unmarshal(param, &index); err != nil {
+		var hash string
+		if err := json.Unmarshal(param, &hash); err != nil {
+			return nil, 0, &Error{Code: ErrCodeInvalidParams, Message: "Invalid params"}
+		}
+
+		i, ok := f.blockHashes[hash]
+		if !ok {
+			return nil, 0, &Error{Code: ErrCodeUnknownItem, Message: "Unknown block"}
+		}
+		index = i
+	}
+
+	block, ok := f.blocks[index]
+	if !ok || int(index) > f.height {
+		return nil, 0, &Error{Code: ErrCodeUnknownItem, Message: "Unknown block"}
+	}
+
+	return block, index, nil
+}
+
+func (f *Fullnode) getBlock(params []json.RawMessage) (interface{}, *Error) {
+	if len(params) == 0 {
+		return nil, &Error{Code: ErrCodeInvalidParams, Message: "Invalid params"}
+	}
+
+	block, _, err := f.visibleBlock(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return block, nil
+}
+
+func (f *Fullnode) getBlockHash(params []json.RawMessage) (interface{}, *Error) {
+	if len(params) == 0 {
+		return nil, &Error{Code: ErrCodeInvalidParams, Message: "Invalid params"}
+	}
+
+	block, _, err := f.visibleBlock(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	hash := struct {
+		Hash string `json:"hash"`
+	}{}
+	json.Unmarshal(block, &hash)
+
+	return hash.Hash, nil
+}
+
+func (f *Fullnode) getAppLog(params []json.RawMessage) (interface{}, *Error) {
+	return f.lookup(f.appLogs, params, "Unknown transaction/blockhash")
+}
+
+func (f *Fullnode) getContractState(params []json.RawMessage) (interface{}, *Error) {
+	return f.lookup(f.contracts, params, "Unknown contract")
+}
+
+// getNativeContracts returns contract states with negative ids,
+// all of them are active since the genesis block.
+func (f *Fullnode) getNativeContracts([]json.RawMessage) (interface{}, *Error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	natives := []map[string]interface{}{}
+	for _, data := range f.contracts {
+		item := map[string]interface{}{}
+		if err := json.Unmarshal(data, &item); err != nil {
+			continue
+		}
+
+		if id, ok := item["id"].(float64); !ok || id >= 0 {
+			continue
+		}
+
+		item["updatehistory"] = []int{0}
+		natives = append(natives, item)
+	}
+
+	sort.Slice(natives, func(i, j int) bool {
+		return natives[i]["id"].(float64) > natives[j]["id"].(float64)
+	})
+
+	return natives, nil
+}
+
+func (f *Fullnode) invokeFunction(params []json.RawMessage) (interface{}, *Error) {
+	var contract, method string
+	if len(params) < 2 ||
+		json.Unmarshal(params[0], &contract) != nil ||
+		json.Unmarshal(params[1], &method) != nil {
+		return nil, &Error{Code: ErrCodeInvalidParams, Message: "Invalid params"}
+	}
+
+	key, _ := json.Marshal(contract + "." + method)
+	return f.lookup(f.invokes, []json.RawMessage{key}, "Unknown invocation")
+}
+
+func (f *Fullnode) invokeScript(params []json.RawMessage) (interface{}, *Error) {
+	return f.lookup(f.invokes, params, "Unknown invocation")
+}
+
+// historic wraps invoke handlers with the leading block index param
+// of historic invocations. Results do not change over heights.
+func (f *Fullnode) historic(handler Handler) Handler {
+	return func(params []json.RawMessage) (interface{}, *Error) {
+		if len(params) == 0 {
+			return nil, &Error{Code: ErrCodeInvalidParams, Message: "Invalid params"}
+		}
+
+		if _, _, err := f.visibleBlock(params[0]); err != nil {
+			return nil, err
+		}
+
+		return handler(params[1:])
+	}
+}
+
+// lookup returns the item of the first string param in the given map.
+func (f *Fullnode) lookup(items map[string]json.RawMessage, params []json.RawMessage, notFound string) (interface{}, *Error) {
+	var key string
+	if len(params) == 0 || json.Unmarshal(params[0], &key) != nil {
+		return nil, &Error{Code: ErrCodeInvalidParams, Message: "Invalid params"}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	item, ok := items[key]
+	if !ok {
+		item, ok = items[strings.ToLower(key)]
+	}
+
+	if !ok {
+		return nil, &Error{Code: ErrCodeUnknownItem, Message: notFound}
+	}
+
+	return item, nil
+}
+
+// Testdata returns the directory of the bundled fixtures.
+func Testdata() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "testdata")
+}
+
+// Setup starts a fake fullnode loaded with the bundled fixtures for the
+// test, and passes its url to use, e.g. rpc.UseFullnodes. Logs are written
+// to ./logs. The fullnode is closed and logs are removed when the test ends.
+func Setup(t *testing.T, use func(urls ...string)) *Fullnode {
+	t.Helper()
+
+	log.Init(true)
+
+	f := New()
+	t.Cleanup(func() {
+		f.Close()
+		os.RemoveAll("./logs")
+	})
+
+	if err := f.LoadFixtures(Testdata()); err != nil {
+		t.Fatal(err)
+	}
+
+	use(f.URL)
+
+	return f
+}
@@ -29,3 +29,13 @@ func (c *SafeCounter) Add(delta int) int {
 func (c *SafeCounter) SetIfHigher(v int) bool {
 	return atomic.CompareAndSwapInt32(&c.val, int32(v-1), int32(v))
 }
+
+// SetMax updates current value to the given integer if it is higher.
+func (c *SafeCounter) SetMax(v int) {
+	for {
+		curr := atomic.LoadInt32(&c.val)
+		if int32(v) <= curr || atomic.CompareAndSwapInt32(&c.val, curr, int32(v)) {
+			return
+		}
+	}
+}
@@ -22,8 +22,7 @@ const (
 	logNameWarning = "warn.log"
 	logNameError   = "error.log"
 
-	logTimeFormat  = "2006-01-02 15:04:05.000"
-	filePathPrefix = "neo3-squirrel"
+	logTimeFormat = "2006-01-02 15:04:05.000"
 )
 
 // Logger global logger.
@@ -34,6 +33,17 @@ type Logger struct {
 	Error *logrus.Logger
 }
 
+// rootDir is the source root of the module, trimmed from
+// file paths in log output wherever the module is checked out.
+var rootDir = func() string {
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		return ""
+	}
+
+	return strings.TrimSuffix(path.Dir(file), "util/log")
+}()
+
 var (
 	logger    Logger
 	logPath   = "./logs"
@@ -267,9 +277,7 @@ func fileInfo() string {
 	if !ok {
 		file = "<???>"
 	} else {
-		slash := strings.LastIndex(file, filePathPrefix)
-		slash += strings.Index(file[slash:], "/") + 1
-		file = file[slash:]
+		file = strings.TrimPrefix(file, rootDir)
 	}
 
 	return fmt.Sprintf("%s:%d", file, line)
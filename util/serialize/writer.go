@@ -0,0 +1,74 @@
+package serialize
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Writer writes data in the Neo binary serialization format.
+type Writer struct {
+	buf bytes.Buffer
+}
+
+// Bytes returns the written bytes.
+func (w *Writer) Bytes() []byte {
+	return w.buf.Bytes()
+}
+
+// WriteBytes writes raw bytes.
+func (w *Writer) WriteBytes(b []byte) {
+	w.buf.Write(b)
+}
+
+// WriteU8 writes a byte.
+func (w *Writer) WriteU8(v uint8) {
+	w.buf.WriteByte(v)
+}
+
+// WriteBool writes a bool as a byte.
+func (w *Writer) WriteBool(v bool) {
+	if v {
+		w.WriteU8(1)
+	} else {
+		w.WriteU8(0)
+	}
+}
+
+// WriteU32 writes a little-endian uint32.
+func (w *Writer) WriteU32(v uint32) {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	w.buf.Write(b)
+}
+
+// WriteU64 writes a little-endian uint64.
+func (w *Writer) WriteU64(v uint64) {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, v)
+	w.buf.Write(b)
+}
+
+// WriteVarUint writes a variable-length unsigned integer.
+func (w *Writer) WriteVarUint(v uint64) {
+	switch {
+	case v < 0xfd:
+		w.WriteU8(uint8(v))
+	case v <= 0xffff:
+		w.WriteU8(0xfd)
+		b := make([]byte, 2)
+		binary.LittleEndian.PutUint16(b, uint16(v))
+		w.buf.Write(b)
+	case v <= 0xffffffff:
+		w.WriteU8(0xfe)
+		w.WriteU32(uint32(v))
+	default:
+		w.WriteU8(0xff)
+		w.WriteU64(v)
+	}
+}
+
+// WriteVarBytes writes bytes prefixed with the length.
+func (w *Writer) WriteVarBytes(b []byte) {
+	w.WriteVarUint(uint64(len(b)))
+	w.WriteBytes(b)
+}
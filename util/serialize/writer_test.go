@@ -0,0 +1,26 @@
+package serialize
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestWriteVarUint(t *testing.T) {
+	cases := map[uint64]string{
+		0:          "00",
+		0xfc:       "fc",
+		0xfd:       "fdfd00",
+		0x10000:    "fe00000100",
+		0x10000000: "fe00000010",
+		1 << 32:    "ff0000000001000000",
+	}
+
+	for v, want := range cases {
+		w := Writer{}
+		w.WriteVarUint(v)
+
+		if get := fmt.Sprintf("%x", w.Bytes()); get != want {
+			t.Fatalf("Incorrect 'WriteVarUint(%d)' result, get: %s, want: %s", v, get, want)
+		}
+	}
+}
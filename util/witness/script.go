@@ -0,0 +1,216 @@
+package witness
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+// Opcodes used by standard verification scripts.
+const (
+	opPushInt8  = 0x00
+	opPushInt16 = 0x01
+	opPushData1 = 0x0C
+	opPush1     = 0x11
+	opPush16    = 0x20
+	opSyscall   = 0x41
+)
+
+const (
+	// MaxMultiSigKeys is the maximum number of keys of multi-signature accounts.
+	MaxMultiSigKeys = 1024
+	// PublicKeySize is the size of compressed secp256r1 public keys.
+	PublicKeySize = 33
+	// SignatureSize is the size of secp256r1 signatures.
+	SignatureSize = 64
+)
+
+// Account types decided by verification scripts.
+const (
+	// Standard accounts are verified by a single signature.
+	Standard = "standard"
+	// MultiSig accounts are verified by m-of-n signatures.
+	MultiSig = "multisig"
+	// Contract accounts are verified by the contract with the account hash.
+	Contract = "contract"
+	// Custom accounts are verified by non-standard scripts.
+	Custom = "custom"
+)
+
+var (
+	// checkSig is the interop id of System.Crypto.CheckSig.
+	checkSig = []byte{0x56, 0xe7, 0xb3, 0x27}
+	// checkMultisig is the interop id of System.Crypto.CheckMultisig.
+	checkMultisig = []byte{0x9e, 0xd0, 0xdc, 0x3a}
+)
+
+// Classify returns the account type of the verification script,
+// with the signature threshold and public keys if any.
+func Classify(script []byte) (string, int, [][]byte) {
+	if len(script) == 0 {
+		return Contract, 0, nil
+	}
+
+	if pubKey, ok := ParseSingleSig(script); ok {
+		return Standard, 1, [][]byte{pubKey}
+	}
+
+	if m, pubKeys, ok := ParseMultiSig(script); ok {
+		return MultiSig, m, pubKeys
+	}
+
+	return Custom, 0, nil
+}
+
+// ParseSingleSig returns the public key of a standard
+// single-signature verification script.
+func ParseSingleSig(script []byte) ([]byte, bool) {
+	if len(script) != 2+PublicKeySize+5 ||
+		script[0] != opPushData1 ||
+		script[1] != PublicKeySize ||
+		script[2+PublicKeySize] != opSyscall ||
+		!bytes.Equal(script[3+PublicKeySize:], checkSig) {
+		return nil, false
+	}
+
+	return script[2 : 2+PublicKeySize], true
+}
+
+// ParseMultiSig returns the threshold and public keys
+// of a standard m-of-n multi-signature verification script.
+func ParseMultiSig(script []byte) (int, [][]byte, bool) {
+	m, offset, ok := readInt(script, 0)
+	if !ok || m < 1 {
+		return 0, nil, false
+	}
+
+	pubKeys := [][]byte{}
+	for offset+2+PublicKeySize <= len(script) &&
+		script[offset] == opPushData1 &&
+		script[offset+1] == PublicKeySize {
+		pubKeys = append(pubKeys, script[offset+2:offset+2+PublicKeySize])
+		offset += 2 + PublicKeySize
+	}
+
+	n, offset, ok := readInt(script, offset)
+	if !ok || n != len(pubKeys) || m > n {
+		return 0, nil, false
+	}
+
+	if len(script) != offset+5 ||
+		script[offset] != opSyscall ||
+		!bytes.Equal(script[offset+1:], checkMultisig) {
+		return 0, nil, false
+	}
+
+	return m, pubKeys, true
+}
+
+// StandardScript returns the verification script
+// of the standard account of the public key.
+func StandardScript(pubKey []byte) []byte {
+	script := append([]byte{opPushData1, PublicKeySize}, pubKey...)
+	return append(append(script, opSyscall), checkSig...)
+}
+
+// MultiSigScript returns the verification script of the m-of-n
+// multi-signature account, public keys are sorted as the chain does.
+func MultiSigScript(m int, pubKeys [][]byte) ([]byte, error) {
+	n := len(pubKeys)
+	if m < 1 || m > n || n > MaxMultiSigKeys {
+		return nil, errors.New("invalid multi-signature threshold")
+	}
+
+	type point struct {
+		pubKey []byte
+		x, y   []byte
+	}
+
+	points := make([]point, n)
+	for i, pubKey := range pubKeys {
+		key, ok := DecodePublicKey(pubKey)
+		if !ok {
+			return nil, errors.New("invalid public key")
+		}
+
+		points[i] = point{pubKey, key.X.Bytes(), key.Y.Bytes()}
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		if c := compareInts(points[i].x, points[j].x); c != 0 {
+			return c < 0
+		}
+
+		return compareInts(points[i].y, points[j].y) < 0
+	})
+
+	script := emitInt(nil, m)
+	for _, p := range points {
+		script = append(append(script, opPushData1, PublicKeySize), p.pubKey...)
+	}
+
+	script = append(emitInt(script, n), opSyscall)
+	return append(script, checkMultisig...), nil
+}
+
+// ParseSignatures returns signatures pushed by the invocation script.
+func ParseSignatures(script []byte) ([][]byte, bool) {
+	sigs := [][]byte{}
+	for offset := 0; offset < len(script); offset += 2 + SignatureSize {
+		if offset+2+SignatureSize > len(script) ||
+			script[offset] != opPushData1 ||
+			script[offset+1] != SignatureSize {
+			return nil, false
+		}
+
+		sigs = append(sigs, script[offset+2:offset+2+SignatureSize])
+	}
+
+	return sigs, true
+}
+
+// readInt reads a small integer pushed at the offset,
+// returns the integer and the offset after it.
+func readInt(script []byte, offset int) (int, int, bool) {
+	if offset >= len(script) {
+		return 0, 0, false
+	}
+
+	switch op := script[offset]; {
+	case op >= opPush1 && op <= opPush16:
+		return int(op-opPush1) + 1, offset + 1, true
+	case op == opPushInt8 && offset+2 <= len(script):
+		return int(int8(script[offset+1])), offset + 2, true
+	case op == opPushInt16 && offset+3 <= len(script):
+		return int(int16(binary.LittleEndian.Uint16(script[offset+1:]))), offset + 3, true
+	}
+
+	return 0, 0, false
+}
+
+// emitInt appends the push of a small integer to the script.
+func emitInt(script []byte, v int) []byte {
+	switch {
+	case v >= 1 && v <= 16:
+		return append(script, byte(opPush1+v-1))
+	case v <= 127:
+		return append(script, opPushInt8, byte(v))
+	}
+
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, uint16(v))
+	return append(append(script, opPushInt16), b...)
+}
+
+// compareInts compares big-endian unsigned integers.
+func compareInts(a, b []byte) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+
+	return bytes.Compare(a, b)
+}
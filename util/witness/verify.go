@@ -0,0 +1,121 @@
+package witness
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"neo3-squirrel/util/hashutil"
+)
+
+// Errors of witness verification.
+var (
+	ErrInvalidInvocation   = errors.New("invalid invocation script")
+	ErrInvalidVerification = errors.New("unsupported verification script")
+	ErrInvalidSignature    = errors.New("invalid signature")
+)
+
+// GetSignData returns the data signed by witnesses of a block
+// or transaction with the given little-endian hash.
+func GetSignData(magic uint32, hash []byte) []byte {
+	data := make([]byte, 4, 4+len(hash))
+	binary.LittleEndian.PutUint32(data, magic)
+	return append(data, hash...)
+}
+
+// Verify checks signatures of the invocation script against public keys
+// of the standard single or multi-signature verification script.
+func Verify(signData, invocation, verification []byte) error {
+	sigs, ok := ParseSignatures(invocation)
+	if !ok {
+		return ErrInvalidInvocation
+	}
+
+	if pubKey, ok := ParseSingleSig(verification); ok {
+		if len(sigs) != 1 {
+			return ErrInvalidInvocation
+		}
+
+		if !VerifySignature(signData, sigs[0], pubKey) {
+			return ErrInvalidSignature
+		}
+
+		return nil
+	}
+
+	m, pubKeys, ok := ParseMultiSig(verification)
+	if !ok {
+		return ErrInvalidVerification
+	}
+
+	if len(sigs) != m {
+		return ErrInvalidInvocation
+	}
+
+	// Signatures are in the order of public keys.
+	for i, j := 0, 0; i < len(sigs); j++ {
+		if len(sigs)-i > len(pubKeys)-j {
+			return ErrInvalidSignature
+		}
+
+		if VerifySignature(signData, sigs[i], pubKeys[j]) {
+			i++
+		}
+	}
+
+	return nil
+}
+
+// VerifySignature verifies the secp256r1 signature of data.
+func VerifySignature(data, sig, pubKey []byte) bool {
+	if len(sig) != SignatureSize {
+		return false
+	}
+
+	key, ok := DecodePublicKey(pubKey)
+	if !ok {
+		return false
+	}
+
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+
+	return ecdsa.Verify(key, hashutil.Sha256(data), r, s)
+}
+
+// DecodePublicKey decodes a compressed secp256r1 public key.
+func DecodePublicKey(pubKey []byte) (*ecdsa.PublicKey, bool) {
+	if len(pubKey) != PublicKeySize || (pubKey[0] != 0x02 && pubKey[0] != 0x03) {
+		return nil, false
+	}
+
+	curve := elliptic.P256()
+	params := curve.Params()
+
+	// y² = x³ - 3x + b
+	x := new(big.Int).SetBytes(pubKey[1:])
+	if x.Cmp(params.P) >= 0 {
+		return nil, false
+	}
+
+	y := new(big.Int).Mul(x, x)
+	y.Mul(y, x)
+	y.Sub(y, new(big.Int).Mul(x, big.NewInt(3)))
+	y.Add(y, params.B)
+	y.Mod(y, params.P)
+
+	if y.ModSqrt(y, params.P) == nil {
+		return nil, false
+	}
+
+	if y.Bit(0) != uint(pubKey[0]&1) {
+		y.Sub(params.P, y)
+	}
+
+	if !curve.IsOnCurve(x, y) {
+		return nil, false
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, true
+}
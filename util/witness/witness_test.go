@@ -0,0 +1,119 @@
+package witness
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"math/big"
+	"neo3-squirrel/util/hashutil"
+	"testing"
+)
+
+func newKey(t *testing.T) (*ecdsa.PrivateKey, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pubKey := append([]byte{0x02 + byte(key.Y.Bit(0))}, pad32(key.X)...)
+	return key, pubKey
+}
+
+func sign(t *testing.T, key *ecdsa.PrivateKey, data []byte) []byte {
+	r, s, err := ecdsa.Sign(rand.Reader, key, hashutil.Sha256(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig := append(pad32(r), pad32(s)...)
+	return append([]byte{opPushData1, SignatureSize}, sig...)
+}
+
+func pad32(v *big.Int) []byte {
+	b := v.Bytes()
+	return append(make([]byte, 32-len(b)), b...)
+}
+
+func TestParseMultiSig(t *testing.T) {
+	// 1-of-1 multi-signature verification script.
+	script, _ := base64.StdEncoding.DecodeString("EQwhA+S7dZtv0nhtbVfqVT76Np6Sz6Q+UudS6L0EKqUiA+bgEUGe0Nw6")
+
+	m, pubKeys, ok := ParseMultiSig(script)
+	if !ok || m != 1 || len(pubKeys) != 1 {
+		t.Fatalf("Incorrect 'ParseMultiSig' result, get: (%d, %d keys, %v)", m, len(pubKeys), ok)
+	}
+
+	if _, ok := ParseSingleSig(script); ok {
+		t.Fatal("Multi-signature script must not be parsed as single-signature")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	data := GetSignData(860833102, make([]byte, 32))
+
+	key, pubKey := newKey(t)
+	single := append([]byte{opPushData1, PublicKeySize}, pubKey...)
+	single = append(append(single, opSyscall), checkSig...)
+
+	if err := Verify(data, sign(t, key, data), single); err != nil {
+		t.Fatalf("Valid single-signature witness must be verified, got %v", err)
+	}
+
+	if err := Verify([]byte("other"), sign(t, key, data), single); err != ErrInvalidSignature {
+		t.Fatalf("Signature of other data must be rejected, got %v", err)
+	}
+
+	// 2-of-3 multi-signature.
+	keys := []*ecdsa.PrivateKey{}
+	multi := []byte{opPush1 + 1}
+	for i := 0; i < 3; i++ {
+		key, pubKey := newKey(t)
+		keys = append(keys, key)
+		multi = append(append(multi, opPushData1, PublicKeySize), pubKey...)
+	}
+	multi = append(append(multi, opPush1+2, opSyscall), checkMultisig...)
+
+	invocation := append(sign(t, keys[0], data), sign(t, keys[2], data)...)
+	if err := Verify(data, invocation, multi); err != nil {
+		t.Fatalf("Valid multi-signature witness must be verified, got %v", err)
+	}
+
+	invocation = append(sign(t, keys[2], data), sign(t, keys[0], data)...)
+	if err := Verify(data, invocation, multi); err != ErrInvalidSignature {
+		t.Fatalf("Signatures out of key order must be rejected, got %v", err)
+	}
+
+	if err := Verify(data, sign(t, keys[0], data), multi); err != ErrInvalidInvocation {
+		t.Fatalf("Signatures fewer than the threshold must be rejected, got %v", err)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	_, pubKey := newKey(t)
+	single := append([]byte{opPushData1, PublicKeySize}, pubKey...)
+	single = append(append(single, opSyscall), checkSig...)
+
+	multi := append([]byte{opPush1}, single[:2+PublicKeySize]...)
+	multi = append(append(multi, opPush1, opSyscall), checkMultisig...)
+
+	cases := []struct {
+		script    []byte
+		want      string
+		threshold int
+		keys      int
+	}{
+		{nil, Contract, 0, 0},
+		{single, Standard, 1, 1},
+		{multi, MultiSig, 1, 1},
+		{[]byte{opPush1}, Custom, 0, 0},
+	}
+
+	for _, c := range cases {
+		get, threshold, pubKeys := Classify(c.script)
+		if get != c.want || threshold != c.threshold || len(pubKeys) != c.keys {
+			t.Fatalf("Incorrect 'Classify' result, get: (%s, %d, %d keys), want: (%s, %d, %d keys)",
+				get, threshold, len(pubKeys), c.want, c.threshold, c.keys)
+		}
+	}
+}